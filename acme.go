@@ -5,37 +5,64 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"flag"
 	"fmt"
 	"log"
 	"path"
-	"strconv"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"9fans.net/go/acme"
-	"9fans.net/go/plumb"
 	"rsc.io/todo/task"
 )
 
-const root = "/todo/" // acme window root "directory"
+// root is the acme window root "directory": /todo/,
+// or /todo/name/ when a profile is selected.
+var root = "/todo/"
+
+// previewDepth is the number of comment lines Preview shows for each task.
+const previewDepth = 2
+
+// snippetDepth is the number of lines matching a search's text
+// shown under each task in a list window.
+const snippetDepth = 2
 
 func runAcme() {
 	acme.AutoExit(true)
-
-	q := strings.Join(flag.Args(), " ")
-	if q == "" {
-		q = "all"
+	if p := task.Profile(); p != "" {
+		root = "/todo/" + p + "/"
 	}
 
+	q := strings.Join(flag.Args(), " ")
 	l := taskList(".")
-	if q == "new" {
-		openNew(l)
-	} else if look(l, q) {
-		// done
-	} else {
+	restored := 0
+	if *restoreFlag {
+		if restored = restoreSession(); restored == 0 {
+			log.Print("-restore: no saved acme session")
+		}
+	}
+	for _, name := range strings.Split(*openFlag, ",") {
+		if name = strings.TrimSpace(name); name != "" && !look(l, name) {
+			log.Printf("-open: no list or task %s", name)
+		}
+	}
+	if q == "" && *openFlag == "" && restored == 0 {
+		q = defaultQuery(l)
+	}
+	// Without q, only the windows opened by -open or -restore.
+	switch {
+	case q == "new":
+		openNew(l, "")
+	case q == "dashboard":
+		openDashboard(l)
+	case q == "agenda":
+		openAgenda(l)
+	case q == "milestones":
+		openMilestones(l)
+	case q != "" && !look(l, q):
 		openSearch(l, q)
 	}
 
@@ -48,16 +75,30 @@ const (
 	modeList
 	modeCreate
 	modeBulk
+	modeBoard
+	modeDashboard
+	modeAgenda
+	modeMilestones
 )
 
 type awin struct {
-	acme   *acme.Win
-	name   string
-	tag    string
-	mode   int
-	query  string
-	task   *task.Task
-	sortBy string // "" means "title"
+	acme      *acme.Win
+	name      string
+	tag       string
+	mu        sync.Mutex // guards mode and draft, which autosave reads
+	mode      int
+	query     string
+	base      string // original query, restored by Filter with no arguments
+	task      *task.Task
+	sortBy    string // set by Sort; "" means the list's sort setting
+	preview   bool   // show comment previews in list windows
+	collapsed bool   // show only the latest update in single-task windows
+	text      string // initial text for modeCreate ("" means createTemplate) or modeBulk
+	pending   string // bulk edit previewed by Put, applied by the next Put if unchanged
+	board     string // header whose values are the board columns
+	cols      []string
+	showCols  []string // set by Cols; nil means the list's cols setting
+	draft     string   // draft saving the text of a modeCreate window
 }
 
 // dir returns the window name's "directory": "/todo/home/" for /todo/home/123.
@@ -104,17 +145,183 @@ func open(w *awin) {
 	}
 	w.acme.SetErrorPrefix(w.dir()) // TODO
 	w.acme.Name(w.name)
-	w.acme.Ctl("cleartag")
-	w.acme.Fprintf("tag", " "+w.tag+" ")
+	w.setTag()
+	windows.Lock()
+	if windows.m == nil {
+		windows.m = make(map[*awin]bool)
+	}
+	windows.m[w] = true
+	windows.Unlock()
 	go w.ExecGet()
-	go w.acme.EventLoop(w)
+	if w.mode == modeCreate {
+		go w.autosave()
+	}
+	go func() {
+		w.acme.EventLoop(w)
+		windows.Lock()
+		delete(windows.m, w)
+		windows.Unlock()
+		closeSession()
+	}()
+}
+
+// setTag replaces the window's tag commands with w.tag,
+// leaving out those that change tasks if the list is read-only.
+func (w *awin) setTag() {
+	tag := w.tag
+	if w.list().ReadOnly() {
+		tag = readOnlyTag(tag)
+	}
+	w.acme.Ctl("cleartag")
+	w.acme.Fprintf("tag", " "+tag+" ")
+}
+
+// readOnlyTag returns tag without the commands that change tasks,
+// for windows showing read-only lists.
+func readOnlyTag(tag string) string {
+	var out []string
+	for _, cmd := range strings.Fields(tag) {
+		switch cmd {
+		case "Put", "Done", "New", "Bulk", "Move", "Refile", "Start", "Stop":
+			continue
+		}
+		out = append(out, cmd)
+	}
+	return strings.Join(out, " ")
+}
+
+// windows records the open windows, so that a Put in one window
+// can refresh the others showing the same tasks.
+var windows struct {
+	sync.Mutex
+	m map[*awin]bool
+}
+
+// changed updates the other windows after w writes the tasks ids.
+// Clean windows showing one of the tasks, or a list containing them,
+// are reloaded. Dirty windows showing one of the tasks are left alone,
+// to avoid discarding edits, but get a warning that they are stale.
+func (w *awin) changed(ids []string) {
+	l := w.list()
+	names := make(map[string]bool)
+	for _, id := range ids {
+		names[adir(l)+id] = true
+	}
+	windows.Lock()
+	var others []*awin
+	for ow := range windows.m {
+		if ow != w {
+			others = append(others, ow)
+		}
+	}
+	windows.Unlock()
+
+	for _, ow := range others {
+		ow.mu.Lock()
+		mode := ow.mode
+		ow.mu.Unlock()
+		switch mode {
+		case modeSingle:
+			if !names[ow.name] {
+				continue
+			}
+			if ow.dirty() {
+				ow.acme.Err(ow.name + " was changed by another window; Get to reload")
+				continue
+			}
+		case modeList, modeBoard:
+			if ow.list() != l || ow.dirty() {
+				continue
+			}
+		default:
+			continue
+		}
+		ow.ExecGet()
+	}
+}
+
+// dirty reports whether the window has unsaved changes.
+func (w *awin) dirty() bool {
+	data, err := w.acme.ReadAll("ctl")
+	if err != nil {
+		return false
+	}
+	f := strings.Fields(string(data))
+	return len(f) > 4 && f[4] == "1"
+}
+
+// draftInterval is how often a window creating a task
+// saves its text as a draft.
+const draftInterval = 5 * time.Second
+
+// autosave saves the text of a window creating a task as a draft
+// in the window's list whenever it has unsaved changes, so that
+// the text survives acme or todo exiting. Once Put creates the task,
+// autosave removes the draft. Deleting the window keeps the draft,
+// for todo resume.
+func (w *awin) autosave() {
+	l := w.list()
+	tick := time.NewTicker(draftInterval)
+	defer tick.Stop()
+	var saved []byte
+	for range tick.C {
+		w.mu.Lock()
+		stop := w.saveDraft(l, &saved)
+		w.mu.Unlock()
+		if stop {
+			return
+		}
+	}
 }
 
-func openNew(l *task.List) {
+// saveDraft saves the window's text as a draft in l if it has changed
+// since *saved, the text last saved, and updates *saved.
+// It reports whether autosave should stop: once the window
+// has created its task or been deleted, or if saving fails.
+// Holding w.mu keeps Put from creating the task meanwhile,
+// so that no draft is saved of text already written as a task.
+func (w *awin) saveDraft(l *task.List, saved *[]byte) (stop bool) {
+	// w.mu is locked
+	if w.mode != modeCreate {
+		if w.draft != "" {
+			l.RemoveDraft(w.draft)
+		}
+		return true
+	}
+	windows.Lock()
+	open := windows.m[w]
+	windows.Unlock()
+	if !open {
+		return true
+	}
+	if !w.dirty() {
+		return false
+	}
+	data, err := w.acme.ReadAll("body")
+	if err != nil || bytes.Equal(data, *saved) {
+		return false
+	}
+	if w.draft == "" {
+		w.draft, err = l.NewDraft(time.Now(), data)
+	} else {
+		err = l.WriteDraft(w.draft, data)
+	}
+	if err != nil {
+		w.acme.Err(fmt.Sprintf("saving draft: %v", err))
+		return true
+	}
+	*saved = data
+	return false
+}
+
+// openNew opens a window for creating a task in l,
+// holding text, or createTemplate if text is empty.
+func openNew(l *task.List, text string) {
 	open(&awin{
 		mode: modeCreate,
 		name: adir(l) + "new",
 		tag:  "Put Search",
+		text: text,
 	})
 }
 
@@ -122,7 +329,7 @@ func openTask(l *task.List, id string) {
 	open(&awin{
 		mode: modeSingle,
 		name: adir(l) + id,
-		tag:  "Get Put Done Look",
+		tag:  "Get Put Done Move Collapse Start Stop Web Look",
 	})
 }
 
@@ -130,8 +337,8 @@ func openAll(l *task.List) {
 	open(&awin{
 		mode:  modeList,
 		name:  adir(l) + "all",
-		query: "all",
-		tag:   "New Get Bulk Sort Search",
+		query: defaultQuery(l),
+		tag:   "New Get Bulk Board Cols Dashboard Filter Overdue Preview Sort Search",
 	})
 }
 
@@ -140,17 +347,79 @@ func openSearch(l *task.List, query string) {
 		mode:  modeList,
 		name:  adir(l) + "search",
 		query: query,
-		tag:   "New Get Bulk Sort Search",
+		tag:   "New Get Bulk Board Cols Dashboard Filter Overdue Preview Sort Search",
+	})
+}
+
+func openDashboard(l *task.List) {
+	open(&awin{
+		mode: modeDashboard,
+		name: adir(l) + "dashboard",
+		tag:  "New Get Search",
+	})
+}
+
+func openAgenda(l *task.List) {
+	open(&awin{
+		mode: modeAgenda,
+		name: adir(l) + "agenda",
+		tag:  "New Get Search",
+	})
+}
+
+// openBoard opens a board window showing the tasks in l matching query
+// in columns by the value of the header key.
+func openBoard(l *task.List, key, query string) {
+	open(&awin{
+		mode:  modeBoard,
+		name:  adir(l) + "board",
+		query: query,
+		board: key,
+		tag:   "New Get Bulk Search",
 	})
 }
 
 func (w *awin) Execute(line string) bool {
-	// Exec* methods handle all our comments.
+	// Exec* methods handle all our commands,
+	// except for the column names in board windows,
+	// which move the selected tasks to that column.
+	if w.mode == modeBoard {
+		for _, c := range w.cols {
+			if line == c {
+				if !w.putHeader(w.board + ": " + c) {
+					w.acme.Err(c + " needs a selection")
+				}
+				return true
+			}
+		}
+	}
 	return false
 }
 
-func (w *awin) ExecNew() {
-	openNew(w.list())
+// ExecNew opens a window for creating a task.
+// The arguments, if any, give the task's title,
+// optionally preceded by -template name to start from
+// a template in the list's _template directory instead of
+// the usual blank one. The current selection, if any,
+// becomes the task's description.
+func (w *awin) ExecNew(arg string) {
+	text := createTemplate
+	f := strings.Fields(arg)
+	if len(f) > 0 && f[0] == "-template" {
+		if len(f) < 2 {
+			w.acme.Err("New -template needs a template name")
+			return
+		}
+		data, err := w.list().Template(f[1])
+		if err != nil {
+			w.acme.Err(fmt.Sprintf("New: %v", err))
+			return
+		}
+		text = string(data)
+		f = f[2:]
+	}
+	text = fillTemplate(text, strings.Join(f, " "), strings.TrimSpace(w.acme.Selection()))
+	openNew(w.list(), text)
 }
 
 func (w *awin) ExecSearch(arg string) {
@@ -177,18 +446,34 @@ func (w *awin) ExecGet() (err error) {
 		w.acme.Addr("0")
 		w.acme.Ctl("dot=addr")
 		w.acme.Ctl("show")
+		saveSession()
 	}()
 
 	switch w.mode {
 	case modeCreate:
 		w.acme.Clear()
-		w.acme.Write("body", []byte(createTemplate))
+		text := w.text
+		if text == "" {
+			text = createTemplate
+		}
+		w.acme.Write("body", []byte(text))
 
 	case modeSingle:
 		var buf bytes.Buffer
-		t, err := showTask(&buf, w.list(), w.id())
-		if err != nil {
-			return err
+		var t *task.Task
+		if w.collapsed {
+			t, err = w.list().Read(w.id())
+			if err != nil {
+				return err
+			}
+			if n := t.PrintRecentTo(&buf, 1); n > 0 {
+				fmt.Fprintf(&buf, "\n(%d earlier update%s hidden; Expand to show)\n", n, suffix(n))
+			}
+		} else {
+			t, err = showTask(&buf, w.list(), w.id())
+			if err != nil {
+				return err
+			}
 		}
 		w.acme.Clear()
 		w.acme.Write("body", buf.Bytes())
@@ -196,7 +481,11 @@ func (w *awin) ExecGet() (err error) {
 
 	case modeList:
 		var buf bytes.Buffer
-		err := showQuery(&buf, w.list(), w.query)
+		opt := &queryOptions{sort: w.sortKey(), snippet: snippetDepth, cols: w.colsShown()}
+		if w.preview {
+			opt.preview = previewDepth
+		}
+		err := showQuery(&buf, w.list(), w.query, opt)
 		if err != nil {
 			return err
 		}
@@ -206,6 +495,12 @@ func (w *awin) ExecGet() (err error) {
 			w.acme.Fprintf("body", "Search %s\n\n", w.query)
 
 		case "all":
+			if w.base != "" && w.query != w.base {
+				w.acme.Fprintf("body", "Filter %s\n\n", w.query)
+			}
+			if about := w.list().About(); about != "" {
+				w.acme.Fprintf("body", "%s\n\n", about)
+			}
 			var buf bytes.Buffer
 			for _, name := range w.list().Sublists() {
 				fmt.Fprintf(&buf, "%s/\n", name)
@@ -217,11 +512,82 @@ func (w *awin) ExecGet() (err error) {
 		}
 		w.acme.PrintTabbed(buf.String())
 
-	case modeBulk:
-		body, err := w.acme.ReadAll("body")
+	case modeBoard:
+		tasks, err := search(w.list(), w.query)
 		if err != nil {
 			return err
 		}
+		sort.Sort(tasksByTitle(tasks))
+		cols := boardColumns(w.list(), w.board, tasks)
+		_, groups := groupTasks(tasks, w.board)
+		var buf bytes.Buffer
+		w.cols = nil
+		for _, c := range cols {
+			name := c
+			if name == "" {
+				name = "(none)"
+			} else {
+				w.cols = append(w.cols, c)
+			}
+			fmt.Fprintf(&buf, "%s: %s (%d)\n", w.board, name, len(groups[c]))
+			for _, t := range groups[c] {
+				fmt.Fprintf(&buf, "%s\t%s\n", t.ID(), t.Title())
+			}
+			fmt.Fprintf(&buf, "\n")
+		}
+		w.acme.Ctl("cleartag")
+		w.acme.Fprintf("tag", " %s | %s ", w.tag, strings.Join(w.cols, " "))
+		w.acme.Clear()
+		w.acme.PrintTabbed(buf.String())
+
+	case modeDashboard:
+		var buf bytes.Buffer
+		if err := writeDashboard(&buf, w.list()); err != nil {
+			return err
+		}
+		w.acme.Clear()
+		w.acme.PrintTabbed(buf.String())
+
+	case modeAgenda:
+		var buf bytes.Buffer
+		if err := writeAgenda(&buf, w.list(), time.Now(), agendaDays); err != nil {
+			return err
+		}
+		w.acme.Clear()
+		w.acme.PrintTabbed(buf.String())
+
+	case modeMilestones:
+		var buf bytes.Buffer
+		if err := writeMilestones(&buf, w.list(), false, true); err != nil {
+			return err
+		}
+		w.acme.Clear()
+		w.acme.PrintTabbed(buf.String())
+
+	case modeBulk:
+		var body []byte
+		switch {
+		case w.query != "":
+			tasks, err := search(w.list(), w.query)
+			if err != nil {
+				return err
+			}
+			sort.Sort(tasksByTitle(tasks))
+			var buf bytes.Buffer
+			for _, t := range tasks {
+				fmt.Fprintf(&buf, "%s\t%s\n", t.ID(), t.Title())
+			}
+			body = buf.Bytes()
+		case w.text != "":
+			body = []byte(w.text)
+			w.text = ""
+		default:
+			var err error
+			body, err = w.acme.ReadAll("body")
+			if err != nil {
+				return err
+			}
+		}
 		base, original, err := bulkEditStartFromText(w.list(), body)
 		if err != nil {
 			return err
@@ -250,13 +616,13 @@ func look(l *task.List, text string) bool {
 
 	// Otherwise, expect a single ID relative to the list,
 	// which may mean switching to a different list.
-	// A /todo/ prefix is OK to signal the root.
+	// A /todo/ prefix (or /todo/profile/) is OK to signal the root.
 	// Do not try to handle a rooted path outside the todo hierarchy,
 	// like /tmp or ../../tmp.
 	var list, id string
-	if strings.HasPrefix(text, "/todo/") {
+	if strings.HasPrefix(text, root) {
 		list = "."
-		id = strings.TrimPrefix(id, "/todo/")
+		id = strings.TrimPrefix(text, root)
 	} else if strings.HasPrefix(text, "/") {
 		return false
 	} else {
@@ -292,29 +658,42 @@ func look(l *task.List, text string) bool {
 	return false
 }
 
+// putTask writes the text of a single-task window as an update to its task,
+// or, in a window creating a task, as the new task, which the window
+// then shows.
+func (w *awin) putTask() (*task.Task, error) {
+	// w.mu is locked
+	data, err := w.acme.ReadAll("body")
+	if err != nil {
+		return nil, fmt.Errorf("Put: %v", err)
+	}
+	t, err := writeTask(w.list(), w.task, data)
+	if err != nil {
+		return nil, err
+	}
+	if w.mode == modeCreate {
+		w.mode = modeSingle
+		w.name = w.dir() + t.ID()
+		w.acme.Name(w.name)
+		w.task = t
+	}
+	return t, nil
+}
+
 func (w *awin) ExecPut() {
 	stop := w.acme.Blink()
 	defer stop()
 	switch w.mode {
 	case modeSingle, modeCreate:
-		old := w.task
-		data, err := w.acme.ReadAll("body")
-		if err != nil {
-			w.acme.Err(fmt.Sprintf("Put: %v", err))
-			return
-		}
-		t, err := writeTask(w.list(), old, data, false)
+		w.mu.Lock()
+		t, err := w.putTask()
+		w.mu.Unlock()
 		if err != nil {
 			w.acme.Err(err.Error())
 			return
 		}
-		if w.mode == modeCreate {
-			w.mode = modeSingle
-			w.name = w.dir() + t.ID()
-			w.acme.Name(w.name)
-			w.task = t
-		}
 		w.ExecGet()
+		w.changed([]string{t.ID()})
 
 	case modeBulk:
 		data, err := w.acme.ReadAll("body")
@@ -322,7 +701,24 @@ func (w *awin) ExecPut() {
 			w.acme.Err(fmt.Sprintf("Put: %v", err))
 			return
 		}
+		summary, n, err := bulkChanges(w.list(), w.task, data)
+		if err != nil {
+			w.acme.Err(fmt.Sprintf("Put: %v", err))
+			return
+		}
+		if n == 0 {
+			w.acme.Err("Put: no changes")
+			return
+		}
+		if w.pending != string(data) {
+			// Show what would change, and apply only on a second Put.
+			w.pending = string(data)
+			w.acme.Err(fmt.Sprintf("Put will update %d task%s; Put again to apply:\n%s", n, suffix(n), summary))
+			return
+		}
+		w.pending = ""
 		ids, err := bulkWriteTask(w.list(), w.task, data, func(s string) { w.acme.Err("Put: " + s) })
+		w.changed(ids)
 		if err != nil {
 			errText := strings.Replace(err.Error(), "\n", "\t\n", -1)
 			if len(ids) > 0 {
@@ -334,13 +730,13 @@ func (w *awin) ExecPut() {
 		}
 		w.acme.Err(fmt.Sprintf("updated %d task%s", len(ids), suffix(len(ids))))
 
-	case modeList:
+	case modeList, modeBoard, modeDashboard, modeAgenda, modeMilestones:
 		w.acme.Err("cannot Put task list")
 	}
 }
 
 func (w *awin) ExecDel() {
-	if w.mode == modeList {
+	if w.mode == modeList || w.mode == modeBoard || w.mode == modeDashboard || w.mode == modeAgenda || w.mode == modeMilestones {
 		w.acme.Ctl("delete")
 		return
 	}
@@ -353,9 +749,21 @@ func (w *awin) ExecDebug() {
 	}
 }
 
-func (w *awin) ExecBulk() {
-	// TODO(rsc): If Bulk has an argument, treat as search query and use results?
-	if w.mode != modeList {
+// ExecBulk opens a bulk edit window for the tasks matching
+// the query arg, or, with no argument, for the tasks in the
+// selection or else the whole window.
+// A window started from a query reruns it on Get.
+func (w *awin) ExecBulk(arg string) {
+	if arg != "" {
+		open(&awin{
+			name:  w.dir() + "bulkedit",
+			mode:  modeBulk,
+			tag:   "New Get Done Sort Search",
+			query: arg,
+		})
+		return
+	}
+	if w.mode != modeList && w.mode != modeBoard {
 		w.acme.Err("can only start bulk edit in task list windows")
 		return
 	}
@@ -374,9 +782,236 @@ func (w *awin) ExecBulk() {
 		mode:  modeBulk,
 		tag:   "New Get Done Sort Search",
 		query: "",
+		text:  text,
 	})
 }
 
+// ExecBoard opens a board window for the list, with columns
+// for the values of the header named by arg or, if arg is empty,
+// by the first board setting in the list's configuration.
+func (w *awin) ExecBoard(arg string) {
+	key := strings.ToLower(arg)
+	if key == "" {
+		for _, line := range w.list().Config().Values("board") {
+			if f := strings.Fields(line); len(f) > 0 {
+				key = strings.ToLower(f[0])
+				break
+			}
+		}
+	}
+	if key == "" {
+		w.acme.Err("Board needs a header name")
+		return
+	}
+	query := w.query
+	if w.mode != modeList && w.mode != modeBoard {
+		query = "all"
+	}
+	openBoard(w.list(), key, query)
+}
+
+// boardColumns returns the columns for a board of tasks by the header key:
+// the values listed for key in the list's board setting, if any,
+// then, for the todo header, the list's states, in workflow order,
+// followed by any other values used by tasks, in sorted order,
+// and then "" if any tasks lack the header.
+func boardColumns(l *task.List, key string, tasks []*task.Task) []string {
+	var cols []string
+	have := make(map[string]bool)
+	for _, line := range l.Config().Values("board") {
+		f := strings.Fields(line)
+		if len(f) > 0 && strings.ToLower(f[0]) == key {
+			for _, c := range f[1:] {
+				if !have[c] {
+					have[c] = true
+					cols = append(cols, c)
+				}
+			}
+		}
+	}
+	if key == "todo" {
+		// The list's states, in workflow order, before any others.
+		for _, c := range l.States().Order {
+			if !have[c] {
+				have[c] = true
+				cols = append(cols, c)
+			}
+		}
+	}
+	values, _ := groupTasks(tasks, key)
+	for _, v := range values {
+		if !have[v] {
+			have[v] = true
+			cols = append(cols, v)
+		}
+	}
+	return cols
+}
+
+func (w *awin) ExecDashboard() {
+	if acme.Show(adir(w.list())+"dashboard") == nil {
+		openDashboard(w.list())
+	}
+}
+
+func (w *awin) ExecAgenda() {
+	if acme.Show(adir(w.list())+"agenda") == nil {
+		openAgenda(w.list())
+	}
+}
+
+// ExecMklist creates the list named by arg, such as work/infra,
+// relative to the root, and opens a window showing it.
+func (w *awin) ExecMklist(arg string) {
+	if arg == "" {
+		w.acme.Err("Mklist needs a list name")
+		return
+	}
+	l, err := task.NewList(strings.TrimPrefix(arg, root), "")
+	if err != nil {
+		w.acme.Err("Mklist: " + err.Error())
+		return
+	}
+	openAll(taskList(l.Name()))
+}
+
+// ExecFilter refines the query of a list window by adding the terms in arg,
+// as in "Filter -tag:x", and reloads the window.
+// Because "all" excludes done tasks, a term constraining the todo header,
+// as in "Filter todo:done", replaces "all" in the query.
+// With no arguments, Filter restores the window's original query.
+func (w *awin) ExecFilter(arg string) {
+	if w.mode != modeList && w.mode != modeBoard {
+		w.acme.Err("Filter can only filter task list windows")
+		return
+	}
+	if w.base == "" {
+		w.base = w.query
+	}
+	if arg == "" {
+		w.query = w.base
+		w.ExecGet()
+		return
+	}
+	var terms []string
+	dropAll := strings.Contains(" "+arg, " todo:")
+	for _, f := range strings.Fields(w.query) {
+		if f == "all" && dropAll {
+			continue
+		}
+		terms = append(terms, f)
+	}
+	w.query = strings.Join(append(terms, strings.Fields(arg)...), " ")
+	w.ExecGet()
+}
+
+// ExecCollapse shows only the headers and latest update
+// in a single-task window, hiding the rest of its history.
+func (w *awin) ExecCollapse() {
+	w.setCollapsed(true)
+}
+
+// ExecExpand undoes ExecCollapse, showing the full history.
+func (w *awin) ExecExpand() {
+	w.setCollapsed(false)
+}
+
+func (w *awin) setCollapsed(collapsed bool) {
+	if w.mode != modeSingle {
+		w.acme.Err("Collapse and Expand can only be used in single task windows")
+		return
+	}
+	if w.dirty() {
+		w.acme.Err(w.name + " has unsaved changes; Put or Get first")
+		return
+	}
+	w.collapsed = collapsed
+	if collapsed {
+		w.tag = strings.Replace(w.tag, "Collapse", "Expand", 1)
+	} else {
+		w.tag = strings.Replace(w.tag, "Expand", "Collapse", 1)
+	}
+	w.setTag()
+	w.ExecGet()
+}
+
+// ExecPreview toggles showing, beneath each task in a list window,
+// the first lines of the task's latest comment.
+func (w *awin) ExecPreview() {
+	if w.mode != modeList {
+		w.acme.Err("Preview can only be used in task list windows")
+		return
+	}
+	w.preview = !w.preview
+	w.ExecGet()
+}
+
+// ExecMove moves the task in a single-task window to the list
+// named by arg, such as work/infra, and renames the window to match.
+func (w *awin) ExecMove(arg string) {
+	w.move("Move", arg)
+}
+
+// ExecRefile is like ExecMove but also records the list as a
+// recently used refile destination, as todo refile does.
+// Without an argument, it lists the destinations, most recent first.
+func (w *awin) ExecRefile(arg string) {
+	if arg == "" && w.mode == modeSingle {
+		w.acme.Err("Refile needs a list name: " + strings.Join(refileTargets(w.list()), " "))
+		return
+	}
+	if name := w.move("Refile", arg); name != "" {
+		noteRefile(name)
+	}
+}
+
+// move implements the command cmd, which is Move or Refile,
+// returning the name of the destination list, or "" on failure.
+func (w *awin) move(cmd, arg string) string {
+	if w.mode != modeSingle {
+		w.acme.Err(cmd + " can only move single task windows")
+		return ""
+	}
+	if w.dirty() {
+		w.acme.Err(w.name + " has unsaved changes; Put or Get first")
+		return ""
+	}
+	if arg == "" {
+		w.acme.Err(cmd + " needs a list name")
+		return ""
+	}
+	name := strings.Trim(strings.TrimPrefix(arg, root), "/")
+	if name == "" {
+		name = "."
+	}
+	if !task.IsList(name) {
+		w.acme.Err(fmt.Sprintf("%s: no list %s", cmd, name))
+		return ""
+	}
+	l := w.list()
+	t, err := l.Read(w.id())
+	if err != nil {
+		w.acme.Err(err.Error())
+		return ""
+	}
+	dst := taskList(name)
+	old := t.ID()
+	t, err = l.Move(t, dst)
+	if err != nil {
+		w.acme.Err(err.Error())
+		return ""
+	}
+	// Refresh the windows showing the source list,
+	// then, once w names the moved task, those showing dst.
+	w.changed([]string{old})
+	w.name = adir(dst) + t.ID()
+	w.acme.Name(w.name)
+	w.acme.SetErrorPrefix(w.dir())
+	w.ExecGet()
+	w.changed([]string{t.ID()})
+	return name
+}
+
 func (w *awin) ExecDone() {
 	w.putHeader("todo: done")
 }
@@ -386,17 +1021,44 @@ func (w *awin) ExecMute() {
 }
 
 func (w *awin) ExecSnooze(arg string) {
-	days := 1
-	if arg != "" {
-		n, err := strconv.Atoi(arg)
-		if err != nil {
-			w.acme.Err("Snooze needs numeric day count")
-			return
-		}
-		days = n
+	v, err := snoozeValue(arg)
+	if err != nil {
+		w.acme.Err("Snooze: " + err.Error())
+		return
 	}
-	wakeup := time.Now().Add(time.Duration(days) * 24 * time.Hour).Format("2006-01-02")
-	w.putHeader("todo: snooze " + wakeup)
+	w.putHeader("todo: " + v)
+}
+
+// ExecDue sets the due header of the task in a single-task window,
+// or of the tasks in a bulk edit window or the selection in a list window,
+// to the date arg, which can be written in words, as in Due fri 15:00.
+func (w *awin) ExecDue(arg string) {
+	if strings.TrimSpace(arg) == "" {
+		w.acme.Err("Due: missing date")
+		return
+	}
+	v := task.NormalizeDate(arg, time.Now())
+	if _, err := time.Parse("2006-01-02", strings.Fields(v)[0]); err != nil {
+		w.acme.Err(fmt.Sprintf("Due: invalid date %q", arg))
+		return
+	}
+	if !w.putHeader("due: " + v) {
+		w.acme.Err("Due: no task selected")
+	}
+}
+
+// ExecOverdue shows the overdue tasks matching
+// a list window's original query.
+func (w *awin) ExecOverdue() {
+	if w.mode != modeList && w.mode != modeBoard {
+		w.acme.Err("Overdue can only be used in task list windows")
+		return
+	}
+	if w.base == "" {
+		w.base = w.query
+	}
+	w.query = w.base + " due:<today"
+	w.ExecGet()
 }
 
 func (w *awin) putHeader(hdr string) bool {
@@ -409,11 +1071,16 @@ func (w *awin) putHeader(hdr string) bool {
 	if w.mode == modeSingle || w.mode == modeBulk {
 		w.acme.Addr("0")
 		w.acme.Write("data", []byte(hdr))
+		if w.mode == modeBulk {
+			// The command itself is the confirmation.
+			data, _ := w.acme.ReadAll("body")
+			w.pending = string(data)
+		}
 		w.ExecPut()
 		w.acme.Ctl("del")
 		return true
 	}
-	if w.mode == modeList {
+	if w.mode == modeList || w.mode == modeBoard {
 		text := w.acme.Selection()
 		if text == "" {
 			return false
@@ -424,44 +1091,16 @@ func (w *awin) putHeader(hdr string) bool {
 			return true
 		}
 		edited := append([]byte(hdr), original...)
-		bulkWriteTask(w.list(), base, edited, func(s string) { w.acme.Err("Put: " + s) })
+		ids, _ := bulkWriteTask(w.list(), base, edited, func(s string) { w.acme.Err("Put: " + s) })
+		w.changed(ids)
+		if w.mode == modeBoard {
+			// Redraw to show the tasks in their new columns.
+			w.ExecGet()
+			return true
+		}
 		w.acme.Ctl("addr=dot")
 		w.acme.Write("data", nil)
 		return true
 	}
 	return false
 }
-
-func servePlumb() {
-	kind := strings.Trim(root, "/")
-	fid, err := plumb.Open(kind, 0)
-	if err != nil {
-		acme.Err(root, fmt.Sprintf("plumb: %v", err))
-		return
-	}
-	r := bufio.NewReader(fid)
-	for {
-		var m plumb.Message
-		if err := m.Recv(r); err != nil {
-			acme.Errf(root, "plumb recv: %v", err)
-			return
-		}
-		if m.Type != "text" {
-			acme.Errf(root, "plumb recv: unexpected type: %s\n", m.Type)
-			continue
-		}
-		if m.Dst != kind {
-			acme.Errf(root, "plumb recv: unexpected dst: %s\n", m.Dst)
-			continue
-		}
-		// TODO use m.Dir?
-		data := string(m.Data)
-		if !strings.HasPrefix(data, root) || strings.Contains(data, "\n") {
-			acme.Errf(root, "plumb recv: bad text %q", data)
-			continue
-		}
-		if !look(taskList("."), strings.TrimPrefix(data, root)) {
-			acme.Errf(root, "plumb recv: can't look %s", data)
-		}
-	}
-}
@@ -0,0 +1,81 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+func cmdActivity(args []string) {
+	fs := flag.NewFlagSet("activity", flag.ExitOnError)
+	since := fs.String("since", "1w", "print changes made since `when`, a duration ago, such as 8h, 3d, or 1w, or a date, such as mon or 2019-07-01")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo activity [-since when]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+	}
+	start, err := parseSince(*since, time.Now())
+	if err != nil {
+		log.Fatalf("invalid -since: %v", err)
+	}
+
+	type change struct {
+		t    time.Time
+		line string
+	}
+	var changes []change
+	for _, l := range allLists(taskList(*dirFlag)) {
+		entries, err := l.Journal(start)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, e := range entries {
+			line := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\n", e.Time.Local().Format("2006-01-02 15:04"), e.Actor, e.Event, path.Join(l.Name(), e.ID), e.Detail)
+			changes = append(changes, change{e.Time, line})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].t.Before(changes[j].t) })
+	var buf bytes.Buffer
+	for _, c := range changes {
+		buf.WriteString(c.line)
+	}
+	page(buf.Bytes())
+}
+
+// parseSince parses the value of a -since flag: a duration before now,
+// such as 8h or 3d, as in task.ParseLead, or a date, as in task.ParseDate,
+// such as mon or 2019-07-01, meaning the start of that day.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := task.ParseLead(s); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := task.ParseDate(s, now)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: want duration, such as 3d, or date, such as 2019-07-01", s)
+	}
+	day, w := strings.ToLower(t.Weekday().String()), strings.ToLower(strings.TrimSpace(s))
+	if t.After(now) && (w == day || w == day[:3]) {
+		// A weekday means the last one, not the next.
+		t = t.AddDate(0, 0, -7)
+	}
+	if t.After(now) {
+		return time.Time{}, fmt.Errorf("invalid time %q: in the future", s)
+	}
+	return t, nil
+}
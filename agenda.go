@@ -0,0 +1,156 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+// agendaDays is the number of days shown by todo week and the acme agenda window.
+const agendaDays = 7
+
+func cmdToday(args []string) { agendaCommand("today", 1, args) }
+func cmdWeek(args []string)  { agendaCommand("week", agendaDays, args) }
+
+func agendaCommand(name string, days int, args []string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo %s\n", name)
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+	}
+	var buf bytes.Buffer
+	if err := writeAgenda(&buf, taskList(*dirFlag), time.Now(), days); err != nil {
+		log.Fatal(err)
+	}
+	page(buf.Bytes())
+}
+
+// An agendaItem is a single entry in an agenda.
+type agendaItem struct {
+	day  string // 2006-01-02
+	at   string // time of day, such as 15:00, or "" for all day
+	ref  string // task name relative to the agenda's list
+	t    *task.Task
+	what string // due, scheduled, or wakes up
+}
+
+// writeAgenda writes to w the agenda for the tasks in l and its sublists
+// for the given number of days starting with the day of now:
+// overdue tasks first, followed by a section for each day listing
+// the tasks due, scheduled, or waking from a snooze that day,
+// all-day items first and then the others in time order.
+// Task names are relative to l, so that they can be looked at
+// in an acme window for l.
+func writeAgenda(w io.Writer, l *task.List, now time.Time, days int) error {
+	today := now.Format("2006-01-02")
+	end := now.AddDate(0, 0, days).Format("2006-01-02")
+
+	var overdue, items []*agendaItem
+	for _, sub := range allLists(l) {
+		all, err := sub.All()
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(sub.Name(), l.Name()), "/")
+		for _, t := range all {
+			ref := path.Join(rel, t.ID())
+			add := func(value, what string) {
+				day, at := agendaTime(value)
+				if day == "" || day >= end {
+					return
+				}
+				it := &agendaItem{day: day, at: at, ref: ref, t: t, what: what}
+				if day < today {
+					if what == "due" {
+						overdue = append(overdue, it)
+					}
+					return
+				}
+				items = append(items, it)
+			}
+			add(t.Header("due"), "due")
+			add(t.Header("scheduled"), "scheduled")
+			if s := t.Header("todo"); strings.HasPrefix(s, "snooze ") {
+				add(strings.TrimPrefix(s, "snooze "), "wakes up")
+			}
+		}
+	}
+	sort.SliceStable(overdue, func(i, j int) bool { return agendaLess(overdue[i], overdue[j]) })
+	sort.SliceStable(items, func(i, j int) bool { return agendaLess(items[i], items[j]) })
+
+	if len(overdue) > 0 {
+		fmt.Fprintf(w, "Overdue\n")
+		for _, it := range overdue {
+			fmt.Fprintf(w, "\t%s\t%s\t%s\t(due)\n", strings.TrimSpace(it.day+" "+it.at), it.ref, it.t.Title())
+		}
+		fmt.Fprintf(w, "\n")
+	}
+	for d := 0; d < days; d++ {
+		day := now.AddDate(0, 0, d)
+		key := day.Format("2006-01-02")
+		heading := day.Format("Mon 2006-01-02")
+		if d == 0 {
+			heading += " (today)"
+		}
+		fmt.Fprintf(w, "%s\n", heading)
+		n := 0
+		for _, it := range items {
+			if it.day == key {
+				fmt.Fprintf(w, "\t%s\t%s\t%s\t(%s)\n", it.at, it.ref, it.t.Title(), it.what)
+				n++
+			}
+		}
+		if n == 0 {
+			fmt.Fprintf(w, "\tnothing\n")
+		}
+		if d+1 < days {
+			fmt.Fprintf(w, "\n")
+		}
+	}
+	return nil
+}
+
+// agendaTime splits a due, scheduled, or snooze date, which may
+// include a time of day, into the day and the time of day, if any.
+// It returns day == "" if the value is not a date.
+func agendaTime(value string) (day, at string) {
+	t, ok := parseDue(value)
+	if !ok {
+		return "", ""
+	}
+	day = t.Format("2006-01-02")
+	if strings.Contains(strings.TrimSpace(value), " ") {
+		at = t.Format("15:04")
+	}
+	return day, at
+}
+
+// agendaLess reports whether x comes before y in an agenda:
+// by day, then all-day items before timed ones, then by time, then by name.
+func agendaLess(x, y *agendaItem) bool {
+	if x.day != y.day {
+		return x.day < y.day
+	}
+	if x.at != y.at {
+		return x.at < y.at // "" sorts first
+	}
+	return x.ref < y.ref
+}
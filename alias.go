@@ -0,0 +1,43 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+func cmdAlias(args []string) {
+	fs := flag.NewFlagSet("alias", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo alias [name query]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	l := taskList(*dirFlag)
+	switch fs.NArg() {
+	case 0:
+		aliases := l.Aliases()
+		var names []string
+		for name := range aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%s = %s\n", name, aliases[name])
+		}
+	case 1:
+		fs.Usage()
+	default:
+		if err := l.AddAlias(fs.Arg(0), strings.Join(fs.Args()[1:], " ")); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+func cmdAmend(args []string) {
+	fs := flag.NewFlagSet("amend", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo amend id\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+	}
+	l := writableList(*dirFlag)
+	t, err := readTask(l, fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	notePrevious(l, t)
+
+	// Find the last update with a comment.
+	updates := t.Updates()
+	n := len(updates) - 1
+	for n >= 0 && updates[n].Comment == "" {
+		n--
+	}
+	if n < 0 {
+		log.Fatalf("%s has no comments to amend", taskRef(l, t))
+	}
+	original := []byte(updates[n].Comment + "\n")
+	updated := editText(original)
+	if bytes.Equal(bytes.TrimSpace(original), bytes.TrimSpace(updated)) {
+		log.Print("no changes made")
+		return
+	}
+	if _, err := l.Amend(t.ID(), n, updated, time.Now()); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,419 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+// Backups.
+//
+// A backup is a tar file holding the files under the todo root,
+// with their paths relative to the root. Its first entry, _backup/info,
+// says whether it is a full backup or an incremental one and when it
+// was made. An incremental backup holds only the tasks changed since the
+// previous backup, as recorded in the lists' journals, along with the
+// lists' settings, state, and journals and the newly deleted and
+// archived tasks. Its last entry, _backup/removed, lists the tasks moved,
+// deleted, or archived since the previous backup, which restoring it
+// removes from their old places.
+// The time of each backup is recorded as the backup state of the root list.
+
+func cmdBackup(args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	to := fs.String("to", "", "write the backup to `file`, compressed according to its extension: .tar, .tar.gz, .tgz, or .tar.zst (default todo-backup-TIME.tar.gz)")
+	incremental := fs.Bool("incremental", false, "back up only the changes since the last backup")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo backup [-incremental] [-to file]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+	}
+
+	root := taskList("")
+	now := time.Now()
+	info := "full " + now.Format(time.RFC3339) + "\n"
+	var since time.Time
+	if *incremental {
+		var err error
+		since, err = time.Parse(time.RFC3339, root.State("backup"))
+		if err != nil {
+			log.Fatal("no previous backup recorded; make a full backup first")
+		}
+		info = "incremental " + now.Format(time.RFC3339) + " since " + since.Format(time.RFC3339) + "\n"
+	}
+	file := *to
+	if file == "" {
+		file = "todo-backup-" + now.Format("20060102-150405") + ".tar.gz"
+	}
+
+	w, err := createCompressed(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tw := tar.NewWriter(w)
+	add := func(name string, mode os.FileMode, mtime time.Time, data []byte) error {
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    int64(mode.Perm()),
+			Size:    int64(len(data)),
+			ModTime: mtime,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	fail := func(err error) {
+		w.Close()
+		os.Remove(file)
+		log.Fatal(err)
+	}
+	if err := add("_backup/info", 0666, now, []byte(info)); err != nil {
+		fail(err)
+	}
+	var removed []string
+	for _, l := range allLists(root) {
+		keep := func(string) bool { return true }
+		if *incremental {
+			var rm []string
+			keep, rm, err = backupChanges(l, since)
+			if err != nil {
+				fail(err)
+			}
+			removed = append(removed, rm...)
+		}
+		err := l.Snapshot(func(name string, fi os.FileInfo, data []byte) error {
+			if !keep(name) {
+				return nil
+			}
+			return add(name, fi.Mode(), fi.ModTime(), data)
+		})
+		if err != nil {
+			fail(err)
+		}
+	}
+	if *incremental {
+		if err := add("_backup/removed", 0666, now, []byte(strings.Join(removed, "\n"))); err != nil {
+			fail(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		fail(err)
+	}
+	if err := w.Close(); err != nil {
+		os.Remove(file)
+		log.Fatal(err)
+	}
+	if err := root.SetState("backup", now.Format(time.RFC3339)); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(file)
+}
+
+// backupChanges reads l's journal and returns a function reporting
+// whether a file of l belongs in an incremental backup of the changes
+// since the given time, along with the tasks moved or deleted from l
+// since then.
+func backupChanges(l *task.List, since time.Time) (keep func(name string) bool, removed []string, err error) {
+	journal, err := l.Journal(since)
+	if err != nil {
+		return nil, nil, err
+	}
+	changed := make(map[string]bool)
+	archived := make(map[string]bool) // _done/2006-01/id of tasks archived since
+	for _, e := range journal {
+		changed[e.ID] = true
+		if e.Event == "delete" || e.Event == "move" && strings.HasPrefix(e.Detail, "to ") || e.Event == "archive" {
+			removed = append(removed, path.Join(l.Name(), e.ID))
+		}
+		if e.Event == "archive" {
+			archived[strings.TrimPrefix(e.Detail, "to ")] = true
+		}
+	}
+	prefix := ""
+	if l.Name() != "." {
+		prefix = l.Name() + "/"
+	}
+	keep = func(name string) bool {
+		rel := strings.TrimPrefix(name, prefix)
+		elem := strings.Split(rel, "/")
+		switch {
+		case len(elem) == 1 && (strings.HasSuffix(rel, ".todo") || strings.HasSuffix(rel, ".done")):
+			return changed[strings.TrimSuffix(rel, path.Ext(rel))]
+		case len(elem) == 1, elem[0] == "_template":
+			return true // settings, state, and journal
+		case elem[0] == "_attach":
+			return changed[elem[1]]
+		case elem[0] == "_journals" && len(elem) == 2:
+			if len(elem[1]) < len("20060102-150405") {
+				return false
+			}
+			t, err := time.ParseInLocation("20060102-150405", elem[1][:len("20060102-150405")], time.Local)
+			return err == nil && !t.Before(since.Truncate(time.Second))
+		case elem[0] == "_trash":
+			t, err := time.ParseInLocation("20060102-150405", elem[1], time.Local)
+			return err == nil && !t.Before(since.Truncate(time.Second))
+		case elem[0] == "_done" && len(elem) == 3:
+			return archived[path.Join(elem[0], elem[1], strings.TrimSuffix(elem[2], ".done"))]
+		case elem[0] == "_done" && len(elem) >= 5 && elem[2] == "_attach":
+			return archived[path.Join(elem[0], elem[1], elem[3])]
+		}
+		return false
+	}
+	return keep, removed, nil
+}
+
+func cmdRestoreBackup(args []string) {
+	fs := flag.NewFlagSet("restore-backup", flag.ExitOnError)
+	force := fs.Bool("f", false, "restore a full backup even if the todo root is not empty")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo restore-backup [-f] file...\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+	}
+	for i, file := range fs.Args() {
+		if err := restoreBackup(file, *force || i > 0); err != nil {
+			log.Fatalf("%s: %v", file, err)
+		}
+	}
+}
+
+// restoreBackup unpacks the backup file into the todo root.
+// Unless force is set, a full backup is only unpacked into an empty root.
+func restoreBackup(file string, force bool) error {
+	r, err := openCompressed(file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	tr := tar.NewReader(r)
+	root := task.Root()
+	written := make(map[string]bool)
+	for first := true; ; first = false {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		name := path.Clean(hdr.Name)
+		if first {
+			if name != "_backup/info" {
+				return fmt.Errorf("not a todo backup")
+			}
+			if strings.HasPrefix(string(data), "full ") && !force && !emptyDir(root) {
+				return fmt.Errorf("todo root %s is not empty; use -f to restore over it", root)
+			}
+			continue
+		}
+		if name == "_backup/removed" {
+			for _, ref := range strings.Fields(string(data)) {
+				ref = path.Clean(ref)
+				if strings.HasPrefix(ref, "..") || path.IsAbs(ref) {
+					continue
+				}
+				base := filepath.Join(root, filepath.FromSlash(ref))
+				for _, ext := range []string{".todo", ".done"} {
+					if !written[ref+ext] {
+						os.Remove(base + ext)
+					}
+				}
+				list, id := path.Split(ref)
+				if !written[path.Join(list, "_attach", id)] {
+					os.RemoveAll(filepath.Join(root, filepath.FromSlash(list), "_attach", id))
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(name, "..") || path.IsAbs(name) || hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			return fmt.Errorf("invalid backup entry %s", hdr.Name)
+		}
+		dst := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+			return err
+		}
+		tmp := dst + ".tmp"
+		if err := ioutil.WriteFile(tmp, data, os.FileMode(hdr.Mode).Perm()); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, dst); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		os.Chtimes(dst, hdr.ModTime, hdr.ModTime)
+		written[name] = true
+		if elem := strings.Split(name, "/"); len(elem) >= 3 && elem[len(elem)-3] == "_attach" {
+			written[path.Dir(name)] = true // the task's attachment directory
+		} else if ext := path.Ext(name); ext == ".todo" || ext == ".done" {
+			// A task changed state: remove its file in the old state.
+			other := ".done"
+			if ext == ".done" {
+				other = ".todo"
+			}
+			if !written[strings.TrimSuffix(name, ext)+other] {
+				os.Remove(strings.TrimSuffix(dst, ext) + other)
+			}
+		}
+	}
+	return nil
+}
+
+// emptyDir reports whether dir is missing or empty.
+func emptyDir(dir string) bool {
+	infos, err := ioutil.ReadDir(dir)
+	return err != nil || len(infos) == 0
+}
+
+// createCompressed creates file, returning a writer that compresses
+// what is written according to the file's extension.
+func createCompressed(file string) (io.WriteCloser, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasSuffix(file, ".tar"):
+		return f, nil
+	case strings.HasSuffix(file, ".tar.gz"), strings.HasSuffix(file, ".tgz"):
+		return &stackedWriter{gzip.NewWriter(f), f}, nil
+	case strings.HasSuffix(file, ".tar.zst"):
+		cmd := exec.Command("zstd", "-q", "-c")
+		cmd.Stdout = f
+		cmd.Stderr = os.Stderr
+		w, err := cmd.StdinPipe()
+		if err == nil {
+			err = cmd.Start()
+		}
+		if err != nil {
+			f.Close()
+			os.Remove(file)
+			return nil, fmt.Errorf("zstd: %v", err)
+		}
+		return &cmdWriter{w, cmd, f}, nil
+	}
+	f.Close()
+	os.Remove(file)
+	return nil, fmt.Errorf("unknown backup format %s: want .tar, .tar.gz, .tgz, or .tar.zst", file)
+}
+
+// openCompressed opens file, returning a reader that decompresses
+// its contents according to the file's extension.
+func openCompressed(file string) (io.ReadCloser, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasSuffix(file, ".tar"):
+		return f, nil
+	case strings.HasSuffix(file, ".tar.gz"), strings.HasSuffix(file, ".tgz"):
+		zr, err := gzip.NewReader(bufio.NewReader(f))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &stackedReader{zr, f}, nil
+	case strings.HasSuffix(file, ".tar.zst"):
+		cmd := exec.Command("zstd", "-q", "-d", "-c")
+		cmd.Stdin = f
+		cmd.Stderr = os.Stderr
+		out, err := cmd.StdoutPipe()
+		if err == nil {
+			err = cmd.Start()
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("zstd: %v", err)
+		}
+		return &cmdReader{out, cmd, f}, nil
+	}
+	f.Close()
+	return nil, fmt.Errorf("unknown backup format %s: want .tar, .tar.gz, .tgz, or .tar.zst", file)
+}
+
+// A stackedWriter is a compressing writer on top of a file.
+type stackedWriter struct {
+	io.WriteCloser
+	f *os.File
+}
+
+func (w *stackedWriter) Close() error {
+	err := w.WriteCloser.Close()
+	if err1 := w.f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+// A stackedReader is a decompressing reader on top of a file.
+type stackedReader struct {
+	io.ReadCloser
+	f *os.File
+}
+
+func (r *stackedReader) Close() error {
+	r.ReadCloser.Close()
+	return r.f.Close()
+}
+
+// A cmdWriter writes to a compression command writing to a file.
+type cmdWriter struct {
+	io.WriteCloser
+	cmd *exec.Cmd
+	f   *os.File
+}
+
+func (w *cmdWriter) Close() error {
+	err := w.WriteCloser.Close()
+	if err1 := w.cmd.Wait(); err == nil {
+		err = err1
+	}
+	if err1 := w.f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+// A cmdReader reads from a decompression command reading a file.
+type cmdReader struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+	f   *os.File
+}
+
+func (r *cmdReader) Close() error {
+	r.ReadCloser.Close()
+	r.cmd.Wait()
+	return r.f.Close()
+}
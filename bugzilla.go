@@ -0,0 +1,152 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// A bugzillaTracker mirrors the open bugs on a Bugzilla server
+// assigned to the authenticated user.
+//
+// It authenticates using the API key in $BUGZILLA_API_KEY.
+type bugzillaTracker struct {
+	host string
+	key  string
+	user string
+}
+
+func newBugzillaTracker(host string) (tracker, error) {
+	b := &bugzillaTracker{
+		host: strings.TrimSuffix(strings.TrimPrefix(host, "https://"), "/"),
+		key:  os.Getenv("BUGZILLA_API_KEY"),
+	}
+	if b.key == "" {
+		return nil, fmt.Errorf("$BUGZILLA_API_KEY not set")
+	}
+	var who struct{ Name string }
+	if _, err := trackerRequest("GET", b.api("/whoami"), b.auth, nil, &who); err != nil {
+		return nil, err
+	}
+	b.user = who.Name
+	return b, nil
+}
+
+func (b *bugzillaTracker) prefix() string {
+	return b.host + "/show_bug.cgi?id="
+}
+
+func (b *bugzillaTracker) api(path string) string {
+	return "https://" + b.host + "/rest" + path
+}
+
+func (b *bugzillaTracker) auth(req *http.Request) {
+	req.Header.Set("X-BUGZILLA-API-KEY", b.key)
+}
+
+type bugzillaBug struct {
+	ID             int
+	Summary        string
+	Status         string
+	IsOpen         bool `json:"is_open"`
+	Product        string
+	Component      string
+	Priority       string
+	Severity       string
+	CreationTime   string `json:"creation_time"`
+	LastChangeTime string `json:"last_change_time"`
+}
+
+const bugzillaFields = "id,summary,status,is_open,product,component,priority,severity,creation_time,last_change_time"
+
+func (b *bugzillaTracker) convert(bug *bugzillaBug) *remoteIssue {
+	created, _ := time.Parse(time.RFC3339, bug.CreationTime)
+	updated, _ := time.Parse(time.RFC3339, bug.LastChangeTime)
+	id := fmt.Sprint(bug.ID)
+	return &remoteIssue{
+		id:      id,
+		title:   bug.Summary,
+		url:     "https://" + b.prefix() + id,
+		created: created,
+		updated: updated,
+		closed:  !bug.IsOpen,
+		hdr: map[string]string{
+			"product":   oneLine(bug.Product),
+			"component": oneLine(bug.Component),
+			"priority":  oneLine(bug.Priority),
+			"severity":  oneLine(bug.Severity),
+		},
+	}
+}
+
+func (b *bugzillaTracker) bugs(query string) ([]*remoteIssue, error) {
+	var resp struct{ Bugs []*bugzillaBug }
+	if _, err := trackerRequest("GET", b.api("/bug?include_fields="+bugzillaFields+"&"+query), b.auth, nil, &resp); err != nil {
+		return nil, err
+	}
+	var list []*remoteIssue
+	for _, bug := range resp.Bugs {
+		list = append(list, b.convert(bug))
+	}
+	return list, nil
+}
+
+func (b *bugzillaTracker) open() ([]*remoteIssue, error) {
+	// Resolution "---" means the bug is still open.
+	return b.bugs("resolution=---&assigned_to=" + url.QueryEscape(b.user))
+}
+
+func (b *bugzillaTracker) issue(id string) (*remoteIssue, error) {
+	list, err := b.bugs("id=" + url.QueryEscape(id))
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("%s: bug %s not found", b.host, id)
+	}
+	return list[0], nil
+}
+
+func (b *bugzillaTracker) comments(ri *remoteIssue, since time.Time) ([]importComment, error) {
+	var resp struct {
+		Bugs map[string]struct {
+			Comments []struct {
+				Text         string
+				Creator      string
+				Count        int
+				CreationTime string `json:"creation_time"`
+			}
+		}
+	}
+	if _, err := trackerRequest("GET", b.api("/bug/"+ri.id+"/comment"), b.auth, nil, &resp); err != nil {
+		return nil, err
+	}
+	var list []importComment
+	for _, c := range resp.Bugs[ri.id].Comments {
+		if c.Count == 0 {
+			// Comment 0 is the bug description.
+			if ri.body == "" {
+				ri.body = c.Text
+			}
+			continue
+		}
+		tm, _ := time.Parse(time.RFC3339, c.CreationTime)
+		if tm.After(since) {
+			list = append(list, importComment{time: tm, text: c.Creator + ":\n" + c.Text})
+		}
+	}
+	return list, nil
+}
+
+func (b *bugzillaTracker) close(id string) error {
+	body := map[string]string{"status": "RESOLVED", "resolution": "FIXED"}
+	_, err := trackerRequest("PUT", b.api("/bug/"+id), b.auth, body, nil)
+	return err
+}
@@ -0,0 +1,163 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+func cmdCycletime(args []string) {
+	fs := flag.NewFlagSet("cycletime", flag.ExitOnError)
+	verbose := fs.Bool("v", false, "also print the lead and cycle time of each task")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo cycletime [-v] [query]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	q := strings.Join(fs.Args(), " ")
+	if q == "" {
+		q = "todo:done"
+	}
+	l := taskList(*dirFlag)
+	all, err := search(l, q)
+	if err != nil {
+		log.Fatal(err)
+	}
+	writeCycleTimes(os.Stdout, l, all, *verbose)
+}
+
+// A cycleTime is the lead and cycle time of a closed task.
+// The cycle time is -1 if the task has no began header.
+type cycleTime struct {
+	t     *task.Task
+	lead  time.Duration
+	cycle time.Duration
+}
+
+// taskCycleTime returns the lead and cycle time of t,
+// reporting false if t has not been closed.
+func taskCycleTime(t *task.Task) (cycleTime, bool) {
+	parse := func(s string) (time.Time, bool) {
+		tm, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.Local)
+		return tm, err == nil
+	}
+	closed, ok := parse(t.Header("closed"))
+	if !ok {
+		return cycleTime{}, false
+	}
+	created, ok := parse(t.Header("ctime"))
+	if !ok {
+		return cycleTime{}, false
+	}
+	c := cycleTime{t: t, lead: closed.Sub(created), cycle: -1}
+	began, ok := parse(t.Header("began"))
+	if !ok {
+		// Updated before began was recorded:
+		// use the first update after creation, if any,
+		// other than the one closing the task.
+		if us := t.Updates(); len(us) > 1 {
+			began, ok = parse(us[1].Time)
+			ok = ok && began.Before(closed)
+		}
+	}
+	if ok && !began.After(closed) {
+		c.cycle = closed.Sub(began)
+	}
+	return c, true
+}
+
+// writeCycleTimes writes to w the distributions of the lead and cycle
+// times of the closed tasks among tasks, and, if verbose, the times
+// of each task, longest lead time first.
+func writeCycleTimes(w io.Writer, l *task.List, tasks []*task.Task, verbose bool) {
+	var times []cycleTime
+	var lead, cycle []time.Duration
+	for _, t := range tasks {
+		c, ok := taskCycleTime(t)
+		if !ok {
+			continue
+		}
+		times = append(times, c)
+		lead = append(lead, c.lead)
+		if c.cycle >= 0 {
+			cycle = append(cycle, c.cycle)
+		}
+	}
+	if len(times) == 0 {
+		fmt.Fprintf(w, "no closed tasks\n")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "\ttasks\tmin\tmedian\tp90\tmax\n")
+	for _, row := range []struct {
+		name string
+		ds   []time.Duration
+	}{
+		{"lead", lead},
+		{"cycle", cycle},
+	} {
+		ds := row.ds
+		if len(ds) == 0 {
+			fmt.Fprintf(tw, "%s\t0\t-\t-\t-\t-\n", row.name)
+			continue
+		}
+		sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\n", row.name, len(ds),
+			fmtAge(ds[0]), fmtAge(percentile(ds, 50)), fmtAge(percentile(ds, 90)), fmtAge(ds[len(ds)-1]))
+	}
+	tw.Flush()
+
+	if !verbose {
+		return
+	}
+	sort.Slice(times, func(i, j int) bool {
+		if times[i].lead != times[j].lead {
+			return times[i].lead > times[j].lead
+		}
+		return times[i].t.ID() < times[j].t.ID()
+	})
+	fmt.Fprintf(w, "\n")
+	tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "task\tlead\tcycle\ttitle\n")
+	for _, c := range times {
+		cycle := "-"
+		if c.cycle >= 0 {
+			cycle = fmtAge(c.cycle)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", taskRef(l, c.t), fmtAge(c.lead), cycle, c.t.Title())
+	}
+	tw.Flush()
+}
+
+// percentile returns the p'th percentile of the sorted durations ds,
+// using the nearest-rank method.
+func percentile(ds []time.Duration, p int) time.Duration {
+	i := (len(ds)*p+99)/100 - 1
+	if i < 0 {
+		i = 0
+	}
+	return ds[i]
+}
+
+// fmtAge formats d in hours, for less than a day, or else in days,
+// as in 5.0h or 2.5d.
+func fmtAge(d time.Duration) string {
+	if d < 24*time.Hour {
+		return fmt.Sprintf("%.1fh", d.Hours())
+	}
+	return fmt.Sprintf("%.1fd", d.Hours()/24)
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+// dashboardTop is the number of urgent tasks shown for each list.
+const dashboardTop = 3
+
+func cmdDashboard(args []string) {
+	fs := flag.NewFlagSet("dashboard", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo dashboard\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+	}
+	var buf bytes.Buffer
+	if err := writeDashboard(&buf, taskList(*dirFlag)); err != nil {
+		log.Fatal(err)
+	}
+	page(buf.Bytes())
+}
+
+// writeDashboard writes a summary of l and each of its sublists:
+// a line giving the list name and its counts of open tasks,
+// overdue tasks, and snoozed tasks waking up today,
+// followed by the list's most urgent tasks.
+// List and task names are relative to l, so that they can be
+// looked at in an acme window for l.
+func writeDashboard(w io.Writer, l *task.List) error {
+	today := time.Now().Format("2006-01-02")
+	for _, sub := range allLists(l) {
+		open, err := sub.Search("all")
+		if err != nil {
+			return err
+		}
+		all, err := sub.All()
+		if err != nil {
+			return err
+		}
+		overdue, waking := 0, 0
+		for _, t := range open {
+			if d := t.Header("due"); d != "" && d < today {
+				overdue++
+			}
+		}
+		for _, t := range all {
+			if t.Header("todo") == "snooze "+today {
+				waking++
+			}
+		}
+
+		rel := strings.TrimPrefix(strings.TrimPrefix(sub.Name(), l.Name()), "/")
+		name := rel + "/"
+		if rel == "" {
+			name = "./"
+		}
+		fmt.Fprintf(w, "%s\t%d open\t%d overdue\t%d waking\n", name, len(open), overdue, waking)
+
+		var urgent []*task.Task
+		for _, t := range open {
+			if t.Header("due") != "" || t.Header("priority") != "" {
+				urgent = append(urgent, t)
+			}
+		}
+		sort.Slice(urgent, func(i, j int) bool { return moreUrgent(urgent[i], urgent[j]) })
+		if len(urgent) > dashboardTop {
+			urgent = urgent[:dashboardTop]
+		}
+		for _, t := range urgent {
+			note := ""
+			if d := t.Header("due"); d != "" {
+				note = " (due " + d + ")"
+			} else if p := t.Header("priority"); p != "" {
+				note = " (priority " + p + ")"
+			}
+			fmt.Fprintf(w, "\t%s\t%s%s\n", path.Join(rel, t.ID()), t.Title(), note)
+		}
+	}
+	return nil
+}
+
+// moreUrgent reports whether x is more urgent than y:
+// tasks with due dates come first, earliest first,
+// then tasks with priorities, in priority order.
+func moreUrgent(x, y *task.Task) bool {
+	dx, dy := x.Header("due"), y.Header("due")
+	if (dx == "") != (dy == "") {
+		return dx != ""
+	}
+	if dx != dy {
+		return dx < dy
+	}
+	px, py := x.Header("priority"), y.Header("priority")
+	if (px == "") != (py == "") {
+		return px != ""
+	}
+	if px != py {
+		return px < py
+	}
+	return x.ID() < y.ID()
+}
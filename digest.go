@@ -0,0 +1,224 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+func cmdDigest(args []string) {
+	fs := flag.NewFlagSet("digest", flag.ExitOnError)
+	to := fs.String("to", "", "mail the digest to `addr` instead of printing it")
+	since := fs.String("since", "24h", "report activity since `when`, a duration ago, such as 8h or 3d, or a date, such as mon or 2019-07-01")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo digest [-to addr] [-since when]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+	}
+
+	now := time.Now()
+	start, err := parseSince(*since, now)
+	if err != nil {
+		log.Fatalf("invalid -since: %v", err)
+	}
+	var body bytes.Buffer
+	if err := writeDigest(&body, taskList(*dirFlag), now, start); err != nil {
+		log.Fatal(err)
+	}
+	if *to == "" {
+		os.Stdout.Write(body.Bytes())
+		return
+	}
+	subject := "todo digest for " + now.Format("2006-01-02")
+	if err := sendMail(*to, subject, body.Bytes()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// taskRef returns the name for t in l relative to the todo root,
+// as in "work/123".
+func taskRef(l *task.List, t *task.Task) string {
+	return path.Join(l.Name(), t.ID())
+}
+
+// writeDigest writes to w a summary of the tasks in l and its sublists:
+// tasks created since start, open tasks due today or earlier,
+// open tasks with reminders set for today or since start,
+// snoozed tasks waking up today, stale tasks, and other tasks
+// updated since start.
+func writeDigest(w io.Writer, l *task.List, now, start time.Time) error {
+	today := now.Format("2006-01-02")
+	startTime := start.Format("2006-01-02 15:04:05")
+
+	type entry struct {
+		ref  string
+		t    *task.Task
+		note string
+	}
+	var created, due, remind, waking, active, stale []entry
+	for _, sub := range allLists(l) {
+		st, err := staleTasks(sub, now)
+		if err != nil {
+			return err
+		}
+		for _, s := range st {
+			stale = append(stale, entry{ref: s.ref, t: s.t, note: s.note(now)})
+		}
+		open, err := sub.All()
+		if err != nil {
+			return err
+		}
+		done, err := sub.Done()
+		if err != nil {
+			return err
+		}
+		for _, t := range append(open, done...) {
+			e := entry{ref: taskRef(sub, t), t: t}
+			switch {
+			case t.Header("ctime") >= startTime:
+				created = append(created, e)
+			case t.Header("mtime") >= startTime:
+				e.note = digestNote(t)
+				active = append(active, e)
+			}
+			if t.Done() {
+				continue
+			}
+			if d := t.Header("due"); d != "" && d <= today {
+				e.note = "due " + d
+				if d < today {
+					e.note = "overdue since " + d
+				}
+				due = append(due, e)
+			}
+			if r := t.Header("remind"); r != "" {
+				if at, ok := parseDue(r); ok && !at.Before(start) && at.Format("2006-01-02") <= today {
+					e.note = "remind " + r
+					remind = append(remind, e)
+				}
+			}
+			if t.Header("todo") == "snooze "+today {
+				waking = append(waking, e)
+			}
+		}
+	}
+
+	sections := []struct {
+		title string
+		list  []entry
+	}{
+		{"Due and overdue", due},
+		{"Reminders", remind},
+		{"Waking up today", waking},
+		{"Stale", stale},
+		{"New", created},
+		{"Updated", active},
+	}
+	empty := true
+	for _, s := range sections {
+		if len(s.list) == 0 {
+			continue
+		}
+		empty = false
+		sort.Slice(s.list, func(i, j int) bool { return s.list[i].ref < s.list[j].ref })
+		fmt.Fprintf(w, "%s (%d)\n\n", s.title, len(s.list))
+		for _, e := range s.list {
+			fmt.Fprintf(w, "\t%s\t%s\n", e.ref, e.t.Title())
+			if e.note != "" {
+				fmt.Fprintf(w, "\t\t%s\n", e.note)
+			}
+		}
+		fmt.Fprintf(w, "\n")
+	}
+	if empty {
+		fmt.Fprintf(w, "Nothing to report since %s.\n", startTime)
+	}
+	return nil
+}
+
+// digestNote summarizes the most recent update to t.
+func digestNote(t *task.Task) string {
+	updates := t.Updates()
+	if len(updates) == 0 {
+		return ""
+	}
+	u := updates[len(updates)-1]
+	var f []string
+	for k, v := range u.Header {
+		if strings.HasPrefix(k, "#") {
+			continue
+		}
+		if v == "" {
+			f = append(f, "cleared "+k)
+		} else {
+			f = append(f, k+": "+v)
+		}
+	}
+	sort.Strings(f)
+	if u.Comment != "" {
+		line := u.Comment
+		if i := strings.Index(line, "\n"); i >= 0 {
+			line = line[:i] + " ..."
+		}
+		f = append(f, "comment: "+line)
+	}
+	return strings.Join(f, "; ")
+}
+
+// sendMail sends a plain-text message to the given address.
+// If the root list's configuration has an smtp setting (host:port),
+// sendMail connects to that server, authenticating with the
+// login and password for the host in $HOME/.netrc, if any.
+// Otherwise it pipes the message to sendmail -t.
+// The mail-from setting, if present, gives the sender address.
+func sendMail(to, subject string, body []byte) error {
+	cfg := taskList(".").Config()
+	from := cfg.Get("mail-from")
+	if from == "" {
+		from = os.Getenv("USER")
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\n", from, to, subject)
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.Write(bytes.Replace(body, []byte("\n"), []byte("\r\n"), -1))
+
+	if addr := cfg.Get("smtp"); addr != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return fmt.Errorf("invalid smtp config %q: %v", addr, err)
+		}
+		var auth smtp.Auth
+		if user, pass := netrcLogin(host); user != "" {
+			auth = smtp.PlainAuth("", user, pass, host)
+		}
+		return smtp.SendMail(addr, auth, from, []string{to}, msg.Bytes())
+	}
+
+	cmd := exec.Command("sendmail", "-t")
+	cmd.Stdin = &msg
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("sendmail: %v\n%s", err, out)
+	}
+	return nil
+}
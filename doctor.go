@@ -0,0 +1,266 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"9fans.net/go/acme"
+	"9fans.net/go/plan9"
+	"9fans.net/go/plumb"
+	"rsc.io/todo/task"
+)
+
+func cmdDoctor(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	acmeCheck := fs.Bool("a", false, "also check the acme and plumber setup used by todo -a")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo doctor [-a]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+	}
+
+	d := new(doctor)
+	if !d.checkRoot() {
+		os.Exit(1)
+	}
+	d.checkFiles()
+	d.checkEditor()
+	if *acmeCheck {
+		d.checkAcme()
+	}
+	for _, l := range allLists(taskList(*dirFlag)) {
+		d.checkList(l)
+		d.checkHooks(l)
+		d.checkSync(l)
+	}
+	if d.failed {
+		os.Exit(1)
+	}
+}
+
+// A doctor checks the todo environment, printing a line for each check.
+type doctor struct {
+	failed bool
+}
+
+// ok reports a passing check.
+func (d *doctor) ok(format string, args ...interface{}) {
+	fmt.Printf("ok\t%s\n", fmt.Sprintf(format, args...))
+}
+
+// fail reports a failing check and how to fix it.
+func (d *doctor) fail(fix, format string, args ...interface{}) {
+	d.failed = true
+	fmt.Printf("FAIL\t%s\n", fmt.Sprintf(format, args...))
+	if fix != "" {
+		fmt.Printf("\tfix: %s\n", fix)
+	}
+}
+
+// checkRoot checks that the todo root exists and is writable.
+// Nothing else can be checked if it fails.
+func (d *doctor) checkRoot() bool {
+	root := task.Root()
+	info, err := os.Stat(root)
+	if err != nil {
+		d.fail("mkdir "+root+", or set $TODO_DIR to an existing directory", "todo root: %v", err)
+		return false
+	}
+	if !info.IsDir() {
+		d.fail("set $TODO_DIR to a directory", "todo root: %s is not a directory", root)
+		return false
+	}
+	f, err := ioutil.TempFile(root, ".doctor")
+	if err != nil {
+		d.fail("chmod u+w "+root, "todo root %s is not writable: %v", root, err)
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	d.ok("todo root %s", root)
+	return true
+}
+
+// checkFiles checks that the file system supports the exclusive creates
+// that reserve new task IDs and the renames that mark tasks done,
+// which some network and synced file systems do not.
+func (d *doctor) checkFiles() {
+	const fix = "keep the todo root on a local file system"
+	file := filepath.Join(task.Root(), ".doctor-lock")
+	defer os.Remove(file)
+	defer os.Remove(file + ".done")
+
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		d.fail(fix, "exclusive create: %v", err)
+		return
+	}
+	f.Close()
+	if f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666); err == nil {
+		f.Close()
+		d.fail(fix, "exclusive create: succeeded twice, so concurrent todo commands can reuse task IDs")
+		return
+	} else if !os.IsExist(err) {
+		d.fail(fix, "exclusive create: unexpected error: %v", err)
+		return
+	}
+	if err := os.Rename(file, file+".done"); err != nil {
+		d.fail(fix, "rename: %v", err)
+		return
+	}
+	d.ok("file system supports exclusive create and rename")
+}
+
+// checkEditor checks that the editor used by todo -e can be found.
+func (d *doctor) checkEditor() {
+	ed, src := editor()
+	f := strings.Fields(ed)
+	if len(f) == 0 {
+		d.fail("set $EDITOR to your editor", "editor: %s is blank", src)
+		return
+	}
+	if _, err := exec.LookPath(f[0]); err != nil {
+		d.fail("install "+f[0]+", or set $EDITOR to an installed editor", "editor %s (%s): %v", ed, src, err)
+		return
+	}
+	d.ok("editor %s (%s)", ed, src)
+}
+
+// checkAcme checks that acme is running and the plumber is reachable.
+func (d *doctor) checkAcme() {
+	if _, err := acme.Windows(); err != nil {
+		d.fail("start acme, and plumber, before running todo -a", "acme: %v", err)
+	} else {
+		d.ok("acme is running")
+	}
+	fid, err := plumb.Open("send", plan9.OWRITE)
+	if err != nil {
+		d.fail("start the plumber, and run todo plumb -install", "plumber: %v", err)
+		return
+	}
+	fid.Close()
+	d.ok("plumber is reachable")
+}
+
+// checkList checks that every task file in l can be read,
+// that each task's file name matches its state, and that
+// no two tasks claim the same external ID.
+func (d *doctor) checkList(l *task.List) {
+	dir := filepath.Join(task.Root(), l.Name())
+	todo, _ := filepath.Glob(filepath.Join(dir, "*.todo"))
+	done, _ := filepath.Glob(filepath.Join(dir, "*.done"))
+	isTodo := make(map[string]bool)
+	for _, file := range todo {
+		isTodo[strings.TrimSuffix(filepath.Base(file), ".todo")] = true
+	}
+
+	bad := 0
+	owner := make(map[string]string)
+	for _, file := range append(todo, done...) {
+		ext := filepath.Ext(file)
+		id := strings.TrimSuffix(filepath.Base(file), ext)
+		name := path.Join(l.Name(), id)
+		if ext == ".done" && isTodo[id] {
+			d.fail("merge the two files and remove one", "%s: both %s.todo and %s.done exist", name, id, id)
+			bad++
+			continue
+		}
+		t, err := l.Read(id)
+		if err != nil {
+			d.fail("fix or remove "+file, "%s: %v", name, err)
+			bad++
+			continue
+		}
+		if t.Done() != (ext == ".done") {
+			want, state := ".todo", "open"
+			if t.Done() {
+				want, state = ".done", "done"
+			}
+			d.fail("mv "+file+" "+strings.TrimSuffix(file, ext)+want, "%s: task is %s but its file is %s", name, state, filepath.Base(file))
+			bad++
+		}
+		for _, eid := range t.EIDs() {
+			if other, ok := owner[eid]; ok {
+				d.fail("remove the #id header from one of them", "%s: external ID %s also used by %s", name, eid, other)
+				bad++
+				continue
+			}
+			owner[eid] = name
+		}
+	}
+	if bad == 0 {
+		d.ok("list %s: %d tasks, %d external IDs", l.Name(), len(todo)+len(done), len(owner))
+	}
+}
+
+// checkHooks checks that the hook settings in l are well-formed
+// and name commands that can be found.
+func (d *doctor) checkHooks(l *task.List) {
+	for _, line := range l.Config().Values("hook") {
+		f := strings.Fields(line)
+		if len(f) < 2 {
+			d.fail("use hook: event target", "list %s: invalid hook %q", l.Name(), line)
+			continue
+		}
+		switch f[0] {
+		case "create", "update", "done", "*":
+		default:
+			d.fail("use event create, update, done, or *", "list %s: hook %q: unknown event %s", l.Name(), line, f[0])
+			continue
+		}
+		target := f[1]
+		if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+			if _, err := url.Parse(target); err != nil {
+				d.fail("fix the URL", "list %s: hook %q: %v", l.Name(), line, err)
+				continue
+			}
+		} else if _, err := exec.LookPath(target); err != nil {
+			d.fail("install "+target+" or fix the hook setting", "list %s: hook %q: %v", l.Name(), line, err)
+			continue
+		}
+		d.ok("list %s: hook %s", l.Name(), line)
+	}
+}
+
+// checkSync checks that the sync settings in l are well-formed
+// and that each tracker can be reached with the configured credentials.
+func (d *doctor) checkSync(l *task.List) {
+	for _, line := range l.Config().Values("sync") {
+		f := strings.Fields(line)
+		if len(f) < 2 || len(f) > 3 || len(f) == 3 && f[2] != "push" {
+			d.fail("use sync: kind arg [push]", "list %s: invalid sync %q", l.Name(), line)
+			continue
+		}
+		newTracker := trackerKinds[f[0]]
+		if newTracker == nil {
+			var kinds []string
+			for k := range trackerKinds {
+				kinds = append(kinds, k)
+			}
+			sort.Strings(kinds)
+			d.fail("use one of "+strings.Join(kinds, ", "), "list %s: sync %q: unknown tracker kind %s", l.Name(), line, f[0])
+			continue
+		}
+		if _, err := newTracker(f[1]); err != nil {
+			d.fail("check the tracker argument and credentials", "list %s: sync %q: %v", l.Name(), line, err)
+			continue
+		}
+		d.ok("list %s: sync %s", l.Name(), line)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"rsc.io/todo/task"
+)
+
+func cmdDrafts(args []string) {
+	fs := flag.NewFlagSet("drafts", flag.ExitOnError)
+	rm := fs.Bool("rm", false, "discard the named drafts")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo drafts [-rm name...]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if *rm != (fs.NArg() > 0) {
+		fs.Usage()
+	}
+	if *rm {
+		failed := false
+		for _, arg := range fs.Args() {
+			l, name := draftRef(arg)
+			if err := l.RemoveDraft(name); err != nil {
+				log.Print(err)
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	var buf bytes.Buffer
+	for _, l := range allLists(taskList(*dirFlag)) {
+		drafts, err := l.Drafts()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, d := range drafts {
+			title := d.Title
+			if title == "" {
+				title = "(untitled)"
+			}
+			fmt.Fprintf(&buf, "%s\t%s\tsaved %s\n", path.Join(l.Name(), d.Name), title, d.Mtime.Format("2006-01-02 15:04"))
+		}
+	}
+	page(buf.Bytes())
+}
+
+func cmdResume(args []string) {
+	fs := flag.NewFlagSet("resume", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo resume [name]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+	}
+
+	var l *task.List
+	var name string
+	if fs.NArg() == 1 {
+		l, name = draftRef(fs.Arg(0))
+	} else {
+		l = writableList(*dirFlag)
+		drafts, err := l.Drafts()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(drafts) == 0 {
+			log.Fatalf("no drafts in list %s", l.Name())
+		}
+		name = drafts[len(drafts)-1].Name
+	}
+	text, err := l.ReadDraft(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	editTask(l, text, nil, name)
+}
+
+// draftRef returns the list and draft name for a draft
+// as printed by todo drafts: a name, in the list selected by -d,
+// or a list and name, as in work/20190605-150405.
+func draftRef(ref string) (*task.List, string) {
+	if i := strings.LastIndex(ref, "/"); i >= 0 {
+		return writableList(ref[:i]), ref[i+1:]
+	}
+	return writableList(*dirFlag), ref
+}
@@ -8,11 +8,16 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -42,20 +47,201 @@ func taskList(dir string) *task.List {
 	return list
 }
 
-func editTask(l *task.List, original []byte, t *task.Task) {
-	updated := editText(original)
-	if bytes.Equal(original, updated) {
-		log.Print("no changes made")
-		return
+// writableList is like taskList but exits with an error
+// if the list is read-only, before any changes are attempted.
+func writableList(dir string) *task.List {
+	l := taskList(dir)
+	if l.ReadOnly() {
+		log.Fatalf("list %s is read-only; remove its readonly setting to change it", l.Name())
+	}
+	return l
+}
+
+// allLists returns l followed by all its sublists, recursively.
+func allLists(l *task.List) []*task.List {
+	lists := []*task.List{l}
+	for _, name := range l.Sublists() {
+		lists = append(lists, allLists(taskList(path.Join(l.Name(), name)))...)
 	}
+	return lists
+}
 
-	newTask, err := writeTask(l, t, updated, false)
+// snoozeValue returns the todo header value that snoozes a task
+// until the given date, as understood by task.ParseDate,
+// for a number of days if when is a bare number, as in Snooze 3,
+// or for one day if when is empty.
+func snoozeValue(when string) (string, error) {
+	if when == "" {
+		when = "tomorrow"
+	}
+	if _, err := strconv.Atoi(when); err == nil && when[0] >= '0' && when[0] <= '9' {
+		when = "+" + when
+	}
+	t, err := task.ParseDate(when, time.Now())
 	if err != nil {
+		return "", err
+	}
+	return "snooze " + t.Format("2006-01-02"), nil
+}
+
+// editTask edits the task t in l in the system editor, starting from
+// the text original, or, if t is nil, creates a new task from the edited text.
+// The text of a new task is kept as a draft in l until the task is created,
+// so that it can be recovered with todo resume; draft names the draft
+// to continue, or is empty to start a new one.
+func editTask(l *task.List, original []byte, t *task.Task, draft string) {
+	resumed := draft != ""
+	if t == nil && draft == "" {
+		var err error
+		if draft, err = l.NewDraft(time.Now(), original); err != nil {
+			log.Fatal(err)
+		}
+	}
+	recovery := ""
+	save := func(data []byte) string {
+		if draft != "" {
+			if err := l.WriteDraft(draft, data); err != nil {
+				log.Fatal(err)
+			}
+			return fmt.Sprintf("edit saved as draft %s; use todo resume %s to continue", draft, draft)
+		}
+		recovery = saveRecovery(data, recovery)
+		return "edit saved in " + recovery
+	}
+
+	text := original
+	annotated := false
+	var updated []byte
+	for {
+		if draft != "" {
+			updated = editDraft(l, draft, text)
+		} else {
+			updated = editText(text)
+		}
+		if annotated {
+			updated = stripEditErrors(updated)
+		}
+		if !resumed && bytes.Equal(original, updated) {
+			if draft != "" {
+				l.RemoveDraft(draft)
+			}
+			log.Print("no changes made")
+			return
+		}
+		_, _, err := parseEdit(t, string(updated))
+		if err == nil {
+			break
+		}
+		log.Printf("%v\n%s", err, save(updated))
+		if !isTerminal(os.Stdin) || !confirm("edit again?") {
+			log.Fatal("edit not applied")
+		}
+		text, annotated = annotateEdit(updated), true
+	}
+	if recovery != "" {
+		defer os.Remove(recovery)
+	}
+
+	if t != nil {
+		summary, err := editChanges(t, updated)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if summary == "" {
+			log.Print("no changes made")
+			return
+		}
+		fmt.Fprintf(os.Stderr, "edit will update %s:\n%s", taskRef(l, t), summary)
+		// Ask before applying the edit, unless there is no one
+		// at a terminal to ask, as when run from acme or a script.
+		if !*yesFlag && isTerminal(os.Stdin) && !confirm("apply edit?") {
+			log.Fatal("edit not applied")
+		}
+	}
+
+	if _, err := writeTask(l, t, updated); err != nil {
+		log.Fatalf("%v\n%s", err, save(updated))
+	}
+	if draft != "" {
+		if err := l.RemoveDraft(draft); err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+// editDraft saves text as the named draft in l, edits it
+// in the system editor, and returns the edited text.
+// The draft keeps the edited text until it is removed.
+func editDraft(l *task.List, draft string, text []byte) []byte {
+	if err := l.WriteDraft(draft, text); err != nil {
 		log.Fatal(err)
 	}
-	if newTask != nil {
-		t = newTask
+	if err := runEditor(l.DraftFile(draft)); err != nil {
+		log.Fatalf("%v\ndraft saved as %s", err, draft)
 	}
+	updated, err := l.ReadDraft(draft)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return updated
+}
+
+// editErrorLine is the line that annotateEdit adds to explain
+// an error; stripEditErrors removes it again.
+const editErrorLine = "#! error: unknown summary line; want key: value, or a blank line before text"
+
+// annotateEdit returns the edited text of a task with an error line
+// before each of the header lines that parseEdit rejects.
+func annotateEdit(data []byte) []byte {
+	var buf bytes.Buffer
+	inHeader := true
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			inHeader = false
+		}
+		if inHeader && !strings.Contains(line, ":") {
+			buf.WriteString(editErrorLine + "\n")
+		}
+		buf.WriteString(line)
+	}
+	return buf.Bytes()
+}
+
+// stripEditErrors removes the lines added by annotateEdit,
+// which are in the headers, leaving any other lines alone,
+// even ones that look the same in the text below the headers.
+func stripEditErrors(data []byte) []byte {
+	var buf bytes.Buffer
+	inHeader := true
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			inHeader = false
+		}
+		if inHeader && strings.TrimRight(line, "\r\n") == editErrorLine {
+			continue
+		}
+		buf.WriteString(line)
+	}
+	return buf.Bytes()
+}
+
+// saveRecovery saves the text of an edit that could not be applied,
+// so that it is not lost, and returns the name of the file holding it.
+// If file is not empty, saveRecovery overwrites it rather than
+// creating a new file.
+func saveRecovery(data []byte, file string) string {
+	if file == "" {
+		f, err := ioutil.TempFile("", "todo-recover-")
+		if err != nil {
+			log.Fatal(err)
+		}
+		file = f.Name()
+		f.Close()
+	}
+	if err := ioutil.WriteFile(file, data, 0600); err != nil {
+		log.Fatal(err)
+	}
+	return file
 }
 
 func editText(original []byte) []byte {
@@ -79,14 +265,24 @@ func editText(original []byte) []byte {
 	return updated
 }
 
-func runEditor(filename string) error {
-	ed := os.Getenv("VISUAL")
+// editor returns the editor command used by todo -e and where it came from:
+// $VISUAL, or else $EDITOR, or else ed (notepad on Windows).
+func editor() (ed, src string) {
+	ed, src = os.Getenv("VISUAL"), "$VISUAL"
 	if ed == "" {
-		ed = os.Getenv("EDITOR")
+		ed, src = os.Getenv("EDITOR"), "$EDITOR"
 	}
 	if ed == "" {
-		ed = "ed"
+		ed, src = "ed", "default"
+		if runtime.GOOS == "windows" {
+			ed = "notepad"
+		}
 	}
+	return ed, src
+}
+
+func runEditor(filename string) error {
+	ed, _ := editor()
 
 	// If the editor contains spaces or other magic shell chars,
 	// invoke it as a shell command. This lets people have
@@ -95,7 +291,7 @@ func runEditor(filename string) error {
 	// sh -c this way is taken from git/run-command.c.
 	var cmd *exec.Cmd
 	if strings.ContainsAny(ed, "|&;<>()$`\\\"' \t\n*?[#~=%") {
-		cmd = exec.Command("sh", "-c", ed+` "$@"`, "$EDITOR", filename)
+		cmd = shellCommand(ed, filename)
 	} else {
 		cmd = exec.Command(ed, filename)
 	}
@@ -111,17 +307,12 @@ func runEditor(filename string) error {
 
 const bulkHeader = "\n— Bulk editing these tasks:"
 
-func writeTask(l *task.List, old *task.Task, updated []byte, isBulk bool) (t *task.Task, err error) {
+// parseEdit parses the headers at the start of sdata, the edited text
+// of a task, returning those that differ from old's (all of them if old
+// is nil) and the offset of the text following the headers.
+func parseEdit(old *task.Task, sdata string) (hdr map[string]string, off int, err error) {
 	var errbuf bytes.Buffer
-	defer func() {
-		if errbuf.Len() > 0 {
-			err = errors.New(strings.TrimSpace(errbuf.String()))
-		}
-	}()
-
-	sdata := string(updated)
-	hdr := make(map[string]string)
-	off := 0
+	hdr = make(map[string]string)
 	for _, line := range strings.SplitAfter(sdata, "\n") {
 		off += len(line)
 		line = strings.TrimSpace(line)
@@ -139,41 +330,107 @@ func writeTask(l *task.List, old *task.Task, updated []byte, isBulk bool) (t *ta
 			hdr[k] = v
 		}
 	}
-
 	if errbuf.Len() > 0 {
-		return nil, nil
-	}
-
-	if old == nil && isBulk {
-		// Asking to just sanity check the text parsing.
-		return nil, nil
-	}
-
-	if old == nil {
-		body := strings.TrimSpace(sdata[off:])
-		t, err := l.Create(hdr["id"], time.Now(), hdr, []byte(body))
-		if err != nil {
-			fmt.Fprintf(&errbuf, "error creating task: %v\n", err)
-			return nil, nil
-		}
-		return t, nil
+		return nil, 0, errors.New(strings.TrimSpace(errbuf.String()))
 	}
+	return hdr, off, nil
+}
 
+// editComment returns the comment in sdata, the edited text of a task,
+// which is the text between the headers, ending at off, and the first
+// update marker or bulk edit task list.
+func editComment(sdata string, off int) string {
 	marker := "\n— "
 	var comment string
 	if i := strings.Index(sdata, marker); i >= off {
 		comment = strings.TrimSpace(sdata[off:i])
 	}
-
 	if comment == "<optional comment here>" {
 		comment = ""
 	}
+	return comment
+}
+
+// editChanges summarizes the changes that writeTask would make
+// to old given its edited text updated: a line for each header
+// it would set, change, or remove, a line for each header whose line
+// was deleted but which the edit would keep, and a line for the comment
+// it would append, if any. It returns "" if the edit changes nothing.
+func editChanges(old *task.Task, updated []byte) (string, error) {
+	sdata := string(updated)
+	hdr, off, err := parseEdit(old, sdata)
+	if err != nil {
+		return "", err
+	}
+	var keys []string
+	for k := range hdr {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, k := range keys {
+		printHeaderChange(&buf, "\t", k, old.Header(k), hdr[k])
+	}
+
+	// Deleting a header line leaves the header unchanged,
+	// which surprises people expecting it to remove the header.
+	listed := make(map[string]bool)
+	for _, line := range strings.Split(sdata[:off], "\n") {
+		if i := strings.Index(line, ":"); i >= 0 {
+			listed[strings.TrimSpace(strings.ToLower(line[:i]))] = true
+		}
+	}
+	for _, k := range old.Keys() {
+		if !listed[k] && old.Header(k) != "" {
+			fmt.Fprintf(&buf, "\t%s: %s (kept; to remove a header, leave its value empty)\n", k, old.Header(k))
+		}
+	}
+	comment := editComment(sdata, off)
+	if comment != "" {
+		n := strings.Count(comment, "\n") + 1
+		fmt.Fprintf(&buf, "\tappend %d-line comment\n", n)
+	}
+	if len(hdr) == 0 && comment == "" {
+		return "", nil
+	}
+	return buf.String(), nil
+}
+
+// printHeaderChange prints to w, after prefix, a line describing
+// the change of the header k from old to v, if they differ.
+func printHeaderChange(w io.Writer, prefix, k, old, v string) {
+	switch {
+	case old == v:
+	case old == "":
+		fmt.Fprintf(w, "%s%s: %s (new)\n", prefix, k, v)
+	case v == "":
+		fmt.Fprintf(w, "%s%s: %s (removed)\n", prefix, k, old)
+	default:
+		fmt.Fprintf(w, "%s%s: %s -> %s\n", prefix, k, old, v)
+	}
+}
 
-	err = l.Write(old, time.Now(), hdr, []byte(comment))
+func writeTask(l *task.List, old *task.Task, updated []byte) (*task.Task, error) {
+	sdata := string(updated)
+	hdr, off, err := parseEdit(old, sdata)
 	if err != nil {
-		fmt.Fprintf(&errbuf, "error updating task: %v\n", err)
+		return nil, err
 	}
 
+	if old == nil {
+		body := strings.TrimSpace(sdata[off:])
+		t, err := l.Create(hdr["id"], time.Now(), hdr, []byte(body))
+		if err != nil {
+			return nil, fmt.Errorf("error creating task: %w", err)
+		}
+		return t, nil
+	}
+
+	comment := editComment(sdata, off)
+	if err := l.Write(old, time.Now(), hdr, []byte(comment)); err != nil {
+		return old, fmt.Errorf("error updating task: %w", err)
+	}
 	return old, nil
 }
 
@@ -223,22 +480,59 @@ func suffix(n int) string {
 	return "s"
 }
 
+// bulkEditTasks edits tasks together in the system editor.
+// As in editTask, an edit that cannot be applied is kept in a recovery
+// file and, on a terminal, can be edited again with its errors noted.
 func bulkEditTasks(l *task.List, tasks []*task.Task) {
 	base, original := bulkEditStart(tasks)
-	updated := editText(original)
-	if bytes.Equal(original, updated) {
+	text := original
+	annotated := false
+	recovery := ""
+	var updated []byte
+	var summary string
+	var n int
+	for {
+		updated = editText(text)
+		if annotated {
+			updated = stripEditErrors(updated)
+		}
+		if bytes.Equal(original, updated) {
+			log.Print("no changes made")
+			return
+		}
+		var err error
+		summary, n, err = bulkChanges(l, base, updated)
+		if err == nil {
+			break
+		}
+		recovery = saveRecovery(updated, recovery)
+		log.Printf("%v\nedit saved in %s", err, recovery)
+		if !isTerminal(os.Stdin) || !confirm("edit again?") {
+			log.Fatal("bulk edit not applied")
+		}
+		text, annotated = annotateEdit(updated), true
+	}
+	if recovery != "" {
+		defer os.Remove(recovery)
+	}
+	if n == 0 {
 		log.Print("no changes made")
 		return
 	}
+	fmt.Fprintf(os.Stderr, "bulk edit will update %d task%s:\n%s", n, suffix(n), summary)
+	if !*yesFlag && !confirm(fmt.Sprintf("update %d task%s?", n, suffix(n))) {
+		log.Fatal("bulk edit not applied")
+	}
 	ids, err := bulkWriteTask(l, base, updated, func(s string) { log.Print(s) })
 	if err != nil {
 		errText := strings.Replace(err.Error(), "\n", "\t\n", -1)
+		recovery = saveRecovery(updated, recovery)
 		if len(ids) > 0 {
-			log.Fatal("updated %d issue%s with errors:\n\t%v", len(ids), suffix(len(ids)), errText)
+			log.Fatalf("updated %d task%s with errors:\n\t%v\nedit saved in %s", len(ids), suffix(len(ids)), errText, recovery)
 		}
-		log.Fatal(errText)
+		log.Fatalf("%s\nedit saved in %s", errText, recovery)
 	}
-	log.Printf("updated %d task%s", len(ids), suffix)
+	log.Printf("updated %d task%s", len(ids), suffix(len(ids)))
 }
 
 func bulkEditStart(tasks []*task.Task) (*task.Task, []byte) {
@@ -252,6 +546,54 @@ func bulkEditStart(tasks []*task.Task) (*task.Task, []byte) {
 	return c, buf.Bytes()
 }
 
+// bulkChanges summarizes the changes that applying the bulk edit
+// updated would make: a line for each header each listed task
+// would change, and a line counting the tasks that would get the
+// comment, if any. It also returns the number of tasks that would change.
+func bulkChanges(l *task.List, base *task.Task, updated []byte) (summary string, n int, err error) {
+	i := bytes.Index(updated, []byte(bulkHeader))
+	if i < 0 {
+		return "", 0, fmt.Errorf("cannot find bulk edit issue list")
+	}
+	ids := readBulkIDs(l, updated[i:])
+	if len(ids) == 0 {
+		return "", 0, fmt.Errorf("found no todos in bulk edit issue list")
+	}
+	sdata := string(updated)
+	hdr, off, err := parseEdit(base, sdata)
+	if err != nil {
+		return "", 0, err
+	}
+	comment := editComment(sdata, off)
+	var keys []string
+	for k := range hdr {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, id := range ids {
+		t, err := l.Read(id)
+		if err != nil {
+			return "", 0, err
+		}
+		changed := comment != ""
+		for _, k := range keys {
+			if t.Header(k) != hdr[k] {
+				printHeaderChange(&buf, "\t"+id+"\t", k, t.Header(k), hdr[k])
+				changed = true
+			}
+		}
+		if changed {
+			n++
+		}
+	}
+	if comment != "" {
+		fmt.Fprintf(&buf, "\tcomment added to %d task%s\n", len(ids), suffix(len(ids)))
+	}
+	return buf.String(), n, nil
+}
+
 func bulkWriteTask(l *task.List, base *task.Task, updated []byte, status func(string)) (ids []string, err error) {
 	i := bytes.Index(updated, []byte(bulkHeader))
 	if i < 0 {
@@ -262,34 +604,25 @@ func bulkWriteTask(l *task.List, base *task.Task, updated []byte, status func(st
 		return nil, fmt.Errorf("found no todos in bulk edit issue list")
 	}
 
-	// Check for formatting only.
-	_, err = writeTask(l, nil, updated, true)
+	sdata := string(updated)
+	hdr, off, err := parseEdit(base, sdata)
 	if err != nil {
 		return nil, err
 	}
+	comment := editComment(sdata, off)
 
-	// Apply to all issues in list.
-	suffix := ""
-	if len(ids) != 1 {
-		suffix = "s"
-	}
-	status(fmt.Sprintf("updating %d task%s", len(ids), suffix))
-
-	failed := false
-	for _, id := range ids {
-		t, err := l.Read(id)
-		if err == nil {
-			_, err = writeTask(l, t, updated, true)
-		}
-		if err != nil {
-			failed = true
-			status(fmt.Sprintf("writing %s: %v", id, strings.Replace(err.Error(), "\n", "\n\t", -1)))
+	// Apply to all issues in list, all or nothing.
+	status(fmt.Sprintf("updating %d task%s", len(ids), suffix(len(ids))))
+	results, err := l.BulkWrite(ids, time.Now(), hdr, []byte(comment))
+	ids = nil
+	for _, r := range results {
+		if r.Err != nil {
+			status(fmt.Sprintf("writing %s: %v", r.ID, strings.Replace(r.Err.Error(), "\n", "\n\t", -1)))
 			continue
 		}
+		if r.Changed {
+			ids = append(ids, r.ID)
+		}
 	}
-
-	if failed {
-		return ids, fmt.Errorf("failed to update all tasks")
-	}
-	return ids, nil
+	return ids, err
 }
@@ -0,0 +1,467 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+
+	"rsc.io/todo/task"
+)
+
+func cmdEval(args []string) {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo eval 'expr' [query]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+	}
+	r, err := parseEval(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("eval: %v", err)
+	}
+	q := strings.Join(fs.Args()[1:], " ")
+	if q == "" {
+		q = "all"
+	}
+	l := taskList(*dirFlag)
+	all, err := search(l, q)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sort.Sort(tasksByTitle(all))
+	if err := r.write(os.Stdout, l, all); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// An evalReport is a parsed todo eval expression:
+// a list of columns, optionally grouped by a header.
+type evalReport struct {
+	cols []*evalColumn
+	by   string // header to group by, or ""
+}
+
+// An evalColumn is one comma-separated column of an evalReport.
+// If agg is empty, the column is evaluated for each task;
+// otherwise it is an aggregate (count, sum, avg, min, or max)
+// over the tasks, and x may be nil for a bare count.
+type evalColumn struct {
+	name string
+	agg  string
+	x    evalExpr
+}
+
+// evalAggs lists the aggregate functions allowed in eval expressions.
+var evalAggs = map[string]bool{
+	"count": true,
+	"sum":   true,
+	"avg":   true,
+	"min":   true,
+	"max":   true,
+}
+
+// An evalExpr is an arithmetic expression over a task's headers.
+// Evaluating it reports false if the expression is undefined for the task,
+// because a header it uses is missing or not a number.
+type evalExpr interface {
+	eval(t *task.Task) (float64, bool)
+}
+
+type (
+	evalNum    float64
+	evalHeader string
+	evalNeg    struct{ x evalExpr }
+	evalBinary struct {
+		op   byte
+		x, y evalExpr
+	}
+)
+
+func (x evalNum) eval(*task.Task) (float64, bool) { return float64(x), true }
+
+func (x evalHeader) eval(t *task.Task) (float64, bool) {
+	return evalValue(t.Header(string(x)))
+}
+
+func (x *evalNeg) eval(t *task.Task) (float64, bool) {
+	v, ok := x.x.eval(t)
+	return -v, ok
+}
+
+func (x *evalBinary) eval(t *task.Task) (float64, bool) {
+	v, ok1 := x.x.eval(t)
+	w, ok2 := x.y.eval(t)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	switch x.op {
+	case '+':
+		return v + w, true
+	case '-':
+		return v - w, true
+	case '*':
+		return v * w, true
+	case '/':
+		if w == 0 {
+			return 0, false
+		}
+		return v / w, true
+	}
+	panic("eval: unknown operator " + string(x.op))
+}
+
+// evalValue converts a header value to a number.
+// Durations, such as 90m or 2d, are converted to hours.
+func evalValue(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v, true
+	}
+	if d, err := task.ParseLead(s); err == nil {
+		return d.Hours(), true
+	}
+	return 0, false
+}
+
+// parseEval parses a todo eval expression, which has the form
+//
+//	column, column... [by header]
+//
+// where each column is an arithmetic expression using numbers, header names,
+// + - * / and parentheses, or an aggregate count, count(x), sum(x), avg(x),
+// min(x), or max(x). Either all columns must be aggregates or none may be.
+func parseEval(s string) (*evalReport, error) {
+	p := &evalParser{toks: evalTokens(s)}
+	r := new(evalReport)
+	for {
+		start := p.pos
+		c, err := p.column()
+		if err != nil {
+			return nil, err
+		}
+		c.name = strings.Join(p.toks[start:p.pos], "")
+		r.cols = append(r.cols, c)
+		if p.peek() != "," {
+			break
+		}
+		p.next()
+	}
+	if p.peek() == "by" {
+		p.next()
+		r.by = strings.ToLower(p.next())
+		if !isEvalIdent(r.by) {
+			return nil, fmt.Errorf("expected header name after by")
+		}
+	}
+	if p.peek() != "" {
+		return nil, fmt.Errorf("unexpected %s", p.peek())
+	}
+	for _, c := range r.cols {
+		if (c.agg == "") != (r.cols[0].agg == "") {
+			return nil, fmt.Errorf("cannot mix aggregates and per-task values")
+		}
+	}
+	if r.by != "" && r.cols[0].agg == "" {
+		return nil, fmt.Errorf("by requires aggregates such as count or sum(x)")
+	}
+	return r, nil
+}
+
+// evalTokens splits s into tokens: identifiers, which may contain
+// dashes as header names do, numbers with optional unit suffixes,
+// and single-character operators.
+func evalTokens(s string) []string {
+	var toks []string
+	for s != "" {
+		r := rune(s[0])
+		n := 1
+		switch {
+		case unicode.IsSpace(r):
+			s = s[1:]
+			continue
+		case unicode.IsLetter(r) || r == '_' || r == '#':
+			for n < len(s) && (isEvalIdentByte(s[n]) || s[n] == '-' && n+1 < len(s) && isEvalIdentByte(s[n+1])) {
+				n++
+			}
+		case '0' <= r && r <= '9' || r == '.':
+			for n < len(s) && (s[n] == '.' || '0' <= s[n] && s[n] <= '9' || 'a' <= s[n] && s[n] <= 'z') {
+				n++
+			}
+		}
+		toks = append(toks, s[:n])
+		s = s[n:]
+	}
+	return toks
+}
+
+func isEvalIdentByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c >= 0x80
+}
+
+func isEvalIdent(s string) bool {
+	return s != "" && (unicode.IsLetter(rune(s[0])) || s[0] == '_' || s[0] == '#' || s[0] >= 0x80)
+}
+
+// An evalParser is a recursive descent parser for eval expressions.
+type evalParser struct {
+	toks []string
+	pos  int
+}
+
+func (p *evalParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *evalParser) next() string {
+	t := p.peek()
+	if t != "" {
+		p.pos++
+	}
+	return t
+}
+
+func (p *evalParser) expect(tok string) error {
+	if t := p.next(); t != tok {
+		if t == "" {
+			t = "end of expression"
+		}
+		return fmt.Errorf("expected %s, found %s", tok, t)
+	}
+	return nil
+}
+
+func (p *evalParser) column() (*evalColumn, error) {
+	name := strings.ToLower(p.peek())
+	if evalAggs[name] {
+		p.next()
+		if name == "count" && p.peek() != "(" {
+			return &evalColumn{agg: name}, nil
+		}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return &evalColumn{agg: name, x: x}, nil
+	}
+	x, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return &evalColumn{x: x}, nil
+}
+
+// expr parses a sum of terms.
+func (p *evalParser) expr() (evalExpr, error) {
+	x, err := p.term()
+	for err == nil && (p.peek() == "+" || p.peek() == "-") {
+		op := p.next()[0]
+		var y evalExpr
+		y, err = p.term()
+		x = &evalBinary{op, x, y}
+	}
+	return x, err
+}
+
+// term parses a product of factors.
+func (p *evalParser) term() (evalExpr, error) {
+	x, err := p.factor()
+	for err == nil && (p.peek() == "*" || p.peek() == "/") {
+		op := p.next()[0]
+		var y evalExpr
+		y, err = p.factor()
+		x = &evalBinary{op, x, y}
+	}
+	return x, err
+}
+
+// factor parses a number, header name, negation, or parenthesized expression.
+func (p *evalParser) factor() (evalExpr, error) {
+	t := p.next()
+	switch {
+	case t == "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case t == "-":
+		x, err := p.factor()
+		return &evalNeg{x}, err
+	case t == "(":
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	case evalAggs[strings.ToLower(t)] && p.peek() == "(":
+		return nil, fmt.Errorf("%s must be used at the top level of a column", t)
+	case isEvalIdent(t):
+		return evalHeader(strings.ToLower(t)), nil
+	case '0' <= t[0] && t[0] <= '9' || t[0] == '.':
+		v, ok := evalValue(t)
+		if !ok {
+			return nil, fmt.Errorf("invalid number %s", t)
+		}
+		return evalNum(v), nil
+	}
+	return nil, fmt.Errorf("unexpected %s", t)
+}
+
+// An evalAcc accumulates the values of an aggregate column.
+type evalAcc struct {
+	n             int
+	sum, min, max float64
+}
+
+func (a *evalAcc) add(v float64) {
+	if a.n == 0 || v < a.min {
+		a.min = v
+	}
+	if a.n == 0 || v > a.max {
+		a.max = v
+	}
+	a.n++
+	a.sum += v
+}
+
+func (a *evalAcc) result(agg string) string {
+	switch agg {
+	case "count":
+		return strconv.Itoa(a.n)
+	case "sum":
+		return formatEval(a.sum)
+	}
+	if a.n == 0 {
+		return "-"
+	}
+	switch agg {
+	case "avg":
+		return formatEval(a.sum / float64(a.n))
+	case "min":
+		return formatEval(a.min)
+	case "max":
+		return formatEval(a.max)
+	}
+	panic("eval: unknown aggregate " + agg)
+}
+
+// formatEval formats a result, rounded to two decimal places.
+func formatEval(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// write evaluates the report over tasks and writes the result as a table.
+// A report without aggregates has one row per task. Otherwise it has
+// one row for each value of the by header, or a single row without by.
+// Grouping by tag counts each task under each of its tags.
+func (r *evalReport) write(w io.Writer, l *task.List, tasks []*task.Task) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	var names []string
+	for _, c := range r.cols {
+		names = append(names, c.name)
+	}
+	if r.cols[0].agg == "" {
+		fmt.Fprintf(tw, "id\t%s\n", strings.Join(names, "\t"))
+		for _, t := range tasks {
+			row := []string{taskRef(l, t)}
+			for _, c := range r.cols {
+				s := "-"
+				if v, ok := c.x.eval(t); ok {
+					s = formatEval(v)
+				}
+				row = append(row, s)
+			}
+			fmt.Fprintf(tw, "%s\n", strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+
+	groups := make(map[string][]*evalAcc)
+	var keys []string
+	for _, t := range tasks {
+		var tkeys []string
+		switch {
+		case r.by == "":
+			tkeys = []string{""}
+		case r.by == "tag":
+			tkeys = strings.FieldsFunc(t.Header("tag"), isTagSep)
+		default:
+			tkeys = []string{t.Header(r.by)}
+		}
+		if len(tkeys) == 0 {
+			tkeys = []string{""}
+		}
+		for _, k := range tkeys {
+			accs := groups[k]
+			if accs == nil {
+				accs = make([]*evalAcc, len(r.cols))
+				for i := range accs {
+					accs[i] = new(evalAcc)
+				}
+				groups[k] = accs
+				keys = append(keys, k)
+			}
+			for i, c := range r.cols {
+				if c.x == nil {
+					accs[i].add(0)
+					continue
+				}
+				if v, ok := c.x.eval(t); ok {
+					accs[i].add(v)
+				}
+			}
+		}
+	}
+	if r.by == "" && len(keys) == 0 {
+		keys = []string{""}
+		groups[""] = make([]*evalAcc, len(r.cols))
+		for i := range groups[""] {
+			groups[""][i] = new(evalAcc)
+		}
+	}
+	sort.Strings(keys)
+
+	if r.by != "" {
+		fmt.Fprintf(tw, "%s\t", r.by)
+	}
+	fmt.Fprintf(tw, "%s\n", strings.Join(names, "\t"))
+	for _, k := range keys {
+		var row []string
+		if r.by != "" {
+			label := k
+			if label == "" {
+				label = "(none)"
+			}
+			row = append(row, label)
+		}
+		for i, c := range r.cols {
+			row = append(row, groups[k][i].result(c.agg))
+		}
+		fmt.Fprintf(tw, "%s\n", strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
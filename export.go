@@ -0,0 +1,95 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"rsc.io/todo/task"
+)
+
+// exportOptions holds the settings for an export.
+type exportOptions struct {
+	list  *task.List
+	query string
+	group string                // header to group tasks by, if the format supports it
+	refs  map[*task.Task]string // task names relative to list
+}
+
+// ref returns the name of t relative to opt.list,
+// such as 123 or, for a task in a sublist, infra/123.
+func (opt *exportOptions) ref(t *task.Task) string {
+	if r, ok := opt.refs[t]; ok {
+		return r
+	}
+	return t.ID()
+}
+
+// exportFormats maps the names accepted by todo export -format
+// to the functions that write them.
+var exportFormats = map[string]func(io.Writer, []*task.Task, *exportOptions) error{
+	"md":      writeMarkdown,
+	"todotxt": writeTodoTxt,
+}
+
+func cmdExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "todotxt", "output `format` (md, todotxt)")
+	group := fs.String("group", "", "group tasks by `header` (md only)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo export [-format f] [-group header] [query]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+
+	write := exportFormats[*format]
+	if write == nil {
+		log.Fatalf("unknown export format %q", *format)
+	}
+	q := strings.Join(fs.Args(), " ")
+	l := taskList(*dirFlag)
+	opt := &exportOptions{list: l, query: q, group: strings.ToLower(*group), refs: make(map[*task.Task]string)}
+	if q == "" {
+		opt.query = "all tasks"
+	}
+	var all []*task.Task
+	for _, sub := range allLists(l) {
+		var tasks []*task.Task
+		if q == "" {
+			open, err := sub.All()
+			if err != nil {
+				log.Fatal(err)
+			}
+			done, err := sub.Done()
+			if err != nil {
+				log.Fatal(err)
+			}
+			tasks = append(open, done...)
+		} else {
+			var err error
+			tasks, err = search(sub, q)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(sub.Name(), l.Name()), "/")
+		for _, t := range tasks {
+			opt.refs[t] = path.Join(rel, t.ID())
+		}
+		all = append(all, tasks...)
+	}
+	sort.Sort(tasksByTitle(all))
+	if err := write(os.Stdout, all, opt); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+// feedSize is the maximum number of entries in an Atom feed.
+const feedSize = 50
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Content string   `xml:"content"`
+}
+
+// serveFeed serves an Atom feed of the recent updates to tasks in l:
+// new tasks, comments, and header changes such as marking a task done.
+// The list's web page is at base.
+func (s *webServer) serveFeed(w http.ResponseWriter, r *http.Request, l *task.List, base string) {
+	open, err := l.AllContext(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	done, err := l.DoneContext(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type update struct {
+		t *task.Task
+		u *task.Update
+		n int // index in task history
+	}
+	var all []update
+	for _, t := range append(open, done...) {
+		for i, u := range t.Updates() {
+			all = append(all, update{t, u, i})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].u.Time != all[j].u.Time {
+			return all[i].u.Time > all[j].u.Time
+		}
+		return all[i].t.ID() < all[j].t.ID()
+	})
+	if len(all) > feedSize {
+		all = all[:feedSize]
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	site := scheme + "://" + r.Host + base
+	feed := &atomFeed{
+		ID:     site,
+		Title:  "todo " + base,
+		Link:   atomLink{site},
+		Author: atomAuthor{os.Getenv("USER")},
+	}
+	if feed.Author.Name == "" {
+		feed.Author.Name = "todo"
+	}
+	for _, x := range all {
+		tm, err := time.ParseInLocation("2006-01-02 15:04:05", x.u.Time, time.Local)
+		if err != nil {
+			continue
+		}
+		what := "updated"
+		switch {
+		case x.n == 0:
+			what = "created"
+		case x.u.Header["todo"] != "":
+			what = "todo: " + x.u.Header["todo"]
+		case x.u.Comment != "":
+			what = "comment"
+		}
+		var content strings.Builder
+		var keys []string
+		for k := range x.u.Header {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&content, "%s: %s\n", k, x.u.Header[k])
+		}
+		if x.u.Comment != "" {
+			fmt.Fprintf(&content, "\n%s\n", x.u.Comment)
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      fmt.Sprintf("%s%s#%d", site, x.t.ID(), x.n),
+			Title:   fmt.Sprintf("%s: %s (%s)", x.t.ID(), x.t.Title(), what),
+			Updated: tm.Format(time.RFC3339),
+			Link:    atomLink{site + x.t.ID()},
+			Content: content.String(),
+		})
+	}
+	if len(feed.Entries) > 0 {
+		feed.Updated = feed.Entries[0].Updated
+	} else {
+		feed.Updated = time.Now().Format(time.RFC3339)
+	}
+
+	data, err := xml.MarshalIndent(feed, "", "\t")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
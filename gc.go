@@ -0,0 +1,53 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+func cmdGC(args []string) {
+	fs := flag.NewFlagSet("gc", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo gc\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+	}
+	now := time.Now()
+	failed := false
+	for _, l := range allLists(taskList(*dirFlag)) {
+		age := l.Setting("archive")
+		if age == "" || l.ReadOnly() {
+			continue
+		}
+		d, err := task.ParseLead(age)
+		if err != nil {
+			log.Printf("list %s: invalid archive setting: %v", l.Name(), err)
+			failed = true
+			continue
+		}
+		n, err := l.ArchiveDone(now.Add(-d))
+		if err != nil {
+			log.Printf("list %s: %v", l.Name(), err)
+			failed = true
+		}
+		if n > 0 {
+			fmt.Printf("%s\t%d done tasks archived\n", l.Name(), n)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
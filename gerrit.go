@@ -0,0 +1,230 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+// A gerritTracker mirrors the open changes on a Gerrit server
+// that the authenticated user owns (outgoing reviews)
+// or has been asked to review (incoming reviews).
+// Each task's external ID is the change's Change-Id,
+// and its ID is the change number.
+//
+// It authenticates using the cookie for the server in $HOME/.gitcookies,
+// as written by the googlesource.com "new password" page,
+// or else the login and password for the server in $HOME/.netrc.
+type gerritTracker struct {
+	host   string
+	cookie string
+	user   string
+	pass   string
+}
+
+func newGerritTracker(host string) (tracker, error) {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "https://"), "/")
+	g := &gerritTracker{host: host}
+	g.cookie = gitCookie(host)
+	if g.cookie == "" {
+		g.user, g.pass = netrcLogin(host)
+	}
+	if g.cookie == "" && g.user == "" {
+		return nil, fmt.Errorf("no credentials for %s in ~/.gitcookies or ~/.netrc", host)
+	}
+	return g, nil
+}
+
+func (g *gerritTracker) prefix() string {
+	return g.host + "/"
+}
+
+type gerritChange struct {
+	ChangeID string `json:"change_id"`
+	Number   int    `json:"_number"`
+	Project  string
+	Branch   string
+	Subject  string
+	Status   string
+	Created  string
+	Updated  string
+	Owner    struct{ Name, Email string }
+	Messages []struct {
+		Author struct{ Name string }
+		Date   string
+		Msg    string `json:"message"`
+	}
+}
+
+// gerritTime is the time format used by Gerrit, always in UTC.
+const gerritTime = "2006-01-02 15:04:05.000000000"
+
+func parseGerritTime(s string) time.Time {
+	tm, _ := time.Parse(gerritTime, s)
+	return tm
+}
+
+func (g *gerritTracker) convert(c *gerritChange, role string) *remoteIssue {
+	ri := &remoteIssue{
+		id:      c.ChangeID,
+		taskID:  fmt.Sprint(c.Number),
+		title:   c.Subject,
+		url:     fmt.Sprintf("https://%s/c/%s/+/%d", g.host, c.Project, c.Number),
+		created: parseGerritTime(c.Created),
+		updated: parseGerritTime(c.Updated),
+		closed:  c.Status == "MERGED" || c.Status == "ABANDONED",
+		hdr: map[string]string{
+			"project": c.Project,
+			"branch":  c.Branch,
+			"owner":   oneLine(c.Owner.Name),
+		},
+	}
+	if role != "" {
+		ri.hdr["role"] = role
+	}
+	return ri
+}
+
+func (g *gerritTracker) query(q string, opts ...string) ([]*gerritChange, error) {
+	u := g.base() + "/changes/?q=" + url.QueryEscape(q)
+	for _, o := range opts {
+		u += "&o=" + o
+	}
+	var list []*gerritChange
+	if _, err := trackerRequest("GET", u, g.auth, nil, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (g *gerritTracker) open() ([]*remoteIssue, error) {
+	var list []*remoteIssue
+	for _, r := range []struct{ role, q string }{
+		{"owner", "is:open owner:self"},
+		{"reviewer", "is:open reviewer:self -owner:self"},
+	} {
+		changes, err := g.query(r.q, "DETAILED_ACCOUNTS")
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range changes {
+			list = append(list, g.convert(c, r.role))
+		}
+	}
+	return list, nil
+}
+
+func (g *gerritTracker) issue(id string) (*remoteIssue, error) {
+	changes, err := g.query("change:"+id, "DETAILED_ACCOUNTS")
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("%s: change %s not found", g.host, id)
+	}
+	return g.convert(changes[0], ""), nil
+}
+
+func (g *gerritTracker) comments(ri *remoteIssue, since time.Time) ([]importComment, error) {
+	changes, err := g.query("change:"+ri.id, "MESSAGES")
+	if err != nil {
+		return nil, err
+	}
+	var list []importComment
+	for _, c := range changes {
+		for _, m := range c.Messages {
+			tm := parseGerritTime(m.Date)
+			if tm.After(since) {
+				list = append(list, importComment{time: tm, text: m.Author.Name + ":\n" + m.Msg})
+			}
+		}
+	}
+	return list, nil
+}
+
+func (g *gerritTracker) close(id string) error {
+	return fmt.Errorf("%s: %w Gerrit changes; submit or abandon %s on the server", g.host, errCannotClose, id)
+}
+
+// base returns the base URL for authenticated API requests.
+func (g *gerritTracker) base() string {
+	return "https://" + g.host + "/a"
+}
+
+func (g *gerritTracker) auth(req *http.Request) {
+	if g.cookie != "" {
+		req.Header.Set("Cookie", g.cookie)
+	} else {
+		req.SetBasicAuth(g.user, g.pass)
+	}
+}
+
+// gitCookie returns the cookie for host in $HOME/.gitcookies, if any.
+// The file is in Netscape cookie format: tab-separated lines of
+// domain, include subdomains, path, secure, expiry, name, value.
+func gitCookie(host string) string {
+	data, err := ioutil.ReadFile(filepath.Join(task.HomeDir(), ".gitcookies"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		f := strings.Split(line, "\t")
+		if len(f) != 7 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domain := f[0]
+		if domain == host || strings.HasPrefix(domain, ".") && strings.HasSuffix(host, domain) {
+			return f[5] + "=" + f[6]
+		}
+	}
+	return ""
+}
+
+// netrcLogin returns the login and password for host in $HOME/.netrc, if any.
+func netrcLogin(host string) (user, pass string) {
+	f, err := os.Open(filepath.Join(task.HomeDir(), ".netrc"))
+	if err != nil {
+		return "", ""
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	s.Split(bufio.ScanWords)
+	var words []string
+	for s.Scan() {
+		words = append(words, s.Text())
+	}
+	match := false
+	for i := 0; i+1 < len(words); i += 2 {
+		switch words[i] {
+		case "machine":
+			if match && user != "" {
+				return user, pass
+			}
+			match = words[i+1] == host
+		case "login":
+			if match {
+				user = words[i+1]
+			}
+		case "password":
+			if match {
+				pass = words[i+1]
+			}
+		}
+	}
+	if !match {
+		return "", ""
+	}
+	return user, pass
+}
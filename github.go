@@ -0,0 +1,175 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+// githubAPI is the base URL of the GitHub API.
+var githubAPI = "https://api.github.com"
+
+// A githubTracker mirrors the open issues in a GitHub repository
+// assigned to the authenticated user.
+//
+// It authenticates using the token in $GITHUB_TOKEN
+// or else in $HOME/.github-issue-token, the same file
+// used by rsc.io/github/issue.
+type githubTracker struct {
+	repo  string // "owner/repo"
+	token string
+	user  string // login of authenticated user
+}
+
+func newGitHubTracker(repo string) (tracker, error) {
+	if strings.Count(repo, "/") != 1 {
+		return nil, fmt.Errorf("invalid GitHub repository %q: want owner/repo", repo)
+	}
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		data, err := ioutil.ReadFile(filepath.Join(task.HomeDir(), ".github-issue-token"))
+		if err != nil {
+			return nil, fmt.Errorf("reading GitHub token: %v", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	g := &githubTracker{repo: repo, token: token}
+	var user struct{ Login string }
+	if _, err := g.do("GET", githubAPI+"/user", nil, &user); err != nil {
+		return nil, err
+	}
+	g.user = user.Login
+	return g, nil
+}
+
+func (g *githubTracker) prefix() string {
+	return "github.com/" + g.repo + "/issues/"
+}
+
+type githubIssue struct {
+	Number    int
+	Title     string
+	Body      string
+	State     string
+	HTMLURL   string    `json:"html_url"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Labels    []struct{ Name string }
+	Milestone *struct{ Title string }
+	// PullRequest is set when the issue is a pull request.
+	PullRequest *struct{} `json:"pull_request"`
+}
+
+func (g *githubTracker) convert(gi *githubIssue) *remoteIssue {
+	ri := &remoteIssue{
+		id:      fmt.Sprint(gi.Number),
+		title:   gi.Title,
+		url:     gi.HTMLURL,
+		body:    gi.Body,
+		created: gi.CreatedAt,
+		updated: gi.UpdatedAt,
+		closed:  gi.State == "closed",
+		hdr:     map[string]string{"labels": "", "milestone": ""},
+	}
+	var labels []string
+	for _, l := range gi.Labels {
+		labels = append(labels, l.Name)
+	}
+	ri.hdr["labels"] = oneLine(strings.Join(labels, ", "))
+	if gi.Milestone != nil {
+		ri.hdr["milestone"] = oneLine(gi.Milestone.Title)
+	}
+	return ri
+}
+
+func (g *githubTracker) open() ([]*remoteIssue, error) {
+	var list []*remoteIssue
+	u := githubAPI + "/repos/" + g.repo + "/issues?state=open&per_page=100&assignee=" + url.QueryEscape(g.user)
+	for u != "" {
+		var page []*githubIssue
+		next, err := g.do("GET", u, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, gi := range page {
+			if gi.PullRequest == nil {
+				list = append(list, g.convert(gi))
+			}
+		}
+		u = next
+	}
+	return list, nil
+}
+
+func (g *githubTracker) issue(id string) (*remoteIssue, error) {
+	var gi githubIssue
+	if _, err := g.do("GET", githubAPI+"/repos/"+g.repo+"/issues/"+id, nil, &gi); err != nil {
+		return nil, err
+	}
+	return g.convert(&gi), nil
+}
+
+func (g *githubTracker) comments(ri *remoteIssue, since time.Time) ([]importComment, error) {
+	var list []importComment
+	u := githubAPI + "/repos/" + g.repo + "/issues/" + ri.id + "/comments?per_page=100"
+	if !since.IsZero() {
+		u += "&since=" + url.QueryEscape(since.UTC().Format(time.RFC3339))
+	}
+	for u != "" {
+		var page []struct {
+			User      struct{ Login string }
+			Body      string
+			CreatedAt time.Time `json:"created_at"`
+		}
+		next, err := g.do("GET", u, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range page {
+			// The API's since filters by update time; skip edits of old comments.
+			if c.CreatedAt.After(since) {
+				list = append(list, importComment{time: c.CreatedAt, text: "@" + c.User.Login + ":\n" + c.Body})
+			}
+		}
+		u = next
+	}
+	return list, nil
+}
+
+func (g *githubTracker) close(id string) error {
+	body := map[string]string{"state": "closed"}
+	_, err := g.do("PATCH", githubAPI+"/repos/"+g.repo+"/issues/"+id, body, nil)
+	return err
+}
+
+var linkNextRE = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// do makes a GitHub API request, sending body (if non-nil) as JSON
+// and decoding the JSON response into out (if non-nil).
+// It returns the URL of the next page of results, if any.
+func (g *githubTracker) do(method, u string, body, out interface{}) (next string, err error) {
+	auth := func(req *http.Request) {
+		req.Header.Set("Authorization", "token "+g.token)
+		req.Header.Set("Accept", "application/vnd.github.v3+json")
+	}
+	h, err := trackerRequest(method, u, auth, body, out)
+	if err != nil {
+		return "", err
+	}
+	if m := linkNextRE.FindStringSubmatch(h.Get("Link")); m != nil {
+		next = m[1]
+	}
+	return next, nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// A gitlabTracker mirrors the open issues and merge requests
+// in a GitLab project that are assigned to the authenticated user.
+// Issue IDs have the form issues/N and merge request IDs merge_requests/N,
+// matching the paths in their web URLs; the corresponding task IDs
+// are N and mrN.
+//
+// It authenticates using the personal access token in $GITLAB_TOKEN.
+type gitlabTracker struct {
+	host    string // "gitlab.com"
+	project string // "group/project"
+	token   string
+	userID  int
+}
+
+func newGitLabTracker(arg string) (tracker, error) {
+	i := strings.Index(arg, "/")
+	if i < 0 || !strings.Contains(arg[i+1:], "/") {
+		return nil, fmt.Errorf("invalid GitLab project %q: want host/group/project", arg)
+	}
+	g := &gitlabTracker{host: arg[:i], project: arg[i+1:], token: os.Getenv("GITLAB_TOKEN")}
+	if g.token == "" {
+		return nil, fmt.Errorf("$GITLAB_TOKEN not set")
+	}
+	var user struct{ ID int }
+	if _, err := trackerRequest("GET", g.api("/user"), g.auth, nil, &user); err != nil {
+		return nil, err
+	}
+	g.userID = user.ID
+	return g, nil
+}
+
+func (g *gitlabTracker) prefix() string {
+	return g.host + "/" + g.project + "/-/"
+}
+
+func (g *gitlabTracker) api(path string) string {
+	return "https://" + g.host + "/api/v4" + path
+}
+
+func (g *gitlabTracker) projectAPI(path string) string {
+	return g.api("/projects/" + url.PathEscape(g.project) + path)
+}
+
+func (g *gitlabTracker) auth(req *http.Request) {
+	req.Header.Set("PRIVATE-TOKEN", g.token)
+}
+
+type gitlabIssue struct {
+	IID         int
+	Title       string
+	Description string
+	State       string
+	WebURL      string    `json:"web_url"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Labels      []string
+	Milestone   *struct{ Title string }
+}
+
+func (g *gitlabTracker) convert(kind string, gi *gitlabIssue) *remoteIssue {
+	ri := &remoteIssue{
+		id:      fmt.Sprintf("%s/%d", kind, gi.IID),
+		taskID:  fmt.Sprint(gi.IID),
+		title:   gi.Title,
+		url:     gi.WebURL,
+		body:    gi.Description,
+		created: gi.CreatedAt,
+		updated: gi.UpdatedAt,
+		closed:  gi.State == "closed" || gi.State == "merged",
+		hdr: map[string]string{
+			"labels":    oneLine(strings.Join(gi.Labels, ", ")),
+			"milestone": "",
+		},
+	}
+	if kind == "merge_requests" {
+		ri.taskID = "mr" + ri.taskID
+	}
+	if gi.Milestone != nil {
+		ri.hdr["milestone"] = oneLine(gi.Milestone.Title)
+	}
+	return ri
+}
+
+// getAll returns the JSON values in all pages of results
+// from the API URL u, which must already have a query string.
+func (g *gitlabTracker) getAll(u string) ([]json.RawMessage, error) {
+	var all []json.RawMessage
+	for n := "1"; n != ""; {
+		var page []json.RawMessage
+		h, err := trackerRequest("GET", u+"&per_page=100&page="+n, g.auth, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		n = h.Get("X-Next-Page")
+	}
+	return all, nil
+}
+
+func (g *gitlabTracker) open() ([]*remoteIssue, error) {
+	var list []*remoteIssue
+	for _, kind := range []string{"issues", "merge_requests"} {
+		all, err := g.getAll(g.projectAPI(fmt.Sprintf("/%s?state=opened&assignee_id=%d", kind, g.userID)))
+		if err != nil {
+			return nil, err
+		}
+		for _, js := range all {
+			var gi gitlabIssue
+			if err := json.Unmarshal(js, &gi); err != nil {
+				return nil, err
+			}
+			list = append(list, g.convert(kind, &gi))
+		}
+	}
+	return list, nil
+}
+
+func (g *gitlabTracker) issue(id string) (*remoteIssue, error) {
+	var gi gitlabIssue
+	if _, err := trackerRequest("GET", g.projectAPI("/"+id), g.auth, nil, &gi); err != nil {
+		return nil, err
+	}
+	return g.convert(id[:strings.Index(id, "/")], &gi), nil
+}
+
+func (g *gitlabTracker) comments(ri *remoteIssue, since time.Time) ([]importComment, error) {
+	all, err := g.getAll(g.projectAPI("/" + ri.id + "/notes?sort=asc&order_by=created_at"))
+	if err != nil {
+		return nil, err
+	}
+	var list []importComment
+	for _, js := range all {
+		var n struct {
+			Body      string
+			System    bool
+			CreatedAt time.Time `json:"created_at"`
+			Author    struct{ Username string }
+		}
+		if err := json.Unmarshal(js, &n); err != nil {
+			return nil, err
+		}
+		if !n.System && n.CreatedAt.After(since) {
+			list = append(list, importComment{time: n.CreatedAt, text: "@" + n.Author.Username + ":\n" + n.Body})
+		}
+	}
+	return list, nil
+}
+
+func (g *gitlabTracker) close(id string) error {
+	_, err := trackerRequest("PUT", g.projectAPI("/"+id), g.auth, map[string]string{"state_event": "close"}, nil)
+	return err
+}
@@ -1,5 +1,11 @@
 module rsc.io/todo
 
-go 1.13
+go 1.18
 
-require 9fans.net/go v0.0.1
+require (
+	9fans.net/go v0.0.1
+	golang.org/x/term v0.13.0
+	golang.org/x/text v0.13.0
+)
+
+require golang.org/x/sys v0.13.0 // indirect
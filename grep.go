@@ -0,0 +1,108 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"rsc.io/todo/task"
+)
+
+func cmdGrep(args []string) {
+	fs := flag.NewFlagSet("grep", flag.ExitOnError)
+	context := fs.Int("C", 0, "print `n` lines of context around each match")
+	fold := fs.Bool("i", false, "ignore case in pattern")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo grep [-C n] [-i] pattern [query]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+	}
+	expr := fs.Arg(0)
+	if *fold {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	found := false
+	for _, l := range allLists(taskList(*dirFlag)) {
+		q := strings.Join(fs.Args()[1:], " ")
+		if q == "" {
+			q = defaultQuery(l)
+		}
+		tasks, err := search(l, q)
+		if err != nil {
+			log.Fatal(err)
+		}
+		task.Sort(tasks, "id")
+		for _, t := range tasks {
+			if grepTask(w, path.Join(l.Name(), t.ID()), t, re, *context) {
+				found = true
+			}
+		}
+	}
+	w.Flush()
+	if !found {
+		os.Exit(1)
+	}
+}
+
+// grepTask prints the lines of t matching re, as "name:line: text",
+// along with n lines of context before and after each, as "name-line- text",
+// separating non-adjacent groups of lines with "--" lines, as grep -C does.
+// It reports whether any lines matched.
+func grepTask(w *bufio.Writer, name string, t *task.Task, re *regexp.Regexp, n int) bool {
+	lines := strings.Split(strings.TrimSuffix(string(t.Text()), "\n"), "\n")
+	last := -1 // last line printed
+	matched := false
+	for i, line := range lines {
+		if !re.MatchString(line) {
+			continue
+		}
+		start := i - n
+		if start <= last {
+			start = last + 1
+		} else if start < 0 {
+			start = 0
+		}
+		if n > 0 && last >= 0 && start > last+1 {
+			fmt.Fprintf(w, "--\n")
+		}
+		end := i + n
+		if end >= len(lines) {
+			end = len(lines) - 1
+		}
+		for j := start; j <= end; j++ {
+			sep := "-"
+			if re.MatchString(lines[j]) {
+				sep = ":"
+			}
+			if j > i && sep == ":" {
+				// Leave later matches for the outer loop.
+				end = j - 1
+				break
+			}
+			fmt.Fprintf(w, "%s%s%d%s %s\n", name, sep, j+1, sep, lines[j])
+		}
+		last = end
+		matched = true
+	}
+	return matched
+}
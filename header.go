@@ -0,0 +1,77 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"rsc.io/todo/task"
+)
+
+func cmdRenameHeader(args []string) {
+	fs := flag.NewFlagSet("rename-header", flag.ExitOnError)
+	dryRun := fs.Bool("n", false, "print the tasks that would be changed but do not change them")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo rename-header [-n] old new [query]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+	}
+	old, new := strings.ToLower(fs.Arg(0)), strings.ToLower(fs.Arg(1))
+	q := strings.Join(fs.Args()[2:], " ")
+
+	failed := false
+	for _, l := range allLists(taskList(*dirFlag)) {
+		if !*dryRun && l.ReadOnly() {
+			continue
+		}
+		var tasks []*task.Task
+		if q == "" {
+			open, err := l.All()
+			if err != nil {
+				log.Fatal(err)
+			}
+			done, err := l.Done()
+			if err != nil {
+				log.Fatal(err)
+			}
+			tasks = append(open, done...)
+		} else {
+			var err error
+			tasks, err = search(l, q)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		task.Sort(tasks, "id")
+		for _, t := range tasks {
+			if *dryRun {
+				if n := t.HeaderUpdates(old); n > 0 {
+					fmt.Printf("%s\t%d updates\n", taskRef(l, t), n)
+				}
+				continue
+			}
+			_, changed, err := l.RenameHeader(t.ID(), old, new)
+			if err != nil {
+				log.Printf("%s: %v", taskRef(l, t), err)
+				failed = true
+				continue
+			}
+			if changed {
+				fmt.Printf("%s\n", taskRef(l, t))
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,347 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+// An importTask is a task read from another tool's export,
+// not yet written to the task list.
+type importTask struct {
+	eid     string    // external ID, recorded as #id
+	list    string    // sublist of the destination list; "" for the list itself
+	created time.Time // creation time; zero means now
+	hdr     map[string]string
+	body    string
+
+	comments []importComment // later comments, oldest first
+}
+
+// An importComment is a comment on an importTask,
+// imported as an update to the task.
+type importComment struct {
+	time time.Time // zero means the task's creation time
+	text string
+}
+
+// importSources maps the tool names accepted by todo import -from
+// to parsers for that tool's export files.
+var importSources = map[string]func(file string, data []byte) ([]*importTask, error){
+	"things":  parseThings,
+	"todoist": parseTodoist,
+	"trello":  parseTrello,
+}
+
+func cmdImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dryRun := fs.Bool("n", false, "print tasks that would be imported, but do not create them")
+	format := fs.String("format", "", "input `format` (json, csv, todotxt); default is to detect from file")
+	projects := fs.String("projects", "tag", "map todo.txt +projects to `kind`: tag or list")
+	from := fs.String("from", "", "import an export file from `tool` (things, todoist, trello)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo import [-n] [-format f] [-projects kind] file\n")
+		fmt.Fprintf(os.Stderr, "       todo import [-n] -from tool file\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+	}
+
+	file := fs.Arg(0)
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *format == "" {
+		*format = detectFormat(file, data)
+	}
+	var tasks []*importTask
+	switch {
+	case *from != "":
+		parse := importSources[*from]
+		if parse == nil {
+			log.Fatalf("unknown import source %q", *from)
+		}
+		tasks, err = parse(file, data)
+	case *format == "json":
+		tasks, err = parseJSONTasks(data)
+	case *format == "csv":
+		tasks, err = parseCSVTasks(data)
+	case *format == "todotxt":
+		if *projects != "tag" && *projects != "list" {
+			log.Fatalf("invalid -projects %q: must be tag or list", *projects)
+		}
+		tasks, err = parseTodoTxt(data, *projects == "list")
+	default:
+		log.Fatalf("unknown import format %q", *format)
+	}
+	if err != nil {
+		log.Fatalf("%s: %v", file, err)
+	}
+
+	importTasks(taskList(*dirFlag), tasks, *dryRun)
+}
+
+// detectFormat guesses the format of the import file
+// from its name, falling back to its content.
+func detectFormat(file string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		return "json"
+	case ".csv":
+		return "csv"
+	case ".txt":
+		return "todotxt"
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && (data[0] == '[' || data[0] == '{') {
+		return "json"
+	}
+	return "csv"
+}
+
+// importTasks creates the tasks in l or its sublists, skipping any whose
+// external ID is already recorded in the destination list.
+// If dryRun is set, importTasks only prints what it would do.
+func importTasks(l *task.List, tasks []*importTask, dryRun bool) {
+	seenByList := make(map[string]map[string]*task.Task)
+	n := 0
+	for _, it := range tasks {
+		name := path.Join(l.Name(), it.list)
+		if dryRun {
+			if it.list != "" && !task.IsList(name) {
+				fmt.Printf("new list\t%s\n", name)
+			}
+		} else if err := task.MakeList(name); err != nil {
+			log.Fatal(err)
+		}
+		dst := taskList(name)
+		dst.Importing()
+		seen := seenByList[name]
+		if seen == nil {
+			var err error
+			if seen, err = dst.ExternalIDs(); err != nil {
+				log.Fatal(err)
+			}
+			seenByList[name] = seen
+		}
+		if it.eid != "" {
+			if t := seen[it.eid]; t != nil {
+				log.Printf("skipping %s: already imported as %s", it.eid, path.Join(name, t.ID()))
+				continue
+			}
+		}
+		if dryRun {
+			fmt.Printf("new\t%s\n", it.hdr["title"])
+			n++
+			continue
+		}
+		hdr := make(map[string]string)
+		for k, v := range it.hdr {
+			hdr[k] = v
+		}
+		if it.eid != "" {
+			hdr["#id"] = it.eid
+		}
+		// Writing a comment to a done task would reopen it,
+		// so mark the task done only with its last comment.
+		var last map[string]string
+		if len(it.comments) > 0 && hdr["todo"] != "" {
+			last = map[string]string{"todo": hdr["todo"]}
+			delete(hdr, "todo")
+		}
+		created := it.created
+		if created.IsZero() {
+			created = time.Now()
+		}
+		t, err := dst.Create("", created, hdr, []byte(it.body))
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i, c := range it.comments {
+			var h map[string]string
+			if i == len(it.comments)-1 {
+				h = last
+			}
+			tm := c.time
+			if tm.Before(created) {
+				tm = created
+			}
+			if err := dst.Write(t, tm, h, []byte(c.text)); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if it.eid != "" {
+			seen[it.eid] = t
+		}
+		fmt.Printf("%s\t%s\n", path.Join(it.list, t.ID()), t.Title())
+		n++
+	}
+	if dryRun {
+		log.Printf("would import %d task%s", n, suffix(n))
+		return
+	}
+	log.Printf("imported %d task%s", n, suffix(n))
+}
+
+// newImportTask returns an importTask built from a generic record,
+// as found in a JSON object or CSV row.
+// The keys id, created (or ctime), and body (or description or notes)
+// are treated specially; all other non-empty fields become headers.
+func newImportTask(rec map[string]string) (*importTask, error) {
+	it := &importTask{hdr: make(map[string]string)}
+	for k, v := range rec {
+		k = strings.ToLower(strings.TrimSpace(k))
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		switch k {
+		case "id":
+			it.eid = v
+		case "created", "ctime":
+			tm, err := parseImportTime(v)
+			if err != nil {
+				return nil, err
+			}
+			it.created = tm
+		case "body", "description", "notes":
+			it.body = v
+		default:
+			if !validHeaderKey(k) {
+				return nil, fmt.Errorf("invalid header name %q", k)
+			}
+			it.hdr[k] = oneLine(v)
+		}
+	}
+	if it.hdr["title"] == "" {
+		return nil, fmt.Errorf("task has no title")
+	}
+	return it, nil
+}
+
+func validHeaderKey(k string) bool {
+	return k != "" && !strings.HasPrefix(k, "#") && !strings.ContainsAny(k, ": \t\r\n")
+}
+
+// oneLine collapses s to a single line, suitable for a header value.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+var importTimeFormats = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+func parseImportTime(s string) (time.Time, error) {
+	for _, f := range importTimeFormats {
+		if tm, err := time.ParseInLocation(f, s, time.Local); err == nil {
+			return tm, nil
+		}
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// parseJSONTasks parses a JSON array of task objects.
+// Non-string values are converted to their JSON text.
+func parseJSONTasks(data []byte) ([]*importTask, error) {
+	var recs []map[string]interface{}
+	if err := json.Unmarshal(data, &recs); err != nil {
+		return nil, err
+	}
+	var tasks []*importTask
+	for i, rec := range recs {
+		m := make(map[string]string)
+		for k, v := range rec {
+			switch v := v.(type) {
+			case nil:
+				// omit
+			case string:
+				m[k] = v
+			default:
+				js, _ := json.Marshal(v)
+				m[k] = string(js)
+			}
+		}
+		it, err := newImportTask(m)
+		if err != nil {
+			return nil, fmt.Errorf("task #%d: %v", i+1, err)
+		}
+		tasks = append(tasks, it)
+	}
+	return tasks, nil
+}
+
+// parseCSVTasks parses CSV data whose first row names the fields.
+func parseCSVTasks(data []byte) ([]*importTask, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	names := rows[0]
+	var tasks []*importTask
+	for i, row := range rows[1:] {
+		m := make(map[string]string)
+		for j, v := range row {
+			if j < len(names) {
+				m[names[j]] = v
+			}
+		}
+		it, err := newImportTask(m)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+2, err)
+		}
+		tasks = append(tasks, it)
+	}
+	return tasks, nil
+}
+
+// hashEID returns a synthetic external ID for a task from a source
+// that does not assign IDs of its own, derived from the fields
+// that identify the task in that source.
+func hashEID(source string, fields ...string) string {
+	h := sha256.New()
+	for _, f := range fields {
+		io.WriteString(h, f)
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%s:%x", source, h.Sum(nil)[:8])
+}
+
+// baseName returns the file name without directory or extension.
+func baseName(file string) string {
+	file = filepath.Base(file)
+	return strings.TrimSuffix(file, filepath.Ext(file))
+}
@@ -0,0 +1,168 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+// Quick capture.
+//
+// todo capture adds a task with a one-line title to the inbox list,
+// named by the inbox setting in the root list's configuration
+// (default "inbox"), without opening an editor or applying a template.
+// todo inbox (or todo refile) reviews the captured tasks one at a time,
+// refiling each into another list, marking it done, or leaving it.
+
+func cmdCapture(args []string) {
+	fs := flag.NewFlagSet("capture", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo capture title...\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	title := strings.Join(strings.Fields(strings.Join(fs.Args(), " ")), " ")
+	if title == "" {
+		fs.Usage()
+	}
+	l, err := inboxList()
+	if err != nil {
+		log.Fatal(err)
+	}
+	t, err := l.Create("", time.Now(), map[string]string{"title": title}, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(taskRef(l, t))
+}
+
+func cmdInbox(args []string) {
+	fs := flag.NewFlagSet("inbox", flag.ExitOnError)
+	listOnly := fs.Bool("l", false, "list the inbox without reviewing it")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo inbox [-l]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+	}
+	l, err := inboxList()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *listOnly || !isTerminal(os.Stdin) {
+		if err := showQuery(os.Stdout, l, "all", nil); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := reviewInbox(os.Stdout, bufio.NewReader(os.Stdin), l); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// inboxList returns the list that todo capture adds tasks to,
+// creating it if necessary.
+func inboxList() (*task.List, error) {
+	name := taskList("").Config().Get("inbox")
+	if name == "" {
+		name = "inbox"
+	}
+	name = path.Clean(strings.Trim(name, "/"))
+	if err := task.MakeList(name); err != nil {
+		return nil, err
+	}
+	return taskList(name), nil
+}
+
+// reviewInbox walks the open tasks in the inbox list l, oldest first,
+// asking on w and reading from r what to do with each.
+func reviewInbox(w io.Writer, r *bufio.Reader, l *task.List) error {
+	tasks, err := l.All()
+	if err != nil {
+		return err
+	}
+	task.Sort(tasks, "id")
+	if len(tasks) == 0 {
+		fmt.Fprintf(w, "%s is empty\n", l.Name())
+		return nil
+	}
+	for i, t := range tasks {
+		fmt.Fprintf(w, "\n%s\t%s\t(%d of %d)\n", taskRef(l, t), t.Title(), i+1, len(tasks))
+		quit, err := reviewTask(w, r, l, t)
+		if quit || err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reviewTask asks on w and reads from r what to do with the task t in l:
+// refile it to another list, mark it done, leave it, or stop reviewing.
+// It offers the first few refile destinations by number, recently used
+// lists first, and asks again if the answer names no list.
+func reviewTask(w io.Writer, r *bufio.Reader, l *task.List, t *task.Task) (quit bool, err error) {
+	targets := refileTargets(l)
+	if len(targets) > 9 {
+		targets = targets[:9]
+	}
+	for {
+		for i, name := range targets {
+			fmt.Fprintf(w, "  %d %s", i+1, name)
+		}
+		if len(targets) > 0 {
+			fmt.Fprintf(w, "\n")
+		}
+		fmt.Fprintf(w, "refile to list (return skips, d done, q quits): ")
+		line, err := r.ReadString('\n')
+		if err != nil && line == "" {
+			if err == io.EOF {
+				fmt.Fprintf(w, "\n")
+				return true, nil
+			}
+			return true, err
+		}
+		switch line = strings.TrimSpace(line); line {
+		case "":
+			return false, nil
+		case "q":
+			return true, nil
+		case "d":
+			if err := l.Write(t, time.Now(), map[string]string{"todo": "done"}, nil); err != nil {
+				return true, err
+			}
+			fmt.Fprintf(w, "%s done\n", taskRef(l, t))
+			return false, nil
+		}
+		name := line
+		if n, err := strconv.Atoi(line); err == nil && 1 <= n && n <= len(targets) {
+			name = targets[n-1]
+		}
+		if !task.IsList(path.Clean(strings.Trim(name, "/"))) {
+			fmt.Fprintf(w, "no list %s\n", name)
+			continue
+		}
+		ref, err := refile(l, t, name)
+		if err != nil {
+			return true, err
+		}
+		fmt.Fprintf(w, "moved to %s\n", ref)
+		return false, nil
+	}
+}
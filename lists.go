@@ -0,0 +1,47 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+func cmdLists(args []string) {
+	fs := flag.NewFlagSet("lists", flag.ExitOnError)
+	verbose := fs.Bool("v", false, "also print each list's description, from its _about file")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo lists [-v]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+	}
+	var buf bytes.Buffer
+	for _, l := range allLists(taskList(*dirFlag)) {
+		tasks, err := l.All()
+		if err != nil {
+			log.Fatal(err)
+		}
+		note := ""
+		if l.ReadOnly() {
+			note = " (read-only)"
+		}
+		fmt.Fprintf(&buf, "%s\t%d open%s\n", l.Name(), len(tasks), note)
+		if about := l.About(); *verbose && about != "" {
+			for _, line := range strings.Split(about, "\n") {
+				fmt.Fprintf(&buf, "\t%s\n", line)
+			}
+			fmt.Fprintf(&buf, "\n")
+		}
+	}
+	page(buf.Bytes())
+}
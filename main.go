@@ -5,14 +5,710 @@
 /*
 Todo is a command-line and acme client for a to-do task tracking system.
 
-	usage: todo [-a] [-e] [-d subdir] [-done] <query>
+	usage: todo [-a [-open lists] [-restore]] [-e] [-d subdir] [-done [-dry-run]] [-yes] [-case] [-color mode] [-no-pager] [-w [interval]]
+	           [-sort key | -rank] [-group header] [-n N] [-offset M] [-reverse] <query>
+	       todo [-d subdir] <command> [args]
 
 Todo runs the query and prints the maching tasks, one per line.
 If the query is a single task number, as in ``todo 1'', todo prints
 the full history of the task.
+In place of a task ID, todo and its commands accept last, meaning the
+most recently created task in the list, ^, the most recently updated,
+and -, the task a todo command most recently acted on by ID,
+as in todo -done - after todo -e 123. Showing a task does not change -.
+
+A query is a sequence of terms, all of which a task must match.
+The term all matches open tasks; key:value matches tasks whose key header
+contains value, or, as key:=value, key:<value, and key:>value,
+equals, sorts before, or sorts after it; and any other word matches
+tasks mentioning it. The term id: followed by a task number, a range,
+or a comma-separated list of them, as in id:100-120 or id:3,7,19,
+matches those tasks, open or done, so that todo -done id:100-120
+and todo -e 3,7,19 work through a block of tasks without a header
+query. A comma-separated list needs no id:, but a bare range such as
+100-120 is text, as in a search for 2018-2019. The term key:* matches
+tasks with a key header, and key:= matches those without one.
+A leading minus sign negates a term, so that -due:* is the same
+as due:=.
+The term key~regexp matches tasks whose key header matches the
+regular expression, as in title~^(fix|revert):, and body~regexp
+matches tasks whose text has a line matching it.
+Text and header values match without regard to case or Unicode
+composition, so that readme matches README and an accented letter
+matches however it is encoded; the -case flag makes them match exactly.
+In due and scheduled comparisons, the value can be a date written in words,
+with dashes for spaces, as in due:<tomorrow, due:<fri, or due:<in-2-weeks.
+Queries leave out deferred tasks, whose todo header is "snooze date" or
+"waiting who date", until the date arrives, unless they ask about the
+state, as in todo:snooze or todo:waiting.
+
+Tasks are stored in lists, which are directories under $TODO_DIR,
+or else $HOME/todo (%USERPROFILE%\todo on Windows) if it exists,
+or else $XDG_DATA_HOME/todo if $XDG_DATA_HOME is set, or else $HOME/todo.
+The -d flag selects a list, such as -d work;
+the default is the root list.
+A profile is a separate root, with its own lists and settings,
+selected by the -p flag or $TODO_PROFILE, as in todo -p work all.
+The default root's configuration gives each profile's root
+with a setting such as "profile: work ~/work/todo"; otherwise
+the root for profile work is todo-work, next to the default root.
+With a profile, acme window names include it, as in /todo/work/123,
+and each todo -a opens only plumbed names for its own profile;
+the default root's todo -a takes /todo/work/123 to be its own
+only if work is one of its lists.
+A list's _about file describes the list for the people using it,
+such as the headers its tasks should have and what its states mean.
+It is shown at the top of the list's acme window and by todo lists -v.
+The command todo lists prints the list and its sublists,
+with the number of open tasks in each.
+The command todo mklist name, as in todo mklist work/infra, creates
+a list, seeding its _config file and, with -about text, its _about file.
+Its -sort, -query, and -readonly flags record the corresponding settings.
+In acme, the Mklist command, as in Mklist work/infra, creates a list
+and opens its window.
+The command todo rmlist name removes a list that has no tasks or sublists,
+nor any deleted tasks, archived done tasks, attachments, or drafts.
+With -archive, it instead moves the list, with its tasks and sublists,
+into the _archive directory in the root, out of the way of queries
+and list windows but still available for reference.
+A list whose configuration has the setting "readonly: true", such as
+a mirror maintained by vcs-todo or todo sync, or a list shared read-only,
+cannot be changed, nor can its sublists: todo refuses to edit them
+and acme windows showing them omit Put, Done, and the other commands
+that change tasks. Only the program maintaining such a list updates it.
+A list whose configuration, or that of a list containing it, has the
+setting "ids: slug" gives new tasks IDs made from their titles,
+such as fix-parser-panic for "Fix parser panic", with a numeric suffix,
+as in fix-parser-panic-2, if the ID is taken, instead of numbers.
+Slug IDs avoid the names of commands, plugins, and sublists.
+A task's todo header holds its state: none for an open task, done or mute
+for a closed one, or snooze or waiting for a deferred one. A list's
+configuration, or that of a list containing it, can define its own workflow
+with settings such as "states: triage accepted doing review done",
+"closed-states: wontfix", and "hidden-states: someday". Writes using
+a state not in the list, apart from those built in, are refused.
+Closed states count as done. Hidden states are left out of queries
+unless the query asks about the state, as in todo:someday.
+A setting such as "state-color: review yellow" colors tasks in a state
+in todo's output, and -group todo and acme boards by todo order
+the states as listed.
+
+The -sort flag orders the results as the acme Sort command does:
+by id (numerically), by title (the default), or by any other header,
+with a leading minus sign, as in -sort -priority, reversing the order.
+A comma-separated list of keys, as in -sort priority,due,-mtime,
+sorts by each key in turn, using later keys to break ties.
+
+The -cols flag chooses the columns printed for each task, as in
+-cols id,due,priority,title: id, title, or any header, with "-"
+for a missing header. The task ID is always the first column.
+A list's cols setting gives its default columns. Unless another
+sort is given, tasks shown in columns are sorted by the displayed
+columns in order, after the ID.
+When printing to a terminal, todo shows up to two lines from each task
+containing the query's text terms, with the terms highlighted,
+so that it is clear why the task matched. Acme list windows show
+the same lines.
+
+The sort key rank, or the -rank flag, orders the results by relevance
+to the query's text terms: tasks with a term in the title first,
+then in another header, then in the text, favoring recently updated tasks.
+A list's sort setting, such as "sort: priority,due", gives its default order.
+A list's query setting, such as "query: reviewed:=no" or
+"query: all -tag:someday", is a saved search run by todo, todo -a,
+and todo ui when no query is given, and by the list's all window in acme.
+A list's alias settings, such as "alias: soon = due:<2019-07-01 -todo:snooze",
+define shorthand for queries, here letting todo soon mean the longer query.
+Aliases may use other aliases, and those defined in a list apply to its
+sublists too. They work everywhere queries do: on the command line,
+in acme and todo ui, and in the web and serve interfaces.
+The command todo alias lists the aliases for the list,
+and todo alias name query defines a new one.
+Queries can also use query functions: mine matches tasks whose
+assignee or owner header names $TODO_ACTOR (or else the user name),
+and stale(age), as in stale(2w), matches tasks with no update for age
+(default 30d). Programs using package rsc.io/todo/task can add their
+own with task.RegisterQueryFunc.
+The -n, -offset, and -reverse flags select a slice of the sorted results:
+-reverse reverses their order, -offset skips the first M results,
+and -n prints at most N results. For example, todo -n 20 all
+prints the first 20 open tasks.
+The -group flag prints the results in sections, one for each value
+of the given header, each headed by the value and its number of tasks.
 
 The -a flag opens the task or query in an acme window.
-The -e flag opens the task or query in the system editor.
+The -e flag opens the task or query in the system editor:
+$VISUAL, or else $EDITOR, or else ed (notepad on Windows).
+On Windows, where there is no shell to run it, the editor setting
+is either the editor's path, spaces and all, or a command line
+whose first word, double-quoted if it contains spaces, is the editor.
+After editing a task, todo lists the headers the edit will set,
+change, or remove, and the comment it will append, and asks for
+confirmation, which the -yes flag skips; without a terminal to ask,
+as in acme or a script, todo applies the edit. If the edited headers
+cannot be parsed, or the edit cannot be saved, todo keeps the edited
+text in a recovery file and, on a terminal, offers to reopen the editor
+with a line explaining each error. Editing a query edits all
+the matching tasks at once; before applying the edit, todo lists
+the headers that will change on each task and again asks for confirmation;
+without a terminal to ask, a bulk edit needs -yes. A bulk edit that cannot
+be applied is kept for recovery in the same way.
+The -done flag marks the task, or the open tasks matching the query,
+as done. When a query matches more than one open task, todo lists
+them and asks for confirmation first, again unless -yes is given.
+With -dry-run, -done only prints the tasks it would mark done.
+
+When printing to a terminal, todo aligns the query results,
+truncates them to the terminal width, and colors them:
+overdue tasks are red, snoozed and done tasks are dim,
+and tasks with a priority are bold.
+The -color flag, or else the color setting in the root list's
+configuration, controls coloring: auto (the default) colors
+only output to a terminal, always colors all output, and never
+colors nothing. Output to a pipe or file is otherwise plain.
+
+When output to a terminal is longer than a screen, todo shows it
+using $PAGER (default less), setting $LESS to FRX if it is unset,
+as git does. The -no-pager flag disables the pager.
+
+The -w flag watches the query: todo prints the results, without a pager,
+and prints them again whenever a task in the list or its sublists
+changes, as in todo -w all. An interval before the query,
+as in todo -w 5m due:<tomorrow or todo -w 1d all,
+also reprints the results that often. On a terminal, each printing
+clears the screen first, making a text dashboard for a spare window.
+
+If the first argument names a command, todo runs that command instead:
+
+	todo import [-n] [-format f] [-projects kind] file
+	todo import [-n] -from tool file
+
+Import creates tasks from a JSON, CSV, or todo.txt file.
+In JSON and CSV files, each object or row is one task;
+fields named id, created, and body become the task's external ID,
+creation time, and initial comment, and other fields become headers.
+In todo.txt files, priorities, contexts, and key:value pairs become headers,
+and projects become tags or, with -projects=list, sublists.
+The -from flag instead reads an export from another tool:
+a Todoist project CSV, a Trello board JSON, or Things JSON.
+Projects and boards become sublists, labels become tags,
+and comments become updates to the imported tasks.
+Tasks whose external ID is already recorded in the list are skipped.
+The -n flag reports what would be imported without creating anything.
+
+	todo show [-json | -header key] id
+
+Show prints the full history of the task with the given ID, as todo id does.
+The -json flag prints the task as a JSON object instead, with its list, ID,
+title, current headers, and each update's time, headers, and comment,
+in the form used by todo serve -stdio. The -header flag prints just
+the value of one header, such as todo show -header due 12, exiting
+with status 1 if the task has no such header, for use in scripts.
+
+	todo export [-format f] [-group header] [query]
+
+Export prints the tasks in the list and its sublists matching
+the query (default all tasks, open and done) in the given format:
+todotxt, or md for a Markdown report listing each task's headers
+and latest comment, with tasks grouped into sections by the -group header.
+Tasks in sublists are named relative to the list, as in infra/123.
+
+	todo eval 'expr' [query]
+
+Eval prints a report computed from the tasks matching the query (default "all").
+The expression is a comma-separated list of columns, optionally followed
+by "by header" to print one row for each value of that header
+(each tag, for "by tag"). A column is an aggregate, one of count, count(x),
+sum(x), avg(x), min(x), or max(x), or, in a report without aggregates,
+an expression printed for each task. Expressions combine numbers
+and header names with + - * / and parentheses; header values that are
+durations, such as 90m or 2d, count as hours, and a task whose header
+is missing or not a number is left out of the aggregate. For example:
+
+	todo eval 'count, sum(estimate), avg(spent) by tag' todo:done
+	todo eval 'spent/estimate' estimate:*
+
+	todo sync [-push] [kind arg]
+
+Sync mirrors the open issues assigned to you in a remote tracker.
+Each issue becomes a task, using the issue number as the task ID when possible,
+and new comments on the issue are appended as updates.
+When an issue is closed, its task is marked done.
+The -push flag also closes issues whose tasks have been marked done locally,
+except in Gerrit, where sync reports the changes to submit or abandon.
+The tracker kinds are:
+
+	github owner/repo
+		GitHub issues, using the token in $GITHUB_TOKEN
+		or $HOME/.github-issue-token
+	gitlab host/group/project
+		GitLab issues and merge requests, using the token in $GITLAB_TOKEN
+	bugzilla host
+		Bugzilla bugs, using the API key in $BUGZILLA_API_KEY
+	gerrit host
+		your open outgoing and incoming Gerrit reviews, recorded with
+		a role header of owner or reviewer and the Change-Id as external ID,
+		using credentials from $HOME/.gitcookies or $HOME/.netrc
+
+With no arguments, sync walks the list and its sublists,
+syncing each list with the trackers named in its configuration.
+
+Each list can be configured by a _config file in its directory,
+containing "key: value" settings, one per line.
+The sync setting, which may be repeated, has the form "sync: kind arg [push]".
+The hook setting, which may also be repeated, has the form "hook: event target".
+It runs target whenever a task in the list is created, updated, or marked done,
+for events create, update, and done, or on all three for event *.
+A target beginning with http:// or https:// is sent a POST request;
+any other target is a command to run. Either way, the hook receives
+a JSON description of the change, as defined by task.HookEvent.
+
+	todo ui [query]
+
+Ui runs a full-screen terminal interface, for use outside acme.
+The top pane lists the tasks matching the query (default the list's
+query setting, or else "all"), and the bottom pane shows the history
+of the selected task.
+The keys j and k (or the arrow keys) move the selection;
+/ edits the query, refreshing the list as you type;
+d, m, and s mark the selected task done, mute it, or snooze it;
+e and n edit the selected task or a new one in the system editor;
+g rereads the list; and q quits.
+The display also refreshes when the list's files change.
+Ui uses stty to drive the terminal, so it is not available on Windows.
+
+	todo amend id
+
+Amend opens the task's most recent comment in the system editor
+and rewrites that update in place with the edited text, for fixing
+typos without appending a correction. The update keeps its time and
+headers, and a note at the end of the comment records when it was amended.
+
+	todo rename-header [-n] old new [query]
+
+Rename-header renames the header old to new in the tasks matching
+the query (default all tasks, open and done) in the list and its
+sublists, rewriting every update that sets it, as in
+todo rename-header pri priority. With -n, it lists the tasks
+it would change. To treat old as new without rewriting task files,
+declare a header alias in the list's configuration instead,
+with a setting such as "header: pri = priority". Header aliases
+apply when tasks are read and written and in queries, so that
+pri:1 matches a task whose file says "priority: 1", and they apply
+to sublists too.
+
+	todo migrate [-n] -set key=value... query
+
+Migrate changes headers in every task matching the query, by writing
+an update to each task that needs one, for schema changes across many
+tasks that would be tedious in a bulk edit. Each -set key=value sets
+a header; an empty value, as in -set pri=, removes it; and a value
+of the form $other copies the task's current value of header other,
+as in todo migrate -set priority='$pri' -set pri= pri:*.
+With -n, migrate prints the header changes it would make.
+
+	todo slim [-max size] [-n]
+
+Slim shrinks the task files in the list and its sublists by moving each
+comment larger than the list's maxupdate setting (or -max size, or 32K)
+into an attachment, leaving the comment's first line and a note naming
+the attachment file, relative to the list's directory. A list's
+maxupdate setting, such as "maxupdate: 64K", also applies to new
+updates as they are written, so that tasks with large comments, like
+the diffs recorded by vcs-todo, stay quick to display and search.
+With -n, slim lists the tasks it would change.
+
+	todo delete id...
+	todo drafts [-rm name...]
+	todo resume [name]
+
+While a new task is being written, with todo -e new or in an acme
+New window, its text is saved as a draft in the list's _drafts directory,
+so that it is not lost if creating the task fails or the editor,
+todo, or acme exits first. Drafts prints the drafts in the list and
+its sublists, and -rm discards them. Resume reopens the editor on
+the named draft, or on the list's most recent one, and creates
+the task from it as todo -e new does, removing the draft.
+
+	todo trash
+	todo restore id...
+	todo empty-trash [-older duration]
+
+Delete moves tasks into the list's trash, along with their attachments.
+Trash prints the deleted tasks in the list and its sublists, and restore
+moves a deleted task back into the list, giving it a new ID if its
+numeric ID has been reused. Empty-trash removes, for good, the tasks
+deleted more than the list's retention period ago, given by its trash
+setting, such as "trash: 2w", or else 30 days, or by -older.
+It is meant to be run from cron.
+
+	todo activity [-since when]
+
+Activity prints the changes made to the list and its sublists in the
+last week (or since -since when, a duration ago, such as 3d, or a date,
+such as mon or 2019-07-01), oldest first: the time, who made the
+change, the kind of change, the task, and what changed. Every change
+is recorded in the _journal file in its list's directory, one line
+per change, which other programs can read too. A journal that reaches
+a megabyte is moved into the _journals directory, in a file named
+for the time of the move, and a new one started. The person making
+a change is named by $TODO_ACTOR, or else the user name.
+
+	todo backup [-incremental] [-to file]
+	todo restore-backup [-f] file...
+
+Backup writes the todo root's lists, with their tasks, attachments,
+settings, journals, trash, and archives, to a tar file, compressed
+according to its name: file.tar, file.tar.gz, or file.tar.zst (using
+the zstd command). The default is todo-backup-TIME.tar.gz in the current
+directory. Each file is copied whole, but files are copied one at a time,
+so a backup made while other programs are changing tasks may catch
+some of their changes and not others.
+With -incremental, backup writes only the changes since the last backup,
+found using the lists' journals. Restore-backup unpacks backups into
+the todo root: a full backup and then any incremental ones, in order.
+It refuses to unpack a full backup into a nonempty root unless given -f.
+
+	todo gc
+
+Gc archives the done tasks in the list and its sublists that have gone
+unchanged for longer than the list's archive setting, such as
+"archive: 180d", moving them into the list's _done directory, in a
+subdirectory for the month of their last update. Archived tasks no
+longer slow down queries and list windows; the -archived flag
+includes them in a query, as in todo -archived todo:done parser,
+showing each with its place in the archive, as in _done/2019-06/123.
+Lists without an archive setting are left alone.
+
+	todo capture title...
+	todo inbox [-l]
+
+Capture adds a task with the given one-line title to the inbox list,
+named by the inbox setting in the root list's configuration
+(default "inbox"), without opening an editor or applying a template.
+Inbox reviews the open tasks in the inbox one at a time, oldest first,
+asking for each the name of a list to refile it into; an empty answer
+leaves the task, d marks it done, and q stops. The first few lists are
+offered by number, those most recently refiled to first. With -l, or when
+standard input is not a terminal, inbox just lists the tasks.
+
+	todo refile [id list]
+
+Refile moves the task with the given ID from the list to another list,
+named relative to the root, remembering the list as recently used.
+Without arguments, refile reviews the inbox as todo inbox does.
+In acme, the Refile command, as in Refile work/infra, refiles the task
+in a single-task window in the same way.
+
+	todo dashboard
+
+Dashboard prints a summary of the list and each of its sublists:
+the number of open tasks, overdue tasks, and snoozed tasks waking up today,
+followed by the most urgent few tasks, those with the earliest due dates
+or else the highest priorities. In acme, the Dashboard command
+(or todo -a dashboard) opens the same summary in a window,
+where looking at a list or task name opens its window.
+
+	todo doctor [-a]
+
+Doctor checks that todo can work: that the todo root exists and is writable,
+that its file system supports the exclusive creates and renames todo uses
+in place of locks, that the editor for -e can be found, that every task file
+in the list and its sublists can be read and is named for its state,
+that no two tasks share an external ID, that hook settings name
+commands that exist, and that sync settings reach their trackers.
+With -a, doctor also checks that acme is running and the plumber is reachable.
+Each failed check is followed by a suggested fix.
+
+	todo today
+	todo week
+
+Today and week print an agenda for the list and its sublists, for today
+or for the seven days starting today: the open tasks due, scheduled
+(by a scheduled header, such as "scheduled: 2019-06-05 10:00"), or waking
+from a snooze on each day, all-day items first and then the rest
+in time order. Overdue tasks are listed first. In acme, the Agenda
+command (or todo -a agenda) opens the week's agenda in a window.
+
+	todo milestone [-all] [-v]
+	todo milestone close|reopen name
+
+A task's milestone header, as in "milestone: v1.0", adds it to a milestone.
+Milestone prints each open milestone with tasks in the list or its sublists:
+how many of its tasks are done, as a count and a percentage, and the sum of
+the estimate headers of its open tasks, with durations counted in hours.
+The -all flag includes closed milestones, and -v lists each milestone's
+open tasks. Milestone close closes a milestone, first listing any open tasks
+still in it and asking for confirmation, unless -yes is given, and milestone
+reopen reopens it. In acme, the Milestones command (or todo -a milestones)
+opens a window listing the open milestones and their open tasks.
+
+	todo waiting [who]
+
+A task handed to someone else can be set to wait on them, with a todo
+header such as "todo: waiting alice 2019-07-01", naming the person in a
+single word. Like a snoozed task, it drops out of queries until the date,
+which can be written in words, as in "waiting alice next fri", and is
+then rewritten as a date. Without a date, it stays out of queries until
+its todo header changes. Waiting lists the waiting tasks in the list and
+its sublists (or only those waiting on who), grouped by person, marking
+those whose date has passed.
+
+	todo muted
+
+Muting a task, with "todo: mute", closes it for good. A header such as
+"todo: mute until 2019-07-01" (or "mute until next month", rewritten
+as a date) instead silences it only until the date, like a snooze:
+the task stays open and resurfaces in queries when the date arrives.
+Muted lists the muted tasks in the list and its sublists, with when they
+were muted and until when, so that silenced tasks are not forgotten.
+
+	todo digest [-to addr] [-since when]
+
+Digest summarizes the list and its sublists: open tasks due or overdue,
+snoozed tasks waking up today, and tasks created or updated in the
+last day (or since -since when, as in activity). With -to, digest mails
+the summary instead of printing it, using the SMTP server named by the
+smtp setting in the root list's configuration, or else sendmail.
+It is meant to be run daily from cron.
+
+	todo remind [-daemon] [-poll duration]
+	todo reminders [-days n]
+
+Remind shows a desktop notification for each open task in the list
+and its sublists whose due time or reminder time has arrived or which
+wakes up from a snooze today. A due header holds a date, optionally
+followed by a time of day, as in "due: 2019-06-05 15:00". A due or
+scheduled header written in words, as in "due: next fri 15:00" or
+"due: jun 5", is saved as a date. A remind header gives a lead time,
+as in "remind: 30m" or "remind: 2d", to send the due reminder early,
+or a time of its own, as in "remind: 2019-06-04 09:00", which is also
+noted in the digest. A time relative to now, as in "remind: +2d" or
+"remind: +3h", or written in words, as in "remind: tomorrow 09:00",
+is saved as a date and time.
+With -daemon, remind keeps running, rereading the lists every minute
+(or -poll duration). Either way, each reminder is sent once: the ones
+sent are recorded in the root list's _state file, so that remind can
+also be run from cron.
+Notifications are shown using the program named by the notify setting
+in the root list's configuration (notify-send, osascript, growlnotify,
+or print), or else the first of those programs found.
+Reminders lists the reminders due in the next week (or -days n),
+including any sent in the last day, in time order.
+
+	todo stale
+
+Stale lists the open tasks in the list and its sublists that have gone
+too long without an update, according to the stale settings in the
+lists' configurations. A setting such as "stale: 30d" or "stale: 2w priority:p0"
+marks as stale the open tasks matching the query (default "all")
+with no update in that long; a list's settings apply to its sublists too.
+Stale tasks are also listed in the digest, and todo remind sends
+a notification when a task becomes stale.
+
+	todo open id...
+
+Open opens each task's URL in a web browser: its url header,
+as set by vcs-todo from Reviewed-on lines, or else the first URL
+in its history. The browser is the program named by the open setting
+in the root list's configuration, or else $BROWSER, or else the first
+of web, xdg-open, and open found. In acme, the Web command opens the URL
+for the task in a single-task window.
+
+	todo start id...
+	todo stop id...
+	todo timesheet [-since when]
+
+Start and stop track the time spent working on tasks.
+Start sets the task's started header to the current time,
+and stop clears it, adding the elapsed time to the task's
+spent header, as in "spent: 1h30m0s". In acme, the Start and Stop
+commands do the same for the task in a single-task window.
+Timesheet prints the time spent on each task in the list and its
+sublists during the last week (or since -since when, as in activity),
+followed by the time spent on each tag and the total.
+
+	todo cycletime [-v] [query]
+
+Todo records when each task's status changes: the first update after
+a task is created sets its began header, unless it closes the task,
+and marking it done sets its closed header, which reopening the task
+clears. (The started header belongs to time tracking, above.)
+Updates by import, sync, migrate, and vcs-todo do not set began,
+and tasks updated before began was recorded use their first update.
+Cycletime prints the distributions
+of the lead time, from creation to closing, and the cycle time,
+from the first update to closing, of the closed tasks matching the
+query (default todo:done). The -v flag adds each task's times.
+
+	todo serve [-http addr [-public]] [-9p] [-stdio]
+
+Serve makes the list and its sublists available to other programs.
+The -http flag serves a web interface on addr, such as localhost:8080.
+The address must be a loopback one unless -public is given, since
+anyone who can reach the server can read and change the tasks.
+Pages show changes made by other programs when reloaded, and the
+server accepts changes only from its own pages.
+Like the acme interface, it shows lists of open tasks with a search box,
+and for each task its headers and history, with buttons to Put changes
+and to mark the task Done, Mute it, or Snooze it until a later date.
+Each list also has an Atom feed of its recent activity, such as
+http://localhost:8080/work/feed.atom.
+
+The -9p flag serves the lists as a 9P file system, posted as todo
+in the current name space, like the plan9port services.
+Each list is a directory holding its sublists, a directory for
+each open task, and a file named new; writing a task template to new
+and reading it back creates a task and returns its ID.
+Each task directory holds a headers file, listing the task's headers
+and accepting new "key: value" lines; a body file, holding the
+task's history and accepting comments; and a ctl file, accepting
+the commands "done", "mute", and "snooze [when]".
+For example:
+
+	9p read todo/work/123/headers
+	echo 'snooze 3' | 9p write todo/work/123/ctl
+
+The -stdio flag serves JSON-RPC 2.0 requests read from standard input,
+writing responses to standard output, for use by editor plugins.
+The methods are search {list, query}, read {list, id},
+write {list, id, header, comment}, create {list, id, header, comment},
+and watch {list}, after which the server sends a "changed" notification
+with parameters {list, id} each time a task in the list changes.
+Serve exits when standard input reaches end of file.
+
+	todo pick [-exec command] [-finder program] [query]
+
+Pick chooses a task matching the query (default the list's query setting,
+or else "all"). When its output is not a terminal, pick just prints
+"id<TAB>title" lines, for piping to fzf, dmenu, or similar programs.
+Otherwise pick runs the -finder program on those lines (default fzf,
+if installed) or, with -finder internal or no fzf, a built-in finder
+in which typing narrows the tasks to those containing the typed
+characters in order. Without a terminal that stty can drive,
+as on Windows, the built-in finder instead lists the tasks numbered
+and reads a number, or text to narrow the list, a line at a time.
+Pick prints the chosen task's ID or,
+with -exec, runs the command with the ID as its argument,
+as in todo pick -exec 'todo -e'.
+
+	todo grep [-C n] [-i] pattern [query]
+
+Grep prints the lines of the tasks matching the query (default the
+list's query setting, or else "all"), in the list and its sublists,
+that match the regular expression pattern, in the form "list/id:line: text",
+where line is the line number in the task's file. The -C flag
+prints n lines of context around each match, as grep -C does,
+and the -i flag ignores case. The output suits acme and the
+grep modes of other editors.
+
+	todo plumb [-install]
+
+Plumb prints plumbing rules that send task references to the
+acme interface: window names, such as /todo/home/123, and
+mentions in other text, such as "todo home/123" in a mail message.
+With -install, plumb adds the rules to $HOME/lib/plumbing
+and reloads the running plumber.
+The acme interface also accepts plumb messages with a list attribute,
+such as list=work, to interpret task IDs relative to that list,
+and an addr attribute of the form search:query, such as
+addr=search:due:<today, to open a search of the list.
+In such queries, a comparison with a date written in words,
+as in due:<today or remind:<next-fri, compares with that date.
+
+In acme, the New command opens a window for creating a task.
+Arguments give the new task's title, as in New fix the build,
+and may begin with -template name, as in New -template bug,
+to start from the file _template/name in the list's directory
+instead of the usual blank template. The current selection,
+if any, becomes the task's description.
+
+In acme, the Bulk command opens a window for editing many tasks at once:
+the tasks in the selection or list window, or, given a query,
+as in Bulk priority:p0, the tasks matching the query.
+Get in a window started from a query reruns the query.
+Put in a Bulk window first lists the headers that will change
+on each task; a second Put, with the text unchanged, applies them.
+
+After a Put, the other acme windows showing the same tasks,
+or lists of them, are reloaded. A window with unsaved changes
+is not reloaded but instead gets a warning that it is stale.
+
+In acme, the Move command moves the task in a single-task window
+to another list, as in Move work/infra, naming the list relative
+to the root. A numeric task ID is replaced by the next unused ID
+in the new list, and the window is renamed to match.
+
+In acme, the Collapse command shows only the headers and latest
+update in a single-task window, noting how many earlier updates
+are hidden, and the Expand command shows the full history again.
+Collapsed windows are useful for tasks with long histories,
+such as those from vcs-todo with large diffs.
+
+An executable named todo-name in $PATH is a plugin, run by todo name
+as git runs git-name, with the remaining arguments. It runs with
+$TODO_DIR set to the todo root, $TODO_LIST to the list selected
+by -d, and $TODO_PROFILE to the profile, if any. Built-in commands
+take precedence over plugins, and plugins over queries, except that
+a name that is also a task ID or the name of a sublist
+is taken as a query, so that todo 123 shows task 123
+even when a plugin todo-123 exists.
+In acme, X name args runs the plugin with a JSON array of the window's
+task or the selected tasks on standard input, in the form used by
+todo serve -stdio, and shows its output.
+
+In acme, the Snooze command snoozes a task for a day or until a given
+date: an explicit date (2006-01-02), today or tomorrow, a weekday name
+such as monday, meaning the next such day, or next mon, meaning the one
+a week after that, a number of days or weeks, such as 3d, 2w, or +3,
+a span such as in 3 weeks, or a month and day, such as jun 5.
+A bare number, such as 3, snoozes for that many days, as Snooze 3d does;
+elsewhere, as in due headers, a bare number is not a date. Day names are
+English whatever the locale. The web, 9P, and terminal interfaces accept
+the same dates.
+
+In acme, the Preview command toggles showing, beneath each task
+in a list window, the first two lines of the task's latest comment.
+
+In acme, the Sort command orders a list window by the given keys,
+as for the -sort flag, or with no argument toggles between
+sorting by id and by title, or, in a window showing other columns,
+sorts by the next displayed column. The window keeps its order across Get.
+
+In acme, the Cols command sets the columns shown in a list window,
+as for the -cols flag, such as Cols due,priority,title.
+With no argument, Cols restores the list's cols setting.
+
+In acme, the Filter command refines the query shown in a list window,
+as in Filter -tag:x, and reloads the window.
+A filter on the todo header, as in Filter todo:done,
+replaces the implicit "all", so that done tasks can be shown.
+Filter with no arguments restores the window's original query.
+The Overdue command, in a list window's tag, likewise shows only the
+overdue tasks among those matching the window's original query.
+The Due command sets the due header of the task in a single-task window,
+the tasks in a bulk edit window, or the selected tasks in a list window,
+to a date written as for Snooze, optionally followed by a time of day,
+as in Due fri or Due 2019-06-05 15:00.
+
+The -open flag, as in todo -a -open work,personal/inbox, opens a window
+for each of the comma-separated lists, showing its all view,
+or tasks, as looking at their names would, in place of the window
+for the query; a query given as well gets a window too.
+This gives a standard layout of windows at startup.
+
+Todo -a records its open windows, with their queries, sorts, and columns,
+in the root list's _state file as they change. After acme restarts,
+todo -a -restore reopens the windows that were open when it exited,
+skipping tasks and lists that no longer exist.
+
+In acme, the Board command opens a board window for a list,
+showing its tasks in columns, one for each value of a header,
+such as Board status. Executing a column name, which the window
+adds to its tag, moves the selected tasks to that column by setting
+the header. A list's board setting, such as
+"board: status triage doing review done", gives the default header
+for Board and the columns to show even when they are empty.
 
 The exact acme/editor integration remains undocumented
 but is similar to acme mail or to rsc.io/github/issue.
@@ -22,6 +718,8 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -30,19 +728,90 @@ import (
 	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"rsc.io/todo/task"
 )
 
 var (
-	acmeFlag = flag.Bool("a", false, "open in new acme window")
-	editFlag = flag.Bool("e", false, "edit in system editor")
-	dirFlag  = flag.String("d", "", "todo subdirectory")
-	doneFlag = flag.Bool("done", false, "mark matching todos as done")
+	acmeFlag     = flag.Bool("a", false, "open in new acme window")
+	openFlag     = flag.String("open", "", "with -a, open windows for the comma-separated `lists` and tasks")
+	restoreFlag  = flag.Bool("restore", false, "with -a, reopen the windows open when acme last exited")
+	editFlag     = flag.Bool("e", false, "edit in system editor")
+	dirFlag      = flag.String("d", "", "todo subdirectory")
+	profileFlag  = flag.String("p", "", "use the todo root of profile `name` (default $TODO_PROFILE)")
+	doneFlag     = flag.Bool("done", false, "mark matching todos as done")
+	dryRunFlag   = flag.Bool("dry-run", false, "with -done, print the tasks that would be marked done but do not change them")
+	yesFlag      = flag.Bool("yes", false, "apply bulk edits and -done for several tasks without asking for confirmation")
+	colorFlag    = flag.String("color", "", "colorize query output: auto, always, or never")
+	noPagerFlag  = flag.Bool("no-pager", false, "do not pipe long output through $PAGER")
+	watchFlag    = flag.Bool("w", false, "print the query results again whenever the list changes (or, given an interval before the query, that often)")
+	limitFlag    = flag.Int("n", 0, "print at most `N` query results")
+	offsetFlag   = flag.Int("offset", 0, "skip the first `M` query results")
+	reverseFlag  = flag.Bool("reverse", false, "print query results in reverse order")
+	groupFlag    = flag.String("group", "", "print query results in sections by `header`")
+	caseFlag     = flag.Bool("case", false, "match query text and headers exactly, without folding case")
+	archivedFlag = flag.Bool("archived", false, "also search done tasks archived by todo gc")
+	rankFlag     = flag.Bool("rank", false, "sort query results by relevance (same as -sort rank)")
+	sortFlag     = flag.String("sort", "", "sort query results by `keys` (comma-separated id, title, or header names; -key reverses)")
+	colsFlag     = flag.String("cols", "", "print query results in `columns` (comma-separated id, title, or header names)")
 )
 
+// commands maps the names of todo subcommands, as in "todo import",
+// to their implementations. Each receives the arguments after its name.
+var commands = map[string]func(args []string){
+	"activity":       cmdActivity,
+	"alias":          cmdAlias,
+	"amend":          cmdAmend,
+	"backup":         cmdBackup,
+	"capture":        cmdCapture,
+	"cycletime":      cmdCycletime,
+	"dashboard":      cmdDashboard,
+	"delete":         cmdDelete,
+	"digest":         cmdDigest,
+	"doctor":         cmdDoctor,
+	"drafts":         cmdDrafts,
+	"empty-trash":    cmdEmptyTrash,
+	"eval":           cmdEval,
+	"export":         cmdExport,
+	"gc":             cmdGC,
+	"grep":           cmdGrep,
+	"import":         cmdImport,
+	"inbox":          cmdInbox,
+	"lists":          cmdLists,
+	"migrate":        cmdMigrate,
+	"milestone":      cmdMilestone,
+	"mklist":         cmdMklist,
+	"muted":          cmdMuted,
+	"open":           cmdOpen,
+	"pick":           cmdPick,
+	"plumb":          cmdPlumb,
+	"refile":         cmdRefile,
+	"remind":         cmdRemind,
+	"reminders":      cmdReminders,
+	"rename-header":  cmdRenameHeader,
+	"restore":        cmdRestore,
+	"restore-backup": cmdRestoreBackup,
+	"resume":         cmdResume,
+	"rmlist":         cmdRmlist,
+	"serve":          cmdServe,
+	"show":           cmdShow,
+	"slim":           cmdSlim,
+	"stale":          cmdStale,
+	"start":          cmdStart,
+	"stop":           cmdStop,
+	"sync":           cmdSync,
+	"timesheet":      cmdTimesheet,
+	"today":          cmdToday,
+	"trash":          cmdTrash,
+	"ui":             cmdUI,
+	"waiting":        cmdWaiting,
+	"week":           cmdWeek,
+}
+
 func usage() {
 	fmt.Fprintf(os.Stderr, `usage: todo [-a] [-e] <query>
+       todo <command> [args]
 
 If query is a single task ID, prints the full history for the task.
 Otherwise, prints a table of matching results.
@@ -56,34 +825,99 @@ func main() {
 	flag.Parse()
 	log.SetFlags(0)
 	log.SetPrefix("todo: ")
+	registerQueryFuncs()
+	task.ReserveIDs(func(id string) bool {
+		return commands[id] != nil || findPlugin(id) != ""
+	})
+	if *profileFlag != "" {
+		if err := task.SetProfile(*profileFlag); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	if flag.NArg() == 0 && !*acmeFlag {
+	if flag.NArg() == 0 && !*acmeFlag && defaultQuery(taskList(*dirFlag)) == "all" {
 		usage()
 	}
+	if *openFlag != "" && !*acmeFlag {
+		log.Fatal("-open requires -a")
+	}
+	if *restoreFlag && !*acmeFlag {
+		log.Fatal("-restore requires -a")
+	}
 
 	if *acmeFlag {
 		runAcme()
 	}
 
+	if cmd := commands[flag.Arg(0)]; cmd != nil {
+		cmd(flag.Args()[1:])
+		return
+	}
+	if file := findPlugin(flag.Arg(0)); file != "" && !namesTaskOrList(taskList(*dirFlag), flag.Arg(0)) {
+		runPlugin(file, flag.Args()[1:])
+	}
+
 	q := strings.Join(flag.Args(), " ")
+	var every time.Duration
+	if *watchFlag {
+		if *editFlag || *doneFlag {
+			log.Fatal("-w cannot be used with -e or -done")
+		}
+		// An optional interval comes before the query, as in todo -w 30s due:*.
+		if d, err := task.ParseLead(flag.Arg(0)); err == nil && d > 0 && flag.NArg() > 0 {
+			every = d
+			q = strings.Join(flag.Args()[1:], " ")
+		}
+	}
 	l := taskList(*dirFlag)
+	if q == "" {
+		q = defaultQuery(l)
+	}
+
+	if *watchFlag {
+		err := watch(l, "todo "+q, every, func(w io.Writer) error {
+			if id, err := resolveRef(l, q); err == nil && l.Exists(id) {
+				_, err := showTask(w, l, id)
+				return err
+			}
+			return showQuery(w, l, q, stdoutQueryOptions())
+		})
+		log.Fatal(err)
+	}
+
+	if *editFlag || *doneFlag && !*dryRunFlag {
+		l = writableList(*dirFlag)
+	}
 
 	if *editFlag && q == "new" {
-		editTask(l, []byte(createTemplate), nil)
+		editTask(l, []byte(createTemplate), nil, "")
 		return
 	}
 
-	if t, err := l.Read(q); err == nil {
+	t, err := readTask(l, q)
+	if errors.Is(err, task.ErrMalformed) {
+		// q names a task, but its file is damaged.
+		log.Fatal(err)
+	}
+	if err == nil {
+		q = t.ID()
+		if *editFlag || *doneFlag {
+			notePrevious(l, t)
+		}
 		if *editFlag {
 			var buf bytes.Buffer
 			issue, err := showTask(&buf, l, q)
 			if err != nil {
 				log.Fatal(err)
 			}
-			editTask(l, buf.Bytes(), issue)
+			editTask(l, buf.Bytes(), issue, "")
 			return
 		}
 		if *doneFlag {
+			if *dryRunFlag {
+				fmt.Printf("%s\t%s\n", t.ID(), t.Title())
+				return
+			}
 			if t.Header("todo") != "done" {
 				err = taskList(*dirFlag).Write(t, time.Now(), map[string]string{"todo": "done"}, nil)
 				if err != nil {
@@ -92,14 +926,16 @@ func main() {
 			}
 			return
 		}
-		if _, err := showTask(os.Stdout, l, q); err != nil {
+		var buf bytes.Buffer
+		if _, err := showTask(&buf, l, q); err != nil {
 			log.Fatal(err)
 		}
+		page(buf.Bytes())
 		return
 	}
 
 	if *editFlag {
-		all, err := taskList(*dirFlag).Search(q)
+		all, err := search(taskList(*dirFlag), q)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -112,24 +948,45 @@ func main() {
 	}
 
 	if *doneFlag {
-		all, err := taskList(*dirFlag).Search(q)
+		all, err := search(taskList(*dirFlag), q)
 		if err != nil {
 			log.Fatal(err)
 		}
+		var ids []string
+		var buf bytes.Buffer
 		for _, t := range all {
 			if t.Header("todo") != "done" {
-				err = taskList(*dirFlag).Write(t, time.Now(), map[string]string{"todo": "done"}, nil)
-				if err != nil {
-					log.Print(err)
-				}
+				ids = append(ids, t.ID())
+				fmt.Fprintf(&buf, "%s\t%s\n", t.ID(), t.Title())
+			}
+		}
+		if *dryRunFlag {
+			os.Stdout.Write(buf.Bytes())
+			return
+		}
+		if len(ids) > 1 && !*yesFlag {
+			os.Stderr.Write(buf.Bytes())
+			if !confirm(fmt.Sprintf("mark %d tasks done?", len(ids))) {
+				log.Fatal("no tasks marked done")
+			}
+		}
+		results, err := l.BulkWrite(ids, time.Now(), map[string]string{"todo": "done"}, nil)
+		for _, r := range results {
+			if r.Err != nil {
+				log.Printf("%s: %v", r.ID, r.Err)
 			}
 		}
+		if err != nil {
+			log.Fatal(err)
+		}
 		return
 	}
 
-	if err := showQuery(os.Stdout, l, q); err != nil {
+	var buf bytes.Buffer
+	if err := showQuery(&buf, l, q, stdoutQueryOptions()); err != nil {
 		log.Fatal(err)
 	}
+	page(buf.Bytes())
 }
 
 var createTemplate = `title: ` + `
@@ -138,6 +995,70 @@ var createTemplate = `title: ` + `
 
 `
 
+// fillTemplate returns the task template text with its title header
+// set to title and its description placeholder replaced by body.
+// Empty title and body leave the template unchanged.
+func fillTemplate(text, title, body string) string {
+	if title != "" {
+		lines := strings.SplitAfter(text, "\n")
+		found := false
+		for i, line := range lines {
+			if strings.TrimSpace(line) == "" {
+				break
+			}
+			if j := strings.Index(line, ":"); j >= 0 && strings.EqualFold(strings.TrimSpace(line[:j]), "title") {
+				lines[i] = "title: " + title + "\n"
+				found = true
+				break
+			}
+		}
+		text = strings.Join(lines, "")
+		if !found {
+			text = "title: " + title + "\n" + text
+		}
+	}
+	if body != "" {
+		if strings.Contains(text, "<describe task here>") {
+			text = strings.Replace(text, "<describe task here>", body, 1)
+		} else {
+			text = strings.TrimRight(text, "\n") + "\n\n" + body + "\n"
+		}
+	}
+	return text
+}
+
+// search returns the tasks in l matching the query q,
+// matching case exactly if the -case flag is set.
+func search(l *task.List, q string) ([]*task.Task, error) {
+	return searchContext(context.Background(), l, q)
+}
+
+// searchContext is like search but gives up once ctx is done.
+func searchContext(ctx context.Context, l *task.List, q string) ([]*task.Task, error) {
+	var tasks []*task.Task
+	var err error
+	if *caseFlag {
+		tasks, err = l.SearchExactContext(ctx, q)
+	} else {
+		tasks, err = l.SearchContext(ctx, q)
+	}
+	if err != nil || !*archivedFlag {
+		return tasks, err
+	}
+	archived, err := l.SearchArchived(q, *caseFlag)
+	return append(tasks, archived...), err
+}
+
+// defaultQuery returns the query to run when none is given,
+// which acme's all window shows too: the list's query setting,
+// or else all.
+func defaultQuery(l *task.List) string {
+	if q := l.Config().Get("query"); q != "" {
+		return q
+	}
+	return "all"
+}
+
 func showTask(w io.Writer, l *task.List, id string) (*task.Task, error) {
 	t, err := l.Read(id)
 	if err != nil {
@@ -147,18 +1068,378 @@ func showTask(w io.Writer, l *task.List, id string) (*task.Task, error) {
 	return t, nil
 }
 
-func showQuery(w io.Writer, l *task.List, q string) error {
-	all, err := l.Search(q)
+// queryOptions controls how showQuery formats its results.
+// A nil *queryOptions means plain "id\ttitle" lines,
+// as acme windows and other programs expect.
+type queryOptions struct {
+	align   bool     // pad IDs to align titles
+	width   int      // if > 0, truncate lines to width
+	color   bool     // colorize tasks by state
+	sort    string   // sort key, as for task.Compare; "" means title, "rank" means task.Rank
+	reverse bool     // reverse the sorted results
+	offset  int      // skip the first offset results
+	limit   int      // if > 0, print at most limit results
+	group   string   // if set, group results by this header
+	preview int      // if > 0, show up to preview lines of each task's latest comment
+	snippet int      // if > 0, show up to snippet lines of each task matching the query's text terms
+	cols    []string // if set, the columns to show, as returned by parseCols; nil means id and title
+}
+
+// parseCols parses a comma-separated list of columns to show
+// for each task, such as "id,due,priority,title".
+// Each column is id, title, or a header name.
+// The task ID is always the first column, so that
+// acme and other programs can find it at the start of each line.
+// parseCols returns nil if spec lists no columns.
+func parseCols(spec string) []string {
+	if strings.Trim(spec, ", ") == "" {
+		return nil
+	}
+	cols := []string{"id"}
+	for _, c := range strings.Split(spec, ",") {
+		if c = strings.ToLower(strings.TrimSpace(c)); c != "" && c != "id" {
+			cols = append(cols, c)
+		}
+	}
+	return cols
+}
+
+// colsSortKey returns the sort key for showing tasks in the columns cols:
+// the displayed columns, in order, after the ID.
+func colsSortKey(cols []string) string {
+	if len(cols) <= 1 {
+		return "id"
+	}
+	return strings.Join(cols[1:], ",")
+}
+
+// colValues returns the values of the columns cols for t,
+// using "-" for missing headers.
+func colValues(t *task.Task, cols []string) []string {
+	var vals []string
+	for _, c := range cols {
+		var v string
+		switch c {
+		case "id":
+			v = t.ID()
+		case "title":
+			v = t.Title()
+		default:
+			v = t.Header(c)
+		}
+		if v == "" {
+			v = "-"
+		}
+		vals = append(vals, v)
+	}
+	return vals
+}
+
+// stdoutQueryOptions returns the options for printing
+// query results to standard output, according to
+// the -color flag or color setting and whether
+// standard output is a terminal.
+func stdoutQueryOptions() *queryOptions {
+	mode := *colorFlag
+	if mode == "" {
+		mode = taskList(".").Config().Get("color")
+	}
+	tty := isTerminal(os.Stdout)
+	sortKey := *sortFlag
+	if *rankFlag {
+		sortKey = "rank"
+	}
+	if sortKey == "" {
+		sortKey = taskList(*dirFlag).Config().Get("sort")
+	}
+	cols := *colsFlag
+	if cols == "" {
+		cols = taskList(*dirFlag).Config().Get("cols")
+	}
+	opt := &queryOptions{
+		sort:    sortKey,
+		reverse: *reverseFlag,
+		offset:  *offsetFlag,
+		limit:   *limitFlag,
+		group:   strings.ToLower(*groupFlag),
+		cols:    parseCols(cols),
+	}
+	switch mode {
+	case "", "auto":
+		opt.color = tty
+	case "always":
+		opt.color = true
+	case "never":
+	default:
+		log.Fatalf("invalid color mode %q: want auto, always, or never", mode)
+	}
+	if tty {
+		opt.align = true
+		opt.width, _ = termSize()
+		opt.snippet = 2
+	}
+	return opt
+}
+
+func showQuery(w io.Writer, l *task.List, q string, opt *queryOptions) error {
+	all, err := search(l, q)
 	if err != nil {
 		return err
 	}
-	sort.Sort(tasksByTitle(all))
+	if opt == nil {
+		opt = new(queryOptions)
+	}
+	if opt.sort == "" && opt.cols != nil {
+		task.Sort(all, colsSortKey(opt.cols))
+	} else if opt.sort == "rank" {
+		sort.Sort(tasksByTitle(all))
+		task.Rank(all, q, time.Now())
+	} else if opt.sort != "" {
+		task.Sort(all, opt.sort)
+	} else {
+		sort.Sort(tasksByTitle(all))
+	}
+	if opt.reverse {
+		for i, j := 0, len(all)-1; i < j; i, j = i+1, j-1 {
+			all[i], all[j] = all[j], all[i]
+		}
+	}
+	if opt.offset > 0 {
+		if opt.offset > len(all) {
+			opt.offset = len(all)
+		}
+		all = all[opt.offset:]
+	}
+	if opt.limit > 0 && opt.limit < len(all) {
+		all = all[:opt.limit]
+	}
+	fields := func(t *task.Task) []string {
+		if opt.cols == nil {
+			return []string{t.ID(), t.Title()}
+		}
+		return colValues(t, opt.cols)
+	}
+	var widths []int
 	for _, t := range all {
-		fmt.Fprintf(w, "%v\t%v\n", t.ID(), t.Title())
+		for i, f := range fields(t) {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if n := utf8.RuneCountInString(f); opt.align && n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	idWidth := 0
+	if len(widths) > 0 {
+		idWidth = widths[0]
+	}
+	today := time.Now().Format("2006-01-02")
+	states := l.States()
+	terms := task.TextTerms(q)
+	show := func(t *task.Task) {
+		f := fields(t)
+		if opt.align {
+			for i := range f[:len(f)-1] {
+				f[i] += strings.Repeat(" ", widths[i]-utf8.RuneCountInString(f[i]))
+			}
+		}
+		line := strings.Join(f, "\t")
+		if opt.align {
+			line = strings.Join(f, "  ")
+		}
+		if r := []rune(line); opt.width > 0 && len(r) > opt.width {
+			line = string(r[:opt.width-1]) + "…"
+		}
+		if opt.color {
+			if c := taskColor(t, today, states); c != "" {
+				line = c + line + "\x1b[m"
+			}
+		}
+		fmt.Fprintf(w, "%s\n", line)
+		indent := "\t"
+		if opt.align {
+			indent = strings.Repeat(" ", idWidth+2)
+		}
+		for _, p := range previewLines(t, opt.preview) {
+			line := indent + p
+			if r := []rune(line); opt.width > 0 && len(r) > opt.width {
+				line = string(r[:opt.width-1]) + "…"
+			}
+			fmt.Fprintf(w, "%s\n", line)
+		}
+		for _, s := range snippetLines(t, terms, opt.snippet) {
+			line := indent + "» " + s
+			if r := []rune(line); opt.width > 0 && len(r) > opt.width {
+				line = string(r[:opt.width-1]) + "…"
+			}
+			if opt.color {
+				line = highlight(line, terms, "\x1b[1;33m", "\x1b[m")
+			}
+			fmt.Fprintf(w, "%s\n", line)
+		}
+	}
+
+	if opt.group == "" {
+		for _, t := range all {
+			show(t)
+		}
+		return nil
+	}
+	values, groups := groupTasks(all, opt.group)
+	if opt.group == "todo" {
+		sortStates(values, states)
+	}
+	for i, v := range values {
+		if i > 0 {
+			fmt.Fprintf(w, "\n")
+		}
+		name := v
+		if name == "" {
+			name = "(none)"
+		}
+		heading := fmt.Sprintf("%s: %s (%d)", opt.group, name, len(groups[v]))
+		if opt.color {
+			heading = "\x1b[1;4m" + heading + "\x1b[m"
+		}
+		fmt.Fprintf(w, "%s\n", heading)
+		for _, t := range groups[v] {
+			show(t)
+		}
+	}
+	return nil
+}
+
+// previewLines returns up to n non-blank lines
+// from the latest comment on t.
+func previewLines(t *task.Task, n int) []string {
+	if n <= 0 {
+		return nil
+	}
+	updates := t.Updates()
+	for i := len(updates) - 1; i >= 0; i-- {
+		var lines []string
+		for _, line := range strings.Split(updates[i].Comment, "\n") {
+			if line = strings.TrimSpace(line); line != "" && len(lines) < n {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) > 0 {
+			return lines
+		}
 	}
 	return nil
 }
 
+// snippetLines returns up to n lines from the comments of t
+// containing any of the text terms, as returned by task.TextTerms,
+// most recent first.
+func snippetLines(t *task.Task, terms []string, n int) []string {
+	if n <= 0 || len(terms) == 0 {
+		return nil
+	}
+	var lines []string
+	updates := t.Updates()
+	for i := len(updates) - 1; i >= 0 && len(lines) < n; i-- {
+		for _, line := range strings.Split(updates[i].Comment, "\n") {
+			if line = strings.TrimSpace(line); line != "" && len(lines) < n && task.MatchTerms(line, terms) != nil {
+				lines = append(lines, line)
+			}
+		}
+	}
+	return lines
+}
+
+// highlight returns line with the text matching terms,
+// as returned by task.TextTerms, wrapped in start and end.
+func highlight(line string, terms []string, start, end string) string {
+	ranges := task.MatchTerms(line, terms)
+	if ranges == nil {
+		return line
+	}
+	var b strings.Builder
+	last := 0
+	for _, r := range ranges {
+		b.WriteString(line[last:r[0]])
+		b.WriteString(start + line[r[0]:r[1]] + end)
+		last = r[1]
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
+
+// groupTasks groups tasks by the value of the header key.
+// It returns the distinct values, sorted but with "" (no header) last,
+// and a map from each value to its tasks, in their original order.
+func groupTasks(tasks []*task.Task, key string) (values []string, groups map[string][]*task.Task) {
+	groups = make(map[string][]*task.Task)
+	for _, t := range tasks {
+		v := t.Header(key)
+		if groups[v] == nil {
+			values = append(values, v)
+		}
+		groups[v] = append(groups[v], t)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		// Tasks without the header go last.
+		if (values[i] == "") != (values[j] == "") {
+			return values[j] == ""
+		}
+		return values[i] < values[j]
+	})
+	return values, groups
+}
+
+// sortStates sorts the todo header values by the workflow order
+// in states, leaving values for other states after those,
+// in their original order.
+func sortStates(values []string, states *task.States) {
+	rank := make(map[string]int)
+	for i, s := range states.Order {
+		rank[s] = i + 1
+	}
+	r := func(v string) int {
+		f := strings.Fields(v)
+		if len(f) == 0 || rank[f[0]] == 0 {
+			return len(states.Order) + 1
+		}
+		return rank[f[0]]
+	}
+	sort.SliceStable(values, func(i, j int) bool { return r(values[i]) < r(values[j]) })
+}
+
+// stateColors maps the colors allowed in state-color settings
+// to ANSI escape sequences.
+var stateColors = map[string]string{
+	"red":     "\x1b[31m",
+	"green":   "\x1b[32m",
+	"yellow":  "\x1b[33m",
+	"blue":    "\x1b[34m",
+	"magenta": "\x1b[35m",
+	"cyan":    "\x1b[36m",
+	"bold":    "\x1b[1m",
+	"dim":     "\x1b[2m",
+}
+
+// taskColor returns the ANSI escape sequence for displaying t:
+// the color set for its state by a state-color setting, if any, or else
+// red if overdue, dim if closed, snoozed, waiting, or in a hidden state,
+// and bold if it has a priority.
+func taskColor(t *task.Task, today string, states *task.States) string {
+	if c := stateColors[states.Color(t.State())]; c != "" {
+		return c
+	}
+	switch {
+	case t.Done() || t.Deferred(time.Now()) || states.Hidden(t.State()):
+		return "\x1b[2m"
+	case t.Header("due") != "" && t.Header("due") < today:
+		return "\x1b[31m"
+	case t.Header("priority") != "" && t.Header("priority") != "low":
+		return "\x1b[1m"
+	}
+	return ""
+}
+
 type tasksByTitle []*task.Task
 
 func (x tasksByTitle) Len() int      { return len(x) }
@@ -0,0 +1,85 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"rsc.io/todo/task"
+)
+
+// writeMarkdown writes the tasks to w as a Markdown report,
+// in sections by opt.group if set. Each task is shown with its
+// title, a table of its headers, and its latest comment.
+func writeMarkdown(w io.Writer, tasks []*task.Task, opt *exportOptions) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# %s\n\n", mdEscape(opt.query))
+
+	if opt.group == "" {
+		for _, t := range tasks {
+			writeMarkdownTask(bw, t, opt.ref(t), "##")
+		}
+		return bw.Flush()
+	}
+
+	values, groups := groupTasks(tasks, opt.group)
+	for _, v := range values {
+		name := v
+		if name == "" {
+			name = "(none)"
+		}
+		fmt.Fprintf(bw, "## %s: %s (%d)\n\n", opt.group, mdEscape(name), len(groups[v]))
+		for _, t := range groups[v] {
+			writeMarkdownTask(bw, t, opt.ref(t), "###")
+		}
+	}
+	return bw.Flush()
+}
+
+func writeMarkdownTask(w io.Writer, t *task.Task, ref, heading string) {
+	fmt.Fprintf(w, "%s %s: %s\n\n", heading, ref, mdEscape(t.Title()))
+	fmt.Fprintf(w, "| header | value |\n|---|---|\n")
+	fmt.Fprintf(w, "| created | %s |\n", t.Header("ctime"))
+	fmt.Fprintf(w, "| updated | %s |\n", t.Header("mtime"))
+	for _, k := range t.Keys() {
+		if k == "title" || k == "done" {
+			continue
+		}
+		fmt.Fprintf(w, "| %s | %s |\n", k, strings.Replace(mdEscape(t.Header(k)), "|", `\|`, -1))
+	}
+	fmt.Fprintf(w, "\n")
+
+	updates := t.Updates()
+	for i := len(updates) - 1; i >= 0; i-- {
+		u := updates[i]
+		if u.Comment == "" {
+			continue
+		}
+		fmt.Fprintf(w, "Latest comment (%s):\n\n", u.Time)
+		for _, line := range strings.Split(u.Comment, "\n") {
+			fmt.Fprintf(w, "> %s\n", line)
+		}
+		fmt.Fprintf(w, "\n")
+		break
+	}
+}
+
+var mdEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"_", `\_`,
+	"`", "\\`",
+	"[", `\[`,
+	"]", `\]`,
+	"<", `\<`,
+)
+
+// mdEscape escapes s for use as Markdown inline text.
+func mdEscape(s string) string {
+	return mdEscaper.Replace(s)
+}
@@ -0,0 +1,125 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+// A setList is a flag.Value collecting repeated -set key=value flags.
+type setList []string
+
+func (s *setList) String() string { return strings.Join(*s, " ") }
+
+func (s *setList) Set(kv string) error {
+	i := strings.Index(kv, "=")
+	if i <= 0 || strings.ContainsAny(kv[:i], ": \t\n") || strings.Contains(kv[i+1:], "\n") {
+		return fmt.Errorf("invalid setting %q: want key=value", kv)
+	}
+	*s = append(*s, kv)
+	return nil
+}
+
+func cmdMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	var sets setList
+	fs.Var(&sets, "set", "set header `key=value` (repeatable); an empty value removes the header, and a value $other copies header other")
+	dryRun := fs.Bool("n", false, "print the changes that would be made but do not make them")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo migrate [-n] -set key=value... query\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 || len(sets) == 0 {
+		fs.Usage()
+	}
+
+	l := taskList(*dirFlag)
+	if !*dryRun {
+		l = writableList(*dirFlag)
+	}
+	l.Importing()
+	tasks, err := search(l, strings.Join(fs.Args(), " "))
+	if err != nil {
+		log.Fatal(err)
+	}
+	task.Sort(tasks, "id")
+
+	failed := false
+	n := 0
+	for _, t := range tasks {
+		hdr := migrateHeaders(t, sets)
+		if len(hdr) == 0 {
+			continue
+		}
+		n++
+		if *dryRun {
+			fmt.Printf("%s\n", taskRef(l, t))
+			var keys []string
+			for k := range hdr {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if old := t.Header(k); old != "" {
+					fmt.Printf("\t- %s: %s\n", k, old)
+				}
+				if hdr[k] != "" {
+					fmt.Printf("\t+ %s: %s\n", k, hdr[k])
+				}
+			}
+			continue
+		}
+		if t.Done() {
+			// Keep done tasks done: Write reopens them otherwise.
+			if _, ok := hdr["todo"]; !ok {
+				hdr["todo"] = t.Header("todo")
+			}
+		}
+		if err := l.Write(t, time.Now(), hdr, nil); err != nil {
+			log.Printf("%s: %v", taskRef(l, t), err)
+			failed = true
+		}
+	}
+	if *dryRun {
+		fmt.Printf("%d of %d tasks would change\n", n, len(tasks))
+	} else {
+		fmt.Printf("%d of %d tasks changed\n", n, len(tasks))
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// migrateHeaders returns the header changes that apply sets,
+// a list of key=value settings, to t, omitting those that would
+// not change it. A value of the form $key stands for t's value
+// of the header key before any of the changes.
+func migrateHeaders(t *task.Task, sets []string) map[string]string {
+	hdr := make(map[string]string)
+	for _, kv := range sets {
+		i := strings.Index(kv, "=")
+		k, v := strings.ToLower(kv[:i]), strings.TrimSpace(kv[i+1:])
+		if strings.HasPrefix(v, "$") && len(v) > 1 {
+			v = t.Header(v[1:])
+		}
+		hdr[k] = v
+	}
+	for k, v := range hdr {
+		if t.Header(k) == v {
+			delete(hdr, k)
+		}
+	}
+	return hdr
+}
@@ -0,0 +1,238 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"9fans.net/go/acme"
+	"rsc.io/todo/task"
+)
+
+// Milestones.
+//
+// A task's milestone header names the milestone it belongs to,
+// as in "milestone: v1.0". Milestones exist as long as tasks name them;
+// there is nothing to create. Closing a milestone records the time
+// it was closed in the root list's _state file, under the key
+// "milestone name", so that the same milestone can collect tasks
+// from any list. Closed milestones are left out of listings
+// unless asked for.
+
+func cmdMilestone(args []string) {
+	fs := flag.NewFlagSet("milestone", flag.ExitOnError)
+	allFlag := fs.Bool("all", false, "include closed milestones")
+	verbose := fs.Bool("v", false, "list the open tasks in each milestone")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo milestone [-all] [-v]\n")
+		fmt.Fprintf(os.Stderr, "       todo milestone close|reopen name\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	l := taskList(*dirFlag)
+	switch fs.Arg(0) {
+	case "":
+		var buf bytes.Buffer
+		if err := writeMilestones(&buf, l, *allFlag, *verbose); err != nil {
+			log.Fatal(err)
+		}
+		page(buf.Bytes())
+
+	case "close", "reopen":
+		if fs.NArg() != 2 || *allFlag || *verbose {
+			fs.Usage()
+		}
+		name := fs.Arg(1)
+		if fs.Arg(0) == "reopen" {
+			if err := reopenMilestone(name); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+		m, err := findMilestone(l, name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(m.open) > 0 {
+			fmt.Fprintf(os.Stderr, "todo: milestone %s has %d open task%s:\n", name, len(m.open), suffix(len(m.open)))
+			for _, t := range m.open {
+				fmt.Fprintf(os.Stderr, "\t%s\t%s\n", t.ref, t.t.Title())
+			}
+			if !*yesFlag && !confirm(fmt.Sprintf("close milestone %s anyway?", name)) {
+				log.Fatal("milestone not closed")
+			}
+		}
+		if err := closeMilestone(name, time.Now()); err != nil {
+			log.Fatal(err)
+		}
+
+	default:
+		fs.Usage()
+	}
+}
+
+// A milestone summarizes the tasks naming a milestone.
+type milestone struct {
+	name      string
+	total     int             // number of tasks
+	open      []milestoneTask // open tasks, in ref order
+	remaining float64         // sum of the open tasks' estimates
+	estimated bool            // whether any open task has an estimate
+	closed    string          // time the milestone was closed, or ""
+}
+
+// A milestoneTask is an open task in a milestone.
+type milestoneTask struct {
+	ref string // task name relative to the listing's list
+	t   *task.Task
+}
+
+// milestoneKey returns the root state key recording
+// when the named milestone was closed.
+func milestoneKey(name string) string {
+	return "milestone " + name
+}
+
+// milestones returns the milestones named by tasks in l and its sublists,
+// sorted by name.
+func milestones(l *task.List) ([]*milestone, error) {
+	byName := make(map[string]*milestone)
+	for _, sub := range allLists(l) {
+		open, err := sub.All()
+		if err != nil {
+			return nil, err
+		}
+		done, err := sub.Done()
+		if err != nil {
+			return nil, err
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(sub.Name(), l.Name()), "/")
+		for _, t := range append(open, done...) {
+			name := t.Header("milestone")
+			if name == "" {
+				continue
+			}
+			m := byName[name]
+			if m == nil {
+				m = &milestone{name: name}
+				byName[name] = m
+			}
+			m.total++
+			if t.Done() {
+				continue
+			}
+			m.open = append(m.open, milestoneTask{path.Join(rel, t.ID()), t})
+			if v, ok := evalValue(t.Header("estimate")); ok {
+				m.remaining += v
+				m.estimated = true
+			}
+		}
+	}
+
+	root := taskList("")
+	var list []*milestone
+	for _, m := range byName {
+		m.closed = root.State(milestoneKey(m.name))
+		sort.Slice(m.open, func(i, j int) bool { return m.open[i].ref < m.open[j].ref })
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return list, nil
+}
+
+// findMilestone returns the named milestone in l and its sublists.
+func findMilestone(l *task.List, name string) (*milestone, error) {
+	list, err := milestones(l)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range list {
+		if m.name == name {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("no tasks in milestone %s", name)
+}
+
+// closeMilestone records that the named milestone was closed at now.
+func closeMilestone(name string, now time.Time) error {
+	root := taskList("")
+	if s := root.State(milestoneKey(name)); s != "" {
+		return fmt.Errorf("milestone %s already closed at %s", name, s)
+	}
+	return root.SetState(milestoneKey(name), now.Format("2006-01-02 15:04:05"))
+}
+
+// reopenMilestone forgets that the named milestone was closed.
+func reopenMilestone(name string) error {
+	root := taskList("")
+	if root.State(milestoneKey(name)) == "" {
+		return fmt.Errorf("milestone %s not closed", name)
+	}
+	return root.SetState(milestoneKey(name), "")
+}
+
+// writeMilestones writes to w a line for each open milestone
+// (or, if all is set, each milestone) in l and its sublists,
+// giving its completion and the sum of its open tasks' estimates,
+// followed, if verbose, by its open tasks. Task names are relative
+// to l, so that they can be looked at in an acme window for l.
+func writeMilestones(w io.Writer, l *task.List, all, verbose bool) error {
+	list, err := milestones(l)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, m := range list {
+		if m.closed != "" && !all {
+			continue
+		}
+		n++
+		done := m.total - len(m.open)
+		fmt.Fprintf(w, "%s\t%d/%d done\t%d%%", m.name, done, m.total, done*100/m.total)
+		if m.estimated {
+			fmt.Fprintf(w, "\t%s remaining", formatEval(m.remaining))
+		}
+		if m.closed != "" {
+			fmt.Fprintf(w, "\tclosed %s", m.closed)
+		}
+		fmt.Fprintf(w, "\n")
+		if verbose {
+			for _, t := range m.open {
+				fmt.Fprintf(w, "\t%s\t%s\n", t.ref, t.t.Title())
+			}
+		}
+	}
+	if n == 0 && all {
+		fmt.Fprintf(w, "no milestones\n")
+	} else if n == 0 {
+		fmt.Fprintf(w, "no open milestones\n")
+	}
+	return nil
+}
+
+func openMilestones(l *task.List) {
+	open(&awin{
+		mode: modeMilestones,
+		name: adir(l) + "milestones",
+		tag:  "New Get Search",
+	})
+}
+
+func (w *awin) ExecMilestones() {
+	if acme.Show(adir(w.list())+"milestones") == nil {
+		openMilestones(w.list())
+	}
+}
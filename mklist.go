@@ -0,0 +1,48 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"rsc.io/todo/task"
+)
+
+func cmdMklist(args []string) {
+	fs := flag.NewFlagSet("mklist", flag.ExitOnError)
+	about := fs.String("about", "", "describe the list with `text`, saved in its _about file")
+	sortKeys := fs.String("sort", "", "set the list's default sort order to `keys`")
+	query := fs.String("query", "", "set the list's default `query`")
+	readonly := fs.Bool("readonly", false, "mark the list read-only")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo mklist [-about text] [-sort keys] [-query query] [-readonly] name\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+	}
+	l, err := task.NewList(fs.Arg(0), *about)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, kv := range [][2]string{{"sort", *sortKeys}, {"query", *query}} {
+		if kv[1] != "" {
+			if err := l.AddConfig(kv[0], kv[1]); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	if *readonly {
+		if err := l.AddConfig("readonly", "true"); err != nil {
+			log.Fatal(err)
+		}
+	}
+	fmt.Println(l.Name())
+}
@@ -0,0 +1,109 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+func cmdMuted(args []string) {
+	fs := flag.NewFlagSet("muted", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo muted\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+	}
+	var buf bytes.Buffer
+	if err := writeMuted(&buf, taskList(*dirFlag), time.Now()); err != nil {
+		log.Fatal(err)
+	}
+	page(buf.Bytes())
+}
+
+// writeMuted writes to w the muted tasks in l and its sublists,
+// for and until each was muted, least recently muted first.
+// Tasks muted until a date that has passed, and so have
+// resurfaced in queries, are marked as such.
+// Task names are relative to l.
+func writeMuted(w io.Writer, l *task.List, now time.Time) error {
+	type entry struct {
+		ref   string
+		t     *task.Task
+		since string
+		until string
+	}
+	var entries []entry
+	for _, sub := range allLists(l) {
+		open, err := sub.All()
+		if err != nil {
+			return err
+		}
+		done, err := sub.Done()
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(sub.Name(), l.Name()), "/")
+		for _, t := range append(open, done...) {
+			s := t.Header("todo")
+			until, ok := task.ParseMuteUntil(s)
+			if s != "mute" && !ok {
+				continue
+			}
+			entries = append(entries, entry{path.Join(rel, t.ID()), t, mutedSince(t), until})
+		}
+	}
+	if len(entries) == 0 {
+		fmt.Fprintf(w, "no muted tasks\n")
+		return nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].since != entries[j].since {
+			return entries[i].since < entries[j].since
+		}
+		return entries[i].ref < entries[j].ref
+	})
+	for _, e := range entries {
+		note := "\tmuted " + e.since
+		if e.until != "" {
+			note += " until " + e.until
+			if !e.t.Deferred(now) {
+				note += " (passed)"
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s%s\n", e.ref, e.t.Title(), note)
+	}
+	return nil
+}
+
+// mutedSince returns the day t was last muted,
+// according to its history.
+func mutedSince(t *task.Task) string {
+	since := ""
+	for _, u := range t.Updates() {
+		if s, ok := u.Header["todo"]; ok && strings.HasPrefix(s, "mute") {
+			since = u.Time
+		}
+	}
+	if len(since) > len("2006-01-02") {
+		since = since[:len("2006-01-02")]
+	}
+	return since
+}
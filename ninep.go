@@ -0,0 +1,522 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"9fans.net/go/plan9"
+	"9fans.net/go/plan9/client"
+	"rsc.io/todo/task"
+)
+
+// A 9P file server for todo lists.
+//
+// The file system mirrors the list hierarchy. Each list is a directory
+// containing its sublists, a directory for each open task, and a file
+// named new. Each task directory holds three files:
+//
+//	headers	the task's headers, one "key: value" per line;
+//		writing "key: value" lines sets those headers
+//	body	the task's history, most recent first;
+//		writing text appends it to the task as a comment
+//	ctl	writing "done", "mute", or "snooze [when]"
+//		updates the task, as the acme commands do
+//
+// Writing a task template (headers, a blank line, and a description)
+// to a list's new file creates a task; reading the file back,
+// using the same open file, returns the new task's ID.
+// Writes to headers, body, and new take effect when the file is closed.
+// Directories of done tasks do not appear in listings,
+// but they can still be opened by name.
+// Each request reads the lists afresh, so that the files show
+// changes made by other programs.
+
+// serve9P serves the lists under root on the Unix socket named todo
+// in the current name space directory, as plan9port's 9pserve would.
+func serve9P(root string) error {
+	ns := client.Namespace()
+	if err := os.MkdirAll(ns, 0700); err != nil {
+		return err
+	}
+	addr := filepath.Join(ns, "todo")
+	os.Remove(addr)
+	ln, err := net.Listen("unix", addr)
+	if err != nil {
+		return err
+	}
+	srv := &fsServer{root: root, qids: make(map[string]uint64)}
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go srv.serveConn(c)
+	}
+}
+
+type fsServer struct {
+	root string
+
+	mu   sync.Mutex
+	qids map[string]uint64 // qid paths, by file name
+}
+
+// An fsNode identifies a file or directory in the file system.
+type fsNode struct {
+	list string // list name, relative to the server root
+	id   string // task ID, if any
+	file string // file name within list or task directory, if any
+}
+
+func (n fsNode) isDir() bool {
+	return n.file == ""
+}
+
+func (n fsNode) name() string {
+	return path.Join(n.list, n.id, n.file)
+}
+
+// An fsFid is the server's state for a client fid.
+type fsFid struct {
+	node fsNode
+	open bool
+	data []byte   // snapshot read at open
+	ents [][]byte // directory entries read at open
+	buf  bytes.Buffer
+	id   string // task created by writing to new
+}
+
+var errNotFound = errors.New("file not found")
+
+func (s *fsServer) qid(n fsNode) plan9.Qid {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := n.name()
+	p, ok := s.qids[name]
+	if !ok {
+		p = uint64(len(s.qids) + 1)
+		s.qids[name] = p
+	}
+	q := plan9.Qid{Path: p, Type: plan9.QTFILE}
+	if n.isDir() {
+		q.Type = plan9.QTDIR
+	}
+	return q
+}
+
+// list returns the task list for the node n.
+// It reads the list afresh, rather than using taskList's cache,
+// so that each request sees changes made by other programs.
+func (s *fsServer) list(n fsNode) *task.List {
+	return task.OpenList(path.Join(s.root, n.list))
+}
+
+// walk1 returns the node for the name elem in the directory n.
+func (s *fsServer) walk1(n fsNode, elem string) (fsNode, error) {
+	if !n.isDir() {
+		return n, errors.New("not a directory")
+	}
+	if elem == ".." {
+		switch {
+		case n.id != "":
+			n.id = ""
+		case n.list != "":
+			n.list = path.Dir(n.list)
+			if n.list == "." {
+				n.list = ""
+			}
+		}
+		return n, nil
+	}
+	if n.id != "" {
+		switch elem {
+		case "headers", "body", "ctl":
+			n.file = elem
+			return n, nil
+		}
+		return n, errNotFound
+	}
+	if strings.HasPrefix(elem, "_") || strings.HasPrefix(elem, ".") {
+		return n, errNotFound
+	}
+	if task.IsList(path.Join(s.root, n.list, elem)) {
+		n.list = path.Join(n.list, elem)
+		return n, nil
+	}
+	if elem == "new" {
+		n.file = elem
+		return n, nil
+	}
+	if s.list(n).Exists(elem) {
+		n.id = elem
+		return n, nil
+	}
+	return n, errNotFound
+}
+
+func (s *fsServer) stat(n fsNode) *plan9.Dir {
+	d := &plan9.Dir{
+		Qid:   s.qid(n),
+		Mode:  0644,
+		Name:  path.Base("/" + n.name()),
+		Uid:   os.Getenv("USER"),
+		Gid:   os.Getenv("USER"),
+		Muid:  os.Getenv("USER"),
+		Mtime: uint32(time.Now().Unix()),
+	}
+	d.Atime = d.Mtime
+	if n.isDir() {
+		d.Mode = plan9.DMDIR | 0755
+	}
+	if n.file == "ctl" {
+		d.Mode = 0222
+	}
+	return d
+}
+
+// readDir returns the directory entries for the directory n.
+func (s *fsServer) readDir(n fsNode) ([][]byte, error) {
+	var names []fsNode
+	if n.id != "" {
+		for _, f := range []string{"body", "ctl", "headers"} {
+			names = append(names, fsNode{list: n.list, id: n.id, file: f})
+		}
+	} else {
+		l := s.list(n)
+		for _, sub := range l.Sublists() {
+			names = append(names, fsNode{list: path.Join(n.list, sub)})
+		}
+		names = append(names, fsNode{list: n.list, file: "new"})
+		all, err := l.All()
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range all {
+			names = append(names, fsNode{list: n.list, id: t.ID()})
+		}
+	}
+	var ents [][]byte
+	for _, m := range names {
+		b, err := s.stat(m).Bytes()
+		if err != nil {
+			return nil, err
+		}
+		ents = append(ents, b)
+	}
+	return ents, nil
+}
+
+// readFile returns the current content of the file n.
+func (s *fsServer) readFile(n fsNode) ([]byte, error) {
+	if n.file == "ctl" || n.file == "new" {
+		return nil, nil
+	}
+	t, err := s.list(n).Read(n.id)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	t.PrintTo(&buf)
+	text := buf.Bytes()
+	i := bytes.Index(text, []byte("\n\n"))
+	if n.file == "headers" {
+		return text[:i+1], nil
+	}
+	return text[i+2:], nil
+}
+
+// ctl executes the ctl command line on the task n.
+func (s *fsServer) ctl(n fsNode, line string) error {
+	f := strings.Fields(line)
+	if len(f) == 0 {
+		return nil
+	}
+	var v string
+	switch f[0] {
+	case "done", "mute":
+		if len(f) != 1 {
+			return fmt.Errorf("usage: %s", f[0])
+		}
+		v = f[0]
+	case "snooze":
+		if len(f) > 2 {
+			return fmt.Errorf("usage: snooze [when]")
+		}
+		when := ""
+		if len(f) == 2 {
+			when = f[1]
+		}
+		var err error
+		if v, err = snoozeValue(when); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown ctl message %q", f[0])
+	}
+	l := s.list(n)
+	t, err := l.Read(n.id)
+	if err != nil {
+		return err
+	}
+	return l.Write(t, time.Now(), map[string]string{"todo": v}, nil)
+}
+
+// flush applies the text written to fid, if any.
+func (s *fsServer) flush(fid *fsFid) error {
+	if fid.buf.Len() == 0 || fid.id != "" {
+		return nil
+	}
+	text := fid.buf.Bytes()
+	fid.buf.Reset()
+	n := fid.node
+	l := s.list(n)
+	switch n.file {
+	case "new":
+		t, err := writeTask(l, nil, text)
+		if err != nil {
+			return err
+		}
+		fid.id = t.ID()
+		return nil
+	case "headers":
+		t, err := l.Read(n.id)
+		if err != nil {
+			return err
+		}
+		hdr := make(map[string]string)
+		for _, line := range strings.Split(string(text), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			i := strings.Index(line, ":")
+			if i < 0 {
+				return fmt.Errorf("unknown header line: %s", line)
+			}
+			k := strings.TrimSpace(strings.ToLower(line[:i]))
+			v := strings.TrimSpace(line[i+1:])
+			if t.Header(k) != v {
+				hdr[k] = v
+			}
+		}
+		if len(hdr) == 0 {
+			return nil
+		}
+		return l.Write(t, time.Now(), hdr, nil)
+	case "body":
+		t, err := l.Read(n.id)
+		if err != nil {
+			return err
+		}
+		comment := bytes.TrimSpace(text)
+		if len(comment) == 0 {
+			return nil
+		}
+		return l.Write(t, time.Now(), nil, comment)
+	}
+	return nil
+}
+
+func (s *fsServer) serveConn(c net.Conn) {
+	defer c.Close()
+	fids := make(map[uint32]*fsFid)
+	msize := uint32(8192 + plan9.IOHDRSZ)
+	for {
+		tx, err := plan9.ReadFcall(c)
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintf(os.Stderr, "todo: 9p: %v\n", err)
+			}
+			return
+		}
+		rx := &plan9.Fcall{Type: tx.Type + 1, Tag: tx.Tag}
+		if err := s.handle(fids, &msize, tx, rx); err != nil {
+			rx = &plan9.Fcall{Type: plan9.Rerror, Tag: tx.Tag, Ename: err.Error()}
+		}
+		if err := plan9.WriteFcall(c, rx); err != nil {
+			fmt.Fprintf(os.Stderr, "todo: 9p: %v\n", err)
+			return
+		}
+	}
+}
+
+func (s *fsServer) handle(fids map[uint32]*fsFid, msize *uint32, tx, rx *plan9.Fcall) error {
+	if tx.Type == plan9.Tversion {
+		if tx.Msize < *msize {
+			*msize = tx.Msize
+		}
+		rx.Msize = *msize
+		rx.Version = "unknown"
+		if strings.HasPrefix(tx.Version, plan9.VERSION9P) {
+			rx.Version = plan9.VERSION9P
+		}
+		for id := range fids {
+			delete(fids, id)
+		}
+		return nil
+	}
+	switch tx.Type {
+	case plan9.Tauth:
+		return errors.New("authentication not required")
+	case plan9.Tattach:
+		if fids[tx.Fid] != nil {
+			return errors.New("fid in use")
+		}
+		fid := &fsFid{}
+		fids[tx.Fid] = fid
+		rx.Qid = s.qid(fid.node)
+		return nil
+	case plan9.Tflush:
+		return nil
+	}
+
+	fid := fids[tx.Fid]
+	if fid == nil {
+		return errors.New("unknown fid")
+	}
+	switch tx.Type {
+	default:
+		return errors.New("bad message")
+
+	case plan9.Twalk:
+		if fid.open {
+			return errors.New("walk of open fid")
+		}
+		if tx.Newfid != tx.Fid && fids[tx.Newfid] != nil {
+			return errors.New("fid in use")
+		}
+		n := fid.node
+		for i, elem := range tx.Wname {
+			next, err := s.walk1(n, elem)
+			if err != nil {
+				if i == 0 {
+					return err
+				}
+				return nil
+			}
+			n = next
+			rx.Wqid = append(rx.Wqid, s.qid(n))
+		}
+		fids[tx.Newfid] = &fsFid{node: n}
+		return nil
+
+	case plan9.Topen:
+		n := fid.node
+		mode := tx.Mode &^ (plan9.OTRUNC | plan9.OCEXEC | plan9.ORCLOSE)
+		if n.isDir() {
+			if mode != plan9.OREAD {
+				return errors.New("permission denied")
+			}
+			ents, err := s.readDir(n)
+			if err != nil {
+				return err
+			}
+			fid.ents = ents
+		} else {
+			if n.file == "ctl" && mode != plan9.OWRITE {
+				return errors.New("permission denied")
+			}
+			data, err := s.readFile(n)
+			if err != nil {
+				return err
+			}
+			fid.data = data
+		}
+		fid.open = true
+		rx.Qid = s.qid(n)
+		rx.Iounit = *msize - plan9.IOHDRSZ
+		return nil
+
+	case plan9.Tcreate:
+		return errors.New("permission denied")
+
+	case plan9.Tread:
+		if !fid.open {
+			return errors.New("fid not open")
+		}
+		if fid.node.isDir() {
+			// Directory reads must return whole entries,
+			// and offsets are those previously returned.
+			off := uint64(0)
+			for _, e := range fid.ents {
+				if off >= tx.Offset {
+					if len(rx.Data)+len(e) > int(tx.Count) {
+						break
+					}
+					rx.Data = append(rx.Data, e...)
+				}
+				off += uint64(len(e))
+			}
+			return nil
+		}
+		if fid.node.file == "new" {
+			if err := s.flush(fid); err != nil {
+				return err
+			}
+			if fid.id == "" {
+				return nil
+			}
+			fid.data = []byte(fid.id + "\n")
+		}
+		if tx.Offset < uint64(len(fid.data)) {
+			data := fid.data[tx.Offset:]
+			if len(data) > int(tx.Count) {
+				data = data[:tx.Count]
+			}
+			rx.Data = data
+		}
+		return nil
+
+	case plan9.Twrite:
+		if !fid.open || fid.node.isDir() {
+			return errors.New("permission denied")
+		}
+		rx.Count = uint32(len(tx.Data))
+		if fid.node.file == "ctl" {
+			for _, line := range strings.Split(string(tx.Data), "\n") {
+				if err := s.ctl(fid.node, line); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		fid.buf.Write(tx.Data)
+		return nil
+
+	case plan9.Tclunk, plan9.Tremove:
+		delete(fids, tx.Fid)
+		if err := s.flush(fid); err != nil {
+			return err
+		}
+		if tx.Type == plan9.Tremove {
+			return errors.New("permission denied")
+		}
+		return nil
+
+	case plan9.Tstat:
+		b, err := s.stat(fid.node).Bytes()
+		if err != nil {
+			return err
+		}
+		rx.Stat = b
+		return nil
+
+	case plan9.Twstat:
+		// Accept and ignore wstat, so that programs
+		// that truncate files before writing them work.
+		return nil
+	}
+}
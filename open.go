@@ -0,0 +1,109 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+
+	"rsc.io/todo/task"
+)
+
+func cmdOpen(args []string) {
+	fs := flag.NewFlagSet("open", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo open id...\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+	}
+	l := taskList(*dirFlag)
+	failed := false
+	for _, id := range fs.Args() {
+		t, err := readTask(l, id)
+		if err == nil {
+			err = openURL(t)
+		}
+		if err != nil {
+			log.Print(err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// urlRE matches the URLs in a task's history.
+var urlRE = regexp.MustCompile(`https?://[^\s<>"')\]]+`)
+
+// taskURL returns the URL for t: its url header,
+// as set by vcs-todo from Reviewed-on lines,
+// or else the first URL in its history.
+func taskURL(t *task.Task) string {
+	if u := t.Header("url"); u != "" {
+		return u
+	}
+	for _, u := range t.Updates() {
+		if m := urlRE.FindString(u.Comment); m != "" {
+			return m
+		}
+	}
+	return ""
+}
+
+// openURL opens the URL for t in a web browser,
+// using the program named by the open setting in the root list's
+// configuration, or else $BROWSER, or else the first of
+// web (from plan9port), xdg-open, and open found in $PATH.
+func openURL(t *task.Task) error {
+	u := taskURL(t)
+	if u == "" {
+		return fmt.Errorf("%s has no URL", t.ID())
+	}
+	prog := taskList(".").Config().Get("open")
+	if prog == "" {
+		prog = os.Getenv("BROWSER")
+	}
+	if prog == "" {
+		for _, name := range []string{"web", "xdg-open", "open"} {
+			if _, err := exec.LookPath(name); err == nil {
+				prog = name
+				break
+			}
+		}
+	}
+	if prog == "" {
+		return fmt.Errorf("no program to open %s: set open in _config or $BROWSER", u)
+	}
+	// Run the opener using the shell, so that it can include arguments.
+	cmd := shellCommand(prog, u)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s %s: %v\n%s", prog, u, err, out)
+	}
+	return nil
+}
+
+// ExecWeb opens the URL for the task in a single-task window.
+func (w *awin) ExecWeb() {
+	if w.mode != modeSingle {
+		w.acme.Err("Web can only be used in single task windows")
+		return
+	}
+	t, err := w.list().Read(w.id())
+	if err == nil {
+		err = openURL(t)
+	}
+	if err != nil {
+		w.acme.Err(err.Error())
+	}
+}
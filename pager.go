@@ -0,0 +1,55 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+)
+
+// page writes data to standard output. If standard output is
+// a terminal and data is too long to fit on the screen,
+// page shows it using $PAGER (default less) instead, as git does.
+// Setting $PAGER to "" or "cat", or using the -no-pager flag,
+// disables the pager.
+func page(data []byte) {
+	if *noPagerFlag || !isTerminal(os.Stdout) {
+		os.Stdout.Write(data)
+		return
+	}
+	_, height := termSize()
+	if bytes.Count(data, []byte("\n")) < height {
+		os.Stdout.Write(data)
+		return
+	}
+	pager, ok := os.LookupEnv("PAGER")
+	if !ok {
+		pager = "less"
+	}
+	if pager == "" || pager == "cat" {
+		os.Stdout.Write(data)
+		return
+	}
+
+	// Like git, run the pager using the shell, so that $PAGER
+	// can include arguments, and default $LESS and $LV to quit
+	// if the text fits on one screen and to pass colors through.
+	cmd := shellCommand(pager)
+	cmd.Env = os.Environ()
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+	if _, ok := os.LookupEnv("LV"); !ok {
+		cmd.Env = append(cmd.Env, "LV=-c")
+	}
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		os.Stdout.Write(data)
+		return
+	}
+	cmd.Wait()
+}
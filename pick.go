@@ -0,0 +1,285 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+func cmdPick(args []string) {
+	fs := flag.NewFlagSet("pick", flag.ExitOnError)
+	execFlag := fs.String("exec", "", "run `command` with the chosen task's ID as its argument")
+	finderFlag := fs.String("finder", "", "choose using `program`, such as fzf or dmenu, or internal for the built-in finder")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo pick [-exec command] [-finder program] [query]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+
+	l := taskList(*dirFlag)
+	q := strings.Join(fs.Args(), " ")
+	if q == "" {
+		q = defaultQuery(l)
+	}
+	var buf bytes.Buffer
+	if err := showQuery(&buf, l, q, nil); err != nil {
+		log.Fatal(err)
+	}
+
+	// Feeding another program: just print the lines.
+	if *execFlag == "" && !isTerminal(os.Stdout) {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+
+	lines := strings.SplitAfter(buf.String(), "\n")
+	if n := len(lines); n > 0 && lines[n-1] == "" {
+		lines = lines[:n-1]
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\n")
+	}
+	if len(lines) == 0 {
+		log.Fatal("no tasks matched query")
+	}
+
+	finder := *finderFlag
+	if finder == "" {
+		finder = "internal"
+		if _, err := exec.LookPath("fzf"); err == nil {
+			finder = "fzf"
+		}
+	}
+	var line string
+	var err error
+	if finder == "internal" {
+		line, err = fuzzyPick(lines)
+	} else {
+		line, err = externalPick(finder, buf.Bytes())
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if line == "" {
+		os.Exit(1) // nothing chosen
+	}
+	id := line
+	if i := strings.Index(line, "\t"); i >= 0 {
+		id = line[:i]
+	}
+
+	if *execFlag == "" {
+		fmt.Println(id)
+		return
+	}
+	cmd := shellCommand(*execFlag, id)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			os.Exit(1)
+		}
+		log.Fatal(err)
+	}
+}
+
+// externalPick runs the finder command with the lines on standard input
+// and returns the line it chooses, or "" if it chooses none.
+func externalPick(finder string, lines []byte) (string, error) {
+	cmd := shellCommand(finder)
+	cmd.Stdin = bytes.NewReader(lines)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			// fzf and dmenu exit 1 when nothing is chosen.
+			return "", nil
+		}
+		return "", fmt.Errorf("%s: %v", finder, err)
+	}
+	return strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0]), nil
+}
+
+// fuzzyPick lets the user choose one of lines on the terminal,
+// typing characters to narrow the list to lines containing them in order.
+// It returns the chosen line, or "" if the user cancels.
+// It uses /dev/tty, leaving standard output free for the result.
+// Without a /dev/tty it can put in raw mode, as on Windows,
+// it falls back to numberPick.
+func fuzzyPick(lines []string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return numberPick(lines)
+	}
+	defer tty.Close()
+	restore, err := rawMode(tty)
+	if err != nil {
+		return numberPick(lines)
+	}
+	fmt.Fprint(tty, "\x1b[?1049h")
+	defer func() {
+		fmt.Fprint(tty, "\x1b[?1049l")
+		restore()
+	}()
+
+	pattern := ""
+	sel := 0
+	matches := fuzzyFilter(lines, pattern)
+	buf := make([]byte, 64)
+	for {
+		width, height := termSize()
+		var b bytes.Buffer
+		b.WriteString("\x1b[H\x1b[2J")
+		fmt.Fprintf(&b, "\x1b[1m> %s\x1b[m\x1b[K\r\n", pattern)
+		for i := 0; i < len(matches) && i < height-2; i++ {
+			s := strings.Replace(matches[i], "\t", "  ", 1)
+			if r := []rune(s); len(r) > width {
+				s = string(r[:width])
+			}
+			if i == sel {
+				s = "\x1b[7m" + s + "\x1b[m"
+			}
+			b.WriteString(s + "\x1b[K\r\n")
+		}
+		fmt.Fprintf(&b, "\x1b[2m%d/%d\x1b[m\x1b[K", len(matches), len(lines))
+		tty.Write(b.Bytes())
+
+		n, err := tty.Read(buf)
+		if err != nil {
+			return "", err
+		}
+		switch k := string(buf[:n]); k {
+		case "\r", "\n":
+			if sel < len(matches) {
+				return matches[sel], nil
+			}
+		case "\x1b", "\x03", "\x07": // escape, ^C, ^G
+			return "", nil
+		case "\x1b[A", "\x10": // up, ^P
+			if sel > 0 {
+				sel--
+			}
+		case "\x1b[B", "\x0e": // down, ^N
+			if sel+1 < len(matches) {
+				sel++
+			}
+		case "\x7f", "\b":
+			if pattern != "" {
+				_, size := utf8.DecodeLastRuneInString(pattern)
+				pattern = pattern[:len(pattern)-size]
+				matches, sel = fuzzyFilter(lines, pattern), 0
+			}
+		case "\x15": // ^U
+			pattern = ""
+			matches, sel = fuzzyFilter(lines, pattern), 0
+		default:
+			if r, _ := utf8.DecodeRuneInString(k); r >= ' ' && r != 0x7f && !strings.HasPrefix(k, "\x1b") {
+				pattern += k
+				matches, sel = fuzzyFilter(lines, pattern), 0
+			}
+		}
+	}
+}
+
+// numberPick lets the user choose one of lines a line at a time:
+// it prints the lines, numbered, on standard error and reads
+// from standard input either a number, choosing that line,
+// or a pattern, narrowing the lines as in fuzzyFilter.
+// It returns the chosen line, or "" if the user enters nothing.
+func numberPick(lines []string) (string, error) {
+	if !isTerminal(os.Stdin) {
+		return "", fmt.Errorf("pick needs a terminal")
+	}
+	in := bufio.NewReader(os.Stdin)
+	matches := lines
+	for {
+		for i, line := range matches {
+			fmt.Fprintf(os.Stderr, "%d\t%s\n", i+1, line)
+		}
+		fmt.Fprintf(os.Stderr, "> ")
+		s, _ := in.ReadString('\n')
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return "", nil
+		}
+		if n, err := strconv.Atoi(s); err == nil && 1 <= n && n <= len(matches) {
+			return matches[n-1], nil
+		}
+		if matches = fuzzyFilter(lines, s); len(matches) == 0 {
+			fmt.Fprintf(os.Stderr, "no tasks match %q\n", s)
+			matches = lines
+		}
+	}
+}
+
+// fuzzyFilter returns the lines containing the characters of pattern
+// in order, ignoring case, best matches first: those where the
+// characters are closest together, and then those where they start earliest.
+func fuzzyFilter(lines []string, pattern string) []string {
+	type match struct {
+		line  string
+		span  int
+		start int
+	}
+	var ms []match
+	for _, line := range lines {
+		if start, span, ok := fuzzyMatch(line, pattern); ok {
+			ms = append(ms, match{line, span, start})
+		}
+	}
+	sort.SliceStable(ms, func(i, j int) bool {
+		if ms[i].span != ms[j].span {
+			return ms[i].span < ms[j].span
+		}
+		return ms[i].start < ms[j].start
+	})
+	out := make([]string, len(ms))
+	for i, m := range ms {
+		out[i] = m.line
+	}
+	return out
+}
+
+// fuzzyMatch reports whether s contains the characters of pattern
+// in order, ignoring case. If so, it returns the start and length,
+// in runes, of the shortest such match.
+func fuzzyMatch(s, pattern string) (start, span int, ok bool) {
+	p := []rune(strings.ToLower(pattern))
+	if len(p) == 0 {
+		return 0, 0, true
+	}
+	r := []rune(strings.ToLower(s))
+	best := -1
+	for i := range r {
+		if r[i] != p[0] {
+			continue
+		}
+		j, k := i, 0
+		for ; j < len(r) && k < len(p); j++ {
+			if r[j] == p[k] {
+				k++
+			}
+		}
+		if k < len(p) {
+			break // no later start can match either
+		}
+		if best < 0 || j-i < span {
+			start, span, best = i, j-i, i
+		}
+	}
+	return start, span, best >= 0
+}
@@ -0,0 +1,139 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"rsc.io/todo/task"
+)
+
+// Plugins.
+//
+// An executable named todo-name in $PATH is a plugin providing the
+// command todo name, as git does for git-name. It runs with the
+// arguments following the name and with $TODO_DIR set to the todo root,
+// $TODO_LIST to the list selected by -d (or "."), and $TODO_PROFILE
+// to the selected profile, if any. When run from acme by X name,
+// its standard input is a JSON array of the selected tasks,
+// in the form used by todo serve -stdio.
+
+// findPlugin returns the path of the plugin providing the command name,
+// or "" if there is none.
+func findPlugin(name string) string {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, "-") {
+		return ""
+	}
+	file, err := exec.LookPath("todo-" + name)
+	if err != nil {
+		return ""
+	}
+	return file
+}
+
+// namesTaskOrList reports whether name is the ID of a task in l
+// or the name of one of its sublists, which todo name takes
+// as a query even when a plugin provides the command name.
+func namesTaskOrList(l *task.List, name string) bool {
+	return l.Exists(name) || task.IsList(path.Join(l.Name(), name))
+}
+
+// pluginCommand returns the command running the plugin file for
+// the list l with the given arguments and standard input.
+func pluginCommand(file string, l *task.List, args []string, stdin io.Reader) *exec.Cmd {
+	cmd := exec.Command(file, args...)
+	cmd.Env = append(os.Environ(),
+		"TODO_DIR="+task.Root(),
+		"TODO_LIST="+l.Name(),
+		"TODO_PROFILE="+task.Profile(),
+	)
+	cmd.Stdin = stdin
+	return cmd
+}
+
+// runPlugin runs the plugin file as todo would run a command,
+// exiting with the plugin's exit status.
+func runPlugin(file string, args []string) {
+	cmd := pluginCommand(file, taskList(*dirFlag), args, os.Stdin)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if e, ok := err.(*exec.ExitError); ok {
+			os.Exit(e.ExitCode())
+		}
+		fmt.Fprintf(os.Stderr, "todo: %v\n", err)
+		os.Exit(2)
+	}
+	os.Exit(0)
+}
+
+// pluginInput returns the JSON array of tasks from l
+// passed to a plugin on standard input.
+func pluginInput(l *task.List, tasks []*task.Task) []byte {
+	list := []*rpcTask{}
+	for _, t := range tasks {
+		list = append(list, newRPCTask(l.Name(), t))
+	}
+	data, _ := json.MarshalIndent(list, "", "\t")
+	return append(data, '\n')
+}
+
+// ExecX runs the plugin named by the first word of arg,
+// with the remaining words as arguments, on the window's task
+// or the tasks in the selection, showing its output in the
+// acme errors window. Windows showing the tasks are then reloaded,
+// in case the plugin changed them.
+func (w *awin) ExecX(arg string) {
+	f := strings.Fields(arg)
+	if len(f) == 0 {
+		w.acme.Err("X needs a plugin name")
+		return
+	}
+	file := findPlugin(f[0])
+	if file == "" {
+		w.acme.Err(fmt.Sprintf("X: no plugin todo-%s in $PATH", f[0]))
+		return
+	}
+	l := w.list()
+	var tasks []*task.Task
+	switch w.mode {
+	case modeSingle:
+		if t, err := l.Read(w.id()); err == nil {
+			tasks = append(tasks, t)
+		}
+	case modeList, modeBoard:
+		for _, id := range readBulkIDs(l, []byte(w.acme.Selection())) {
+			if t, err := l.Read(id); err == nil {
+				tasks = append(tasks, t)
+			}
+		}
+	}
+	var out bytes.Buffer
+	cmd := pluginCommand(file, l, f[1:], bytes.NewReader(pluginInput(l, tasks)))
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	if out.Len() > 0 {
+		w.acme.Err(out.String())
+	}
+	if err != nil {
+		w.acme.Err(fmt.Sprintf("X %s: %v", f[0], err))
+	}
+	var ids []string
+	for _, t := range tasks {
+		ids = append(ids, t.ID())
+	}
+	w.changed(ids)
+	if w.mode == modeSingle && !w.dirty() {
+		w.ExecGet()
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"9fans.net/go/acme"
+	"9fans.net/go/plumb"
+	"rsc.io/todo/task"
+)
+
+// plumbRules are the plumbing rules sending task references to todo.
+// They match full window names, such as /todo/home/123,
+// and references in other text, such as "todo home/123".
+const plumbRules = `# todo: task references, from rsc.io/todo
+type is text
+data matches '/todo/[a-zA-Z0-9_\-./]+'
+plumb to todo
+
+type is text
+data matches 'todo ([a-zA-Z0-9_\-./]+)'
+data set /todo/$1
+plumb to todo
+`
+
+func cmdPlumb(args []string) {
+	fs := flag.NewFlagSet("plumb", flag.ExitOnError)
+	install := fs.Bool("install", false, "add the rules to $HOME/lib/plumbing and reload the plumber")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo plumb [-install]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+	}
+	if !*install {
+		fmt.Print(plumbRules)
+		return
+	}
+
+	file := filepath.Join(task.HomeDir(), "lib", "plumbing")
+	data, err := ioutil.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		log.Fatal(err)
+	}
+	if bytes.Contains(data, []byte(plumbRules)) {
+		log.Printf("%s already has todo rules", file)
+	} else {
+		if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n\n")) {
+			data = append(data, '\n')
+		}
+		data = append(data, plumbRules...)
+		if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+			log.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, data, 0666); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Reload the running plumber, if any, as in
+	// cat $HOME/lib/plumbing | 9p write plumb/rules.
+	cmd := exec.Command("9p", "write", "plumb/rules")
+	cmd.Stdin = bytes.NewReader(data)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		log.Printf("reloading plumber: %v\n%s", err, out)
+	}
+}
+
+// servePlumb opens windows for the messages sent to the todo plumb port.
+func servePlumb() {
+	// The port is todo even when a profile is selected;
+	// plumbMessage ignores window names for other profiles.
+	const kind = "todo"
+	fid, err := plumb.Open(kind, 0)
+	if err != nil {
+		acme.Err(root, fmt.Sprintf("plumb: %v", err))
+		return
+	}
+	r := bufio.NewReader(fid)
+	for {
+		var m plumb.Message
+		if err := m.Recv(r); err != nil {
+			acme.Errf(root, "plumb recv: %v", err)
+			return
+		}
+		if m.Type != "text" {
+			acme.Errf(root, "plumb recv: unexpected type: %s\n", m.Type)
+			continue
+		}
+		if m.Dst != kind {
+			acme.Errf(root, "plumb recv: unexpected dst: %s\n", m.Dst)
+			continue
+		}
+		if err := plumbMessage(&m); err != nil {
+			acme.Errf(root, "plumb recv: %v", err)
+		}
+	}
+}
+
+// taskRefRE matches task references in plumbed text, such as "todo home/123".
+var taskRefRE = regexp.MustCompile(`\btodo ([a-zA-Z0-9_\-./]+)`)
+
+// plumbMessage opens the window for a plumb message.
+// The data is a window name, such as /todo/home/123,
+// a task ID relative to the list, or text mentioning tasks,
+// such as "see todo home/123".
+// A list attribute, such as list=work, names the list
+// for relative names, and an addr attribute of the form
+// search:query, such as addr=search:due:<today, opens
+// a search of that list instead.
+func plumbMessage(m *plumb.Message) error {
+	// TODO use m.Dir?
+	name := "."
+	if s := m.LookupAttr("list"); s != "" {
+		if strings.HasPrefix(s, "/todo/") && !ownWindow(s) {
+			return nil // list in another profile's todo -a
+		}
+		name = strings.Trim(strings.TrimPrefix(s, root), "/")
+		if name == "" {
+			name = "."
+		}
+	}
+	l := taskList(name)
+
+	if addr := m.LookupAttr("addr"); strings.HasPrefix(addr, "search:") {
+		q := plumbQuery(strings.TrimPrefix(addr, "search:"), time.Now())
+		if q == "" {
+			return fmt.Errorf("empty search")
+		}
+		openSearch(l, q)
+		return nil
+	}
+
+	data := strings.TrimSpace(string(m.Data))
+	if strings.HasPrefix(data, "/todo/") && !ownWindow(data) {
+		return nil // window in another profile's todo -a
+	}
+	if strings.HasPrefix(data, root) && !strings.ContainsAny(data, " \t\n") {
+		if !look(taskList("."), strings.TrimPrefix(data, root)) {
+			return fmt.Errorf("can't look %s", data)
+		}
+		return nil
+	}
+	if data != "" && !strings.ContainsAny(data, " \t\n") && look(l, data) {
+		return nil
+	}
+	refs := taskRefRE.FindAllStringSubmatch(data, -1)
+	if len(refs) == 0 {
+		return fmt.Errorf("bad text %q", data)
+	}
+	for _, ref := range refs {
+		id := strings.TrimRight(ref[1], "./")
+		if !look(l, id) {
+			acme.Errf(root, "plumb recv: can't look %s", id)
+		}
+	}
+	return nil
+}
+
+// ownWindow reports whether the acme window name, such as /todo/home/123,
+// belongs to this todo -a rather than to one using another profile.
+// Without a profile, root is /todo/, and a name /todo/p/... belongs
+// to profile p unless p is a list in the default root.
+func ownWindow(name string) bool {
+	if !strings.HasPrefix(name, root) {
+		return false
+	}
+	if root != "/todo/" {
+		return true
+	}
+	rest := strings.TrimPrefix(name, root)
+	i := strings.Index(rest, "/")
+	if i < 0 {
+		return true
+	}
+	for _, sub := range taskList(".").Sublists() {
+		if sub == rest[:i] {
+			return true
+		}
+	}
+	return false
+}
+
+// plumbQuery returns the query q with the dates written in words
+// in its comparisons, as in due:<today or remind:<next-fri,
+// replaced by the dates they stand for, as understood by task.ParseDate,
+// with dashes for spaces as in queries.
+func plumbQuery(q string, now time.Time) string {
+	f := strings.Fields(q)
+	for i, w := range f {
+		j := strings.Index(w, ":")
+		if j < 0 {
+			continue
+		}
+		v := strings.TrimLeft(w[j+1:], "<>=")
+		op := w[j+1 : len(w)-len(v)]
+		if op == "" || v == "" {
+			continue
+		}
+		d, err := task.ParseDate(strings.Replace(v, "-", " ", -1), now)
+		if err != nil {
+			continue
+		}
+		f[i] = w[:j+1] + op + d.Format("2006-01-02")
+	}
+	return strings.Join(f, " ")
+}
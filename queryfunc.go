@@ -0,0 +1,57 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+// registerQueryFuncs registers the query functions todo provides:
+// mine, matching tasks assigned to the current user, and stale(age),
+// matching tasks without an update for age (default 30d).
+func registerQueryFuncs() {
+	task.RegisterQueryFunc("mine", queryMine)
+	task.RegisterQueryFunc("stale", queryStale)
+}
+
+// queryMine implements the query function mine, which matches tasks
+// whose assignee or owner header names the current user,
+// $TODO_ACTOR or else the user name, perhaps among others.
+func queryMine(arg string) (func(*task.Task) bool, error) {
+	if arg != "" {
+		return nil, fmt.Errorf("mine takes no argument")
+	}
+	me := strings.ToLower(task.Actor())
+	return func(t *task.Task) bool {
+		for _, key := range []string{"assignee", "owner"} {
+			for _, name := range strings.FieldsFunc(strings.ToLower(t.Header(key)), func(r rune) bool { return r == ',' || r == ' ' }) {
+				if name == me || strings.TrimPrefix(name, "@") == me {
+					return true
+				}
+			}
+		}
+		return false
+	}, nil
+}
+
+// queryStale implements the query function stale(age), which matches
+// tasks whose last update is older than age, such as 30d or 2w.
+func queryStale(arg string) (func(*task.Task) bool, error) {
+	if arg == "" {
+		arg = "30d"
+	}
+	age, err := task.ParseLead(arg)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-age).Format("2006-01-02 15:04:05")
+	return func(t *task.Task) bool {
+		return t.Header("mtime") < cutoff
+	}, nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"rsc.io/todo/task"
+)
+
+// maxRecentRefile is the number of recently used refile
+// destinations remembered in the root list's state.
+const maxRecentRefile = 10
+
+func cmdRefile(args []string) {
+	fs := flag.NewFlagSet("refile", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo refile [id list]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	switch fs.NArg() {
+	default:
+		fs.Usage()
+	case 0:
+		l, err := inboxList()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !isTerminal(os.Stdin) {
+			log.Fatal("refile needs a terminal, or an id and a list")
+		}
+		if err := reviewInbox(os.Stdout, bufio.NewReader(os.Stdin), l); err != nil {
+			log.Fatal(err)
+		}
+	case 2:
+		l := writableList(*dirFlag)
+		t, err := readTask(l, fs.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		dst, err := refile(l, t, fs.Arg(1))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(dst)
+	}
+}
+
+// refile moves the task t from l to the list with the given name,
+// relative to the root, and records the list as recently used.
+// It returns the moved task's name, as in work/123.
+func refile(l *task.List, t *task.Task, name string) (string, error) {
+	name = path.Clean(strings.Trim(name, "/"))
+	if !task.IsList(name) {
+		return "", fmt.Errorf("no list %s", name)
+	}
+	dst := taskList(name)
+	t, err := l.Move(t, dst)
+	if err != nil {
+		return "", err
+	}
+	noteRefile(name)
+	notePrevious(dst, t)
+	return taskRef(dst, t), nil
+}
+
+// noteRefile records name as the most recently used refile destination.
+func noteRefile(name string) {
+	recent := []string{name}
+	for _, r := range strings.Fields(taskList("").State("refile")) {
+		if r != name && len(recent) < maxRecentRefile {
+			recent = append(recent, r)
+		}
+	}
+	if err := taskList("").SetState("refile", strings.Join(recent, " ")); err != nil {
+		log.Print(err)
+	}
+}
+
+// refileTargets returns the names of the lists that tasks in l
+// can be refiled to: the recently used ones, most recent first,
+// and then the rest in alphabetical order.
+func refileTargets(l *task.List) []string {
+	var names []string
+	for _, sub := range allLists(taskList("")) {
+		if name := sub.Name(); name != "." && name != l.Name() && !sub.ReadOnly() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	exists := make(map[string]bool)
+	for _, name := range names {
+		exists[name] = true
+	}
+	var out []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Fields(taskList("").State("refile")) {
+		if exists[name] && !seen[name] {
+			out = append(out, name)
+			seen[name] = true
+		}
+	}
+	for _, name := range names {
+		if !seen[name] {
+			out = append(out, name)
+		}
+	}
+	return out
+}
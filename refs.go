@@ -0,0 +1,86 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	"rsc.io/todo/task"
+)
+
+// Commands given a task ID also accept special references:
+// last, the most recently created task in the list, as recorded
+// by the task package; ^, the most recently updated; and -,
+// the task a command most recently acted on, such as by editing it,
+// recorded as previous in the root list's _state file.
+// Commands that only show a task do not change -.
+
+// readTask reads the task in l named by ref, a task ID or special reference.
+func readTask(l *task.List, ref string) (*task.Task, error) {
+	id, err := resolveRef(l, ref)
+	if err != nil {
+		return nil, err
+	}
+	return l.Read(id)
+}
+
+// notePrevious records t in l as the previous task, for later uses of -.
+func notePrevious(l *task.List, t *task.Task) {
+	// The reference is a convenience; failing to record it is not an error.
+	taskList("").SetState("previous", path.Join(l.Name(), t.ID()))
+}
+
+// resolveRef returns the task ID in l for ref,
+// which is either a task ID, returned unchanged,
+// or one of the special references last, ^, and -.
+func resolveRef(l *task.List, ref string) (string, error) {
+	switch ref {
+	case "last":
+		if id := l.LastCreated(); id != "" && l.Exists(id) {
+			return id, nil
+		}
+		fallthrough
+
+	case "^":
+		open, err := l.All()
+		if err != nil {
+			return "", err
+		}
+		done, err := l.Done()
+		if err != nil {
+			return "", err
+		}
+		tasks := append(open, done...)
+		if len(tasks) == 0 {
+			return "", fmt.Errorf("no tasks in list %s", l.Name())
+		}
+		key := "ctime,id"
+		if ref == "^" {
+			key = "mtime,id"
+		}
+		cmp := task.Compare(key)
+		latest := tasks[0]
+		for _, t := range tasks[1:] {
+			if cmp(t, latest) > 0 {
+				latest = t
+			}
+		}
+		return latest.ID(), nil
+
+	case "-":
+		prev := taskList("").State("previous")
+		if prev == "" {
+			return "", errors.New("no previous task")
+		}
+		dir, id := path.Split(prev)
+		if dir = path.Clean(dir); dir != l.Name() {
+			return "", fmt.Errorf("previous task %s is not in list %s", prev, l.Name())
+		}
+		return id, nil
+	}
+	return ref, nil
+}
@@ -0,0 +1,296 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+// Desktop reminders.
+//
+// A task generates a reminder when its due time arrives,
+// when it wakes up from a snooze, and when it becomes stale
+// under a list's aging policy (see stale.go). A remind header, such as
+// "remind: 30m" or "remind: 2d", moves the due reminder earlier
+// by the given lead time; one giving a time, such as
+// "remind: 2019-07-01 09:00", adds a reminder at that time
+// (see ../task/remind.go). A date without a time of day is
+// at the start of that day, as is a snooze wakeup.
+
+func cmdRemind(args []string) {
+	fs := flag.NewFlagSet("remind", flag.ExitOnError)
+	daemon := fs.Bool("daemon", false, "keep running, checking for reminders periodically")
+	poll := fs.Duration("poll", time.Minute, "with -daemon, check for reminders every `duration`")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo remind [-daemon] [-poll duration]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+	}
+
+	root := taskList(".")
+	notify := notifier(root.Config().Get("notify"))
+	for {
+		rs, err := reminders(*dirFlag, time.Now())
+		if err != nil {
+			log.Print(err)
+		}
+		// The reminders already sent, by this or an earlier run,
+		// are recorded in the root list's state, so that running
+		// todo remind from cron does not repeat them.
+		// Only the active reminders need to be remembered.
+		old := root.State("reminded")
+		sent := make(map[string]bool)
+		for _, key := range strings.Split(old, "\t") {
+			sent[key] = true
+		}
+		var keys []string
+		for _, r := range rs {
+			keys = append(keys, r.key)
+			if sent[r.key] {
+				continue
+			}
+			if err := notify(r.title, r.text); err != nil {
+				log.Print(err)
+			}
+		}
+		if v := strings.Join(keys, "\t"); v != old {
+			if err := root.SetState("reminded", v); err != nil {
+				log.Print(err)
+			}
+		}
+		if !*daemon {
+			return
+		}
+		time.Sleep(*poll)
+	}
+}
+
+func cmdReminders(args []string) {
+	fs := flag.NewFlagSet("reminders", flag.ExitOnError)
+	days := fs.Int("days", 7, "list reminders due in the next `n` days")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo reminders [-days n]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+	}
+
+	now := time.Now()
+	end := now.AddDate(0, 0, *days)
+	var rs []*reminder
+	for _, l := range allLists(taskList(*dirFlag)) {
+		all, err := l.All()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, t := range all {
+			for _, r := range allTaskReminders(l, t) {
+				// Upcoming, or sent recently and still active.
+				if r.at.Before(end) && now.Sub(r.from) < remindWindow {
+					rs = append(rs, r)
+				}
+			}
+		}
+	}
+	sort.SliceStable(rs, func(i, j int) bool { return rs[i].at.Before(rs[j].at) })
+	var buf bytes.Buffer
+	for _, r := range rs {
+		fmt.Fprintf(&buf, "%s\t%s\t%s\n", r.at.Format("2006-01-02 15:04"), strings.TrimPrefix(r.title, "todo "), r.text)
+	}
+	page(buf.Bytes())
+}
+
+// A reminder is a single notification about a task.
+type reminder struct {
+	key   string // identifies the reminder, to avoid repeats
+	at    time.Time
+	from  time.Time // the reminder is worth sending until remindWindow after from
+	title string
+	text  string
+}
+
+// remindWindow is how long after a task's due or wakeup time
+// its reminder is still worth sending.
+const remindWindow = 24 * time.Hour
+
+// reminders returns the reminders that are active at time now
+// for the tasks in the named list and its sublists, oldest first.
+// It reads the lists from disk afresh on each call, so that
+// a long-running daemon sees changes made by other programs.
+func reminders(name string, now time.Time) ([]*reminder, error) {
+	var rs []*reminder
+	var walk func(name string) error
+	walk = func(name string) error {
+		l := task.OpenList(name)
+		all, err := l.All()
+		if err != nil {
+			return err
+		}
+		for _, t := range all {
+			rs = append(rs, taskReminders(l, t, now)...)
+		}
+		stale, err := staleTasks(l, now)
+		if err != nil {
+			return err
+		}
+		for _, s := range stale {
+			if now.Sub(s.stale) < remindWindow {
+				rs = append(rs, &reminder{
+					key:   s.ref + " stale " + s.t.Header("mtime"),
+					at:    s.stale,
+					title: "todo " + s.ref + " is stale",
+					text:  s.t.Title() + "\n" + s.note(now),
+				})
+			}
+		}
+		for _, sub := range l.Sublists() {
+			if err := walk(path.Join(name, sub)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(name); err != nil {
+		return nil, err
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].at.Before(rs[j].at) })
+	return rs, nil
+}
+
+// taskReminders returns the reminders for t that are active at time now.
+func taskReminders(l *task.List, t *task.Task, now time.Time) []*reminder {
+	var rs []*reminder
+	for _, r := range allTaskReminders(l, t) {
+		if !now.Before(r.at) && now.Sub(r.from) < remindWindow {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
+
+// allTaskReminders returns the reminders for t, past and future.
+func allTaskReminders(l *task.List, t *task.Task) []*reminder {
+	ref := taskRef(l, t)
+	var rs []*reminder
+	if s := t.Header("todo"); strings.HasPrefix(s, "snooze ") {
+		// Only tasks still marked as snoozed can wake up;
+		// any later update to the todo header replaces the snooze.
+		if wake, err := time.ParseInLocation("2006-01-02", strings.TrimPrefix(s, "snooze "), time.Local); err == nil {
+			rs = append(rs, &reminder{
+				key:   ref + " " + s,
+				at:    wake,
+				from:  wake,
+				title: "todo " + ref + " woke up",
+				text:  t.Title(),
+			})
+		}
+	}
+	if s := t.Header("remind"); s != "" {
+		if at, ok := parseDue(s); ok {
+			rs = append(rs, &reminder{
+				key:   ref + " remind " + s,
+				at:    at,
+				from:  at,
+				title: "todo " + ref + " reminder",
+				text:  t.Title(),
+			})
+		}
+	}
+	if s := t.Header("due"); s != "" {
+		due, ok := parseDue(s)
+		if !ok {
+			return rs
+		}
+		at := due
+		if lead, err := task.ParseLead(t.Header("remind")); err == nil {
+			at = due.Add(-lead)
+		}
+		rs = append(rs, &reminder{
+			key:   ref + " due " + s,
+			at:    at,
+			from:  due,
+			title: "todo " + ref + " due " + s,
+			text:  t.Title(),
+		})
+	}
+	return rs
+}
+
+// parseDue parses a due header (or a remind header giving a time),
+// which is a date, optionally followed by a time of day, in local time.
+func parseDue(s string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// notifier returns a function that displays a desktop notification
+// using the named program: notify-send, osascript, or growlnotify.
+// If name is empty, notifier uses the first of those found in $PATH.
+// If none is available, notifications are printed to standard output.
+func notifier(name string) func(title, text string) error {
+	if name == "" {
+		for _, prog := range []string{"notify-send", "osascript", "growlnotify"} {
+			if _, err := exec.LookPath(prog); err == nil {
+				name = prog
+				break
+			}
+		}
+	}
+	var argv func(title, text string) []string
+	switch name {
+	case "notify-send":
+		argv = func(title, text string) []string {
+			return []string{"notify-send", "--", title, text}
+		}
+	case "osascript":
+		argv = func(title, text string) []string {
+			return []string{"osascript", "-e", "display notification " + strconv.Quote(text) + " with title " + strconv.Quote(title)}
+		}
+	case "growlnotify":
+		argv = func(title, text string) []string {
+			return []string{"growlnotify", "-t", title, "-m", text}
+		}
+	case "", "print":
+		return func(title, text string) error {
+			fmt.Printf("%s\t%s\n", title, text)
+			return nil
+		}
+	default:
+		return func(title, text string) error {
+			return fmt.Errorf("unknown notify program %q", name)
+		}
+	}
+	return func(title, text string) error {
+		args := argv(title, text)
+		out, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s: %v\n%s", args[0], err, out)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+func cmdRmlist(args []string) {
+	fs := flag.NewFlagSet("rmlist", flag.ExitOnError)
+	archive := fs.Bool("archive", false, "move the list, with its tasks and sublists, to the _archive tree instead")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo rmlist [-archive] name\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+	}
+	name := fs.Arg(0)
+	if !*archive {
+		err := task.RemoveList(name)
+		var notEmpty *task.NotEmptyError
+		if errors.As(err, &notEmpty) {
+			log.Fatalf("%v; use -archive to archive it instead", err)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	dir, err := task.ArchiveList(name, time.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(dir)
+}
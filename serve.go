@@ -0,0 +1,71 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+)
+
+func cmdServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	httpAddr := fs.String("http", "", "serve the web interface on `addr`")
+	ninep := fs.Bool("9p", false, "serve a 9P file system named todo in the current name space")
+	stdio := fs.Bool("stdio", false, "serve JSON-RPC requests on standard input and output")
+	public := fs.Bool("public", false, "allow serving the web interface on a non-loopback address")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo serve [-http addr [-public]] [-9p] [-stdio]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 || *httpAddr == "" && !*ninep && !*stdio {
+		fs.Usage()
+	}
+
+	if *httpAddr != "" && !*public && !isLoopback(*httpAddr) {
+		log.Fatalf("-http %s is not a loopback address; use -public to serve other hosts", *httpAddr)
+	}
+
+	errc := make(chan error)
+	if *httpAddr != "" {
+		log.Printf("serving web interface on %s", *httpAddr)
+		go func() {
+			errc <- http.ListenAndServe(*httpAddr, newWebServer(*dirFlag))
+		}()
+	}
+	if *ninep {
+		go func() {
+			errc <- serve9P(*dirFlag)
+		}()
+	}
+	if *stdio {
+		go func() {
+			errc <- serveStdio(*dirFlag, os.Stdin, os.Stdout)
+		}()
+	}
+	if err := <-errc; err != nil {
+		log.Fatal(err)
+	}
+}
+
+// isLoopback reports whether the network address addr,
+// such as localhost:8080, listens only on a loopback interface.
+// An address without a host, such as :8080, listens on all of them.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
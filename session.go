@@ -0,0 +1,153 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"9fans.net/go/acme"
+	"rsc.io/todo/task"
+)
+
+// The acme session records the open todo windows in the root list's
+// acme state, so that todo -a -restore can reopen them after acme restarts.
+// Each window is a URL-encoded set of fields, and the windows are
+// separated by spaces, keeping the state on a single line.
+// Window modes are recorded by name, so that the session
+// survives changes to the mode constants.
+
+// sessionModes maps the modes of windows recorded in the session
+// to their names.
+var sessionModes = map[int]string{
+	modeSingle:     "task",
+	modeList:       "list",
+	modeBoard:      "board",
+	modeDashboard:  "dashboard",
+	modeAgenda:     "agenda",
+	modeMilestones: "milestones",
+}
+
+// sessionMode returns the mode with the given name in sessionModes,
+// or 0 if there is none.
+func sessionMode(name string) int {
+	for mode, n := range sessionModes {
+		if n == name {
+			return mode
+		}
+	}
+	return 0
+}
+
+// sessionMu serializes writes of the session.
+var sessionMu sync.Mutex
+
+// saveSession records the open windows as the acme session,
+// writing the state only if the session has changed.
+func saveSession() {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	windows.Lock()
+	var wins []string
+	for w := range windows.m {
+		if v := w.sessionValues(); v != nil {
+			wins = append(wins, v.Encode())
+		}
+	}
+	windows.Unlock()
+	sort.Strings(wins)
+	l := taskList("")
+	s := strings.Join(wins, " ")
+	if s == l.State("acme") {
+		return
+	}
+	if err := l.SetState("acme", s); err != nil {
+		log.Printf("saving acme session: %v", err)
+	}
+}
+
+// closeSession is called after a window has been closed.
+// If acme is still running, the user deleted the window,
+// so it is dropped from the session. If acme has exited,
+// the session is left alone, for todo -a -restore.
+func closeSession() {
+	if _, err := acme.Windows(); err == nil {
+		saveSession()
+	}
+}
+
+// sessionValues returns the fields recording w in the session,
+// or nil if w should not be recorded, as for windows
+// holding unsaved new tasks or bulk edits.
+func (w *awin) sessionValues() url.Values {
+	mode, ok := sessionModes[w.mode]
+	if !ok {
+		return nil
+	}
+	v := url.Values{}
+	v.Set("mode", mode)
+	v.Set("name", w.name)
+	v.Set("tag", w.tag)
+	set := func(key, value string) {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	set("query", w.query)
+	set("base", w.base)
+	set("sort", w.sortBy)
+	set("cols", strings.Join(w.showCols, ","))
+	set("board", w.board)
+	if w.preview {
+		v.Set("preview", "1")
+	}
+	if w.collapsed {
+		v.Set("collapsed", "1")
+	}
+	return v
+}
+
+// restoreSession reopens the windows recorded in the acme session.
+// It returns the number of windows opened.
+func restoreSession() int {
+	n := 0
+	for _, f := range strings.Fields(taskList("").State("acme")) {
+		v, err := url.ParseQuery(f)
+		if err != nil {
+			log.Printf("restoring acme session: %v", err)
+			continue
+		}
+		w := &awin{
+			mode:      sessionMode(v.Get("mode")),
+			name:      v.Get("name"),
+			tag:       v.Get("tag"),
+			query:     v.Get("query"),
+			base:      v.Get("base"),
+			sortBy:    v.Get("sort"),
+			showCols:  parseCols(v.Get("cols")),
+			board:     v.Get("board"),
+			preview:   v.Get("preview") == "1",
+			collapsed: v.Get("collapsed") == "1",
+		}
+		if !strings.HasPrefix(w.name, root) || w.sessionValues() == nil {
+			continue // another profile's window, or a damaged entry
+		}
+		if !task.IsList(w.list().Name()) {
+			continue // list removed since
+		}
+		if w.mode == modeSingle {
+			if _, err := w.list().Read(w.id()); err != nil {
+				continue // task deleted or moved since
+			}
+		}
+		open(w)
+		n++
+	}
+	return n
+}
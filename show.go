@@ -0,0 +1,52 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+func cmdShow(args []string) {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	jsonFlag := fs.Bool("json", false, "print the task as JSON, with its parsed updates")
+	header := fs.String("header", "", "print only the value of the header `key`")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo show [-json | -header key] id\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 || *jsonFlag && *header != "" {
+		fs.Usage()
+	}
+
+	l := taskList(*dirFlag)
+	t, err := readTask(l, fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch {
+	case *jsonFlag:
+		data, err := json.MarshalIndent(newRPCTask(l.Name(), t), "", "\t")
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Stdout.Write(append(data, '\n'))
+	case *header != "":
+		v := t.Header(strings.ToLower(*header))
+		if v == "" {
+			// Like git config, report a missing value by exit status alone.
+			os.Exit(1)
+		}
+		fmt.Println(v)
+	default:
+		t.PrintTo(os.Stdout)
+	}
+}
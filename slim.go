@@ -0,0 +1,85 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"rsc.io/todo/task"
+)
+
+// defaultMaxUpdate is the comment size limit used by todo slim
+// for lists without a maxupdate setting.
+const defaultMaxUpdate = 32 << 10
+
+func cmdSlim(args []string) {
+	fs := flag.NewFlagSet("slim", flag.ExitOnError)
+	maxFlag := fs.String("max", "", "move comments larger than `size`, such as 32K, to attachments (default the list's maxupdate setting, or 32K)")
+	dryRun := fs.Bool("n", false, "print the tasks that would be slimmed but do not change them")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo slim [-max size] [-n]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+	}
+	flagMax := 0
+	if *maxFlag != "" {
+		n, err := task.ParseSize(*maxFlag)
+		if err != nil || n == 0 {
+			log.Fatalf("invalid -max %q", *maxFlag)
+		}
+		flagMax = n
+	}
+
+	failed := false
+	for _, l := range allLists(taskList(*dirFlag)) {
+		max := flagMax
+		if max == 0 {
+			max = l.MaxUpdate()
+		}
+		if max == 0 {
+			max = defaultMaxUpdate
+		}
+		if !*dryRun && l.ReadOnly() {
+			continue
+		}
+		open, err := l.All()
+		if err != nil {
+			log.Fatal(err)
+		}
+		done, err := l.Done()
+		if err != nil {
+			log.Fatal(err)
+		}
+		tasks := append(open, done...)
+		task.Sort(tasks, "id")
+		for _, t := range tasks {
+			if *dryRun {
+				if large := t.LargeComments(max); large > 0 {
+					fmt.Printf("%s\t%d bytes, %d large comments\n", taskRef(l, t), len(t.Text()), large)
+				}
+				continue
+			}
+			slim, n, err := l.Slim(t.ID(), max)
+			if err != nil {
+				log.Printf("%s: %v", taskRef(l, t), err)
+				failed = true
+				continue
+			}
+			if n > 0 {
+				fmt.Printf("%s\t%d bytes, was %d; %d comments attached\n", taskRef(l, t), len(slim.Text()), len(t.Text()), n)
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+// Aging policies.
+//
+// A list's configuration can mark tasks as stale when they go
+// too long without an update, using settings of the form
+//
+//	stale: age [query]
+//
+// as in "stale: 30d" or "stale: 1w priority:p0". An open task matching
+// the query (default "all") whose last update is older than age is stale.
+// The age is a number of days or weeks, such as 30d or 2w, or a duration
+// as accepted by time.ParseDuration. A list's settings apply to its
+// sublists too. Stale tasks are listed by todo stale, noted in the
+// digest, and announced by todo remind when they become stale.
+
+func cmdStale(args []string) {
+	fs := flag.NewFlagSet("stale", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo stale\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+	}
+	now := time.Now()
+	var all []*staleTask
+	for _, l := range allLists(taskList(*dirFlag)) {
+		st, err := staleTasks(l, now)
+		if err != nil {
+			log.Fatal(err)
+		}
+		all = append(all, st...)
+	}
+	for _, s := range all {
+		fmt.Printf("%s\t%s\n\t\t%s\n", s.ref, s.t.Title(), s.note(now))
+	}
+}
+
+// A staleTask is a task that violates an aging policy.
+type staleTask struct {
+	ref    string     // task name, as in work/123
+	t      *task.Task // the task
+	mtime  time.Time  // time of last update
+	stale  time.Time  // time the task became stale
+	policy string     // the violated stale setting
+}
+
+// note describes how long s has gone without an update.
+func (s *staleTask) note(now time.Time) string {
+	days := int(now.Sub(s.mtime).Hours() / 24)
+	unit := "days"
+	if days == 1 {
+		unit = "day"
+	}
+	return fmt.Sprintf("no update for %d %s (stale: %s)", days, unit, s.policy)
+}
+
+// stalePolicies returns the stale settings that apply to l:
+// its own and those of the lists containing it.
+func stalePolicies(l *task.List) []string {
+	var policies []string
+	for name := l.Name(); ; name = path.Dir(name) {
+		policies = append(policies, taskList(name).Config().Values("stale")...)
+		if name == "." || name == "/" || name == "" {
+			break
+		}
+	}
+	return policies
+}
+
+// staleTasks returns the tasks in l (not its sublists) that are stale
+// at time now, ordered by name. A task violating several policies
+// is reported once, for the policy it has violated longest.
+func staleTasks(l *task.List, now time.Time) ([]*staleTask, error) {
+	found := make(map[*task.Task]*staleTask)
+	for _, p := range stalePolicies(l) {
+		f := strings.Fields(p)
+		if len(f) == 0 {
+			continue
+		}
+		age, err := task.ParseLead(f[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid stale setting %q: %v", l.Name(), p, err)
+		}
+		q := strings.Join(f[1:], " ")
+		if q == "" {
+			q = "all"
+		}
+		tasks, err := search(l, q)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid stale setting %q: %v", l.Name(), p, err)
+		}
+		for _, t := range tasks {
+			if t.Done() {
+				continue
+			}
+			mtime, err := time.ParseInLocation("2006-01-02 15:04:05", t.Header("mtime"), time.Local)
+			if err != nil {
+				continue
+			}
+			stale := mtime.Add(age)
+			if now.Before(stale) {
+				continue
+			}
+			if old := found[t]; old == nil || stale.Before(old.stale) {
+				found[t] = &staleTask{ref: taskRef(l, t), t: t, mtime: mtime, stale: stale, policy: p}
+			}
+		}
+	}
+	var list []*staleTask
+	for _, s := range found {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ref < list[j].ref })
+	return list, nil
+}
@@ -0,0 +1,263 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+// A JSON-RPC 2.0 server for editor integrations.
+//
+// Requests and responses are JSON objects, one after another,
+// read from standard input and written to standard output.
+// The methods are:
+//
+//	search {list, query} → [{list, id, title}]
+//	read {list, id} → task
+//	write {list, id, header, comment} → task
+//	create {list, id, header, comment} → task
+//	watch {list} → null
+//
+// List names are relative to the served list, and "" denotes the served
+// list itself. A task result has the fields list, id, title, header
+// (a map of the current headers), and updates (the task's history,
+// oldest first, each with time, header, and comment fields).
+// In write, a header value of "" clears that header;
+// to mark a task done, write the header todo: done.
+// After watch, the server sends a "changed" notification
+// with parameters {list, id} whenever a task in the list changes.
+// Each request reads the lists afresh, so that results show
+// changes made by other programs, watched or not.
+
+type rpcRequest struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  interface{}     `json:"params,omitempty"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// JSON-RPC 2.0 error codes.
+const (
+	rpcParseError     = -32700
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+	rpcNotFound       = -32001 // task.ErrNotExist
+	rpcConflict       = -32002 // task.ErrExists or task.ErrConflict
+	rpcReadOnly       = -32003 // task.ErrReadOnly
+)
+
+// rpcErrorCode returns the error code for err, a failure
+// to read or write a task.
+func rpcErrorCode(err error) int {
+	switch {
+	case errors.Is(err, task.ErrNotExist):
+		return rpcNotFound
+	case errors.Is(err, task.ErrExists), errors.Is(err, task.ErrConflict):
+		return rpcConflict
+	case errors.Is(err, task.ErrReadOnly):
+		return rpcReadOnly
+	}
+	return rpcServerError
+}
+
+type rpcParams struct {
+	List    string            `json:"list"`
+	ID      string            `json:"id"`
+	Query   string            `json:"query"`
+	Header  map[string]string `json:"header"`
+	Comment string            `json:"comment"`
+}
+
+type rpcTask struct {
+	List    string            `json:"list"`
+	ID      string            `json:"id"`
+	Title   string            `json:"title"`
+	Header  map[string]string `json:"header,omitempty"`
+	Updates []rpcUpdate       `json:"updates,omitempty"`
+}
+
+type rpcUpdate struct {
+	Time    string            `json:"time"`
+	Header  map[string]string `json:"header,omitempty"`
+	Comment string            `json:"comment,omitempty"`
+}
+
+type rpcServer struct {
+	root string
+
+	mu      sync.Mutex
+	w       *json.Encoder
+	watched map[string]bool
+}
+
+// serveStdio serves JSON-RPC requests for the lists under root,
+// reading from r and writing to w, until r reaches EOF.
+func serveStdio(root string, r io.Reader, w io.Writer) error {
+	s := &rpcServer{root: root, w: json.NewEncoder(w), watched: make(map[string]bool)}
+	go s.watch()
+	dec := json.NewDecoder(r)
+	for {
+		var req rpcRequest
+		if err := dec.Decode(&req); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			s.send(&rpcResponse{Error: &rpcError{rpcParseError, err.Error()}})
+			return err
+		}
+		// Answer each request in turn, so that
+		// responses arrive in the order of the requests.
+		resp := &rpcResponse{ID: req.ID}
+		result, rerr := s.call(req.Method, req.Params)
+		switch {
+		case rerr != nil:
+			resp.Error = rerr
+		case result == nil:
+			resp.Result = json.RawMessage("null")
+		default:
+			resp.Result = result
+		}
+		if req.ID != nil {
+			s.send(resp)
+		}
+	}
+}
+
+func (s *rpcServer) send(resp *rpcResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp.Version = "2.0"
+	s.w.Encode(resp)
+}
+
+// list returns the named list, relative to the served one.
+// It reads the list afresh, rather than using taskList's cache,
+// so that each request sees changes made by other programs.
+func (s *rpcServer) list(name string) *task.List {
+	return task.OpenList(path.Join(s.root, name))
+}
+
+func (s *rpcServer) call(method string, raw json.RawMessage) (interface{}, *rpcError) {
+	var p rpcParams
+	if raw != nil {
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+	}
+	if p.List != "" && !task.IsList(path.Join(s.root, p.List)) {
+		return nil, &rpcError{rpcInvalidParams, fmt.Sprintf("unknown list %q", p.List)}
+	}
+	l := s.list(p.List)
+
+	var t *task.Task
+	var err error
+	switch method {
+	default:
+		return nil, &rpcError{rpcMethodNotFound, fmt.Sprintf("unknown method %q", method)}
+
+	case "search":
+		q := p.Query
+		if q == "" {
+			q = "all"
+		}
+		all, err := search(l, q)
+		if err != nil {
+			return nil, &rpcError{rpcServerError, err.Error()}
+		}
+		sort.Sort(tasksByTitle(all))
+		list := []*rpcTask{}
+		for _, t := range all {
+			list = append(list, &rpcTask{List: p.List, ID: t.ID(), Title: t.Title()})
+		}
+		return list, nil
+
+	case "read":
+		t, err = l.Read(p.ID)
+
+	case "write":
+		if t, err = l.Read(p.ID); err == nil {
+			err = l.Write(t, time.Now(), p.Header, []byte(p.Comment))
+		}
+
+	case "create":
+		if p.Header["title"] == "" {
+			return nil, &rpcError{rpcInvalidParams, "missing title header"}
+		}
+		t, err = l.Create(p.ID, time.Now(), p.Header, []byte(p.Comment))
+
+	case "watch":
+		s.mu.Lock()
+		s.watched[p.List] = true
+		s.mu.Unlock()
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &rpcError{rpcErrorCode(err), err.Error()}
+	}
+	return newRPCTask(p.List, t), nil
+}
+
+// newRPCTask returns the JSON form of t, from the named list,
+// as sent by todo serve -stdio and to plugins.
+func newRPCTask(list string, t *task.Task) *rpcTask {
+	rt := &rpcTask{List: list, ID: t.ID(), Title: t.Title(), Header: make(map[string]string)}
+	for _, k := range t.Keys() {
+		rt.Header[k] = t.Header(k)
+	}
+	for _, u := range t.Updates() {
+		rt.Updates = append(rt.Updates, rpcUpdate{Time: u.Time, Header: u.Header, Comment: u.Comment})
+	}
+	return rt
+}
+
+// watch polls the watched lists for changes,
+// sending a notification for each changed task.
+func (s *rpcServer) watch() {
+	last := time.Now()
+	for range time.Tick(time.Second) {
+		now := time.Now()
+		s.mu.Lock()
+		var lists []string
+		for name := range s.watched {
+			lists = append(lists, name)
+		}
+		s.mu.Unlock()
+		sort.Strings(lists)
+		for _, name := range lists {
+			ids, err := s.list(name).Modified(last)
+			if err != nil {
+				continue
+			}
+			for _, id := range ids {
+				s.send(&rpcResponse{Method: "changed", Params: map[string]string{"list": name, "id": id}})
+			}
+		}
+		last = now
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+// Mirroring issues from remote trackers.
+//
+// Each remote issue is mirrored as a task whose external ID (#id)
+// identifies the issue. The synced header records the remote
+// update time as of the last sync, so that later syncs can append
+// only the comments posted since then.
+
+// A tracker is a remote issue tracker.
+type tracker interface {
+	// prefix returns the prefix shared by the external IDs
+	// of all issues in the tracker, such as "github.com/golang/go/issues/".
+	prefix() string
+
+	// open returns the open issues to be mirrored.
+	open() ([]*remoteIssue, error)
+
+	// issue returns the issue with the given ID.
+	issue(id string) (*remoteIssue, error)
+
+	// comments returns the comments on the issue posted after since.
+	comments(ri *remoteIssue, since time.Time) ([]importComment, error)
+
+	// close closes the issue with the given ID.
+	// It returns an error wrapping errCannotClose if
+	// the tracker's issues cannot be closed by sync.
+	close(id string) error
+}
+
+// errCannotClose reports that a tracker's issues cannot be closed by sync.
+var errCannotClose = errors.New("cannot close")
+
+// A remoteIssue is an issue in a tracker.
+type remoteIssue struct {
+	id      string // ID within tracker, such as "123"
+	taskID  string // preferred task ID, if different from id
+	title   string
+	url     string
+	body    string
+	created time.Time
+	updated time.Time
+	closed  bool
+	hdr     map[string]string // additional headers, such as labels; "" for none
+}
+
+// trackerKinds maps the tracker kinds accepted by todo sync
+// to functions returning a tracker for the given argument,
+// such as "golang/go" for github.
+var trackerKinds = map[string]func(arg string) (tracker, error){
+	"bugzilla": newBugzillaTracker,
+	"gerrit":   newGerritTracker,
+	"github":   newGitHubTracker,
+	"gitlab":   newGitLabTracker,
+}
+
+func cmdSync(args []string) {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	push := fs.Bool("push", false, "close remote issues whose tasks are done")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo sync [-push] [kind arg]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	switch fs.NArg() {
+	default:
+		fs.Usage()
+	case 0:
+		// Sync the list and its sublists as configured.
+		failed := false
+		for _, l := range allLists(taskList(*dirFlag)) {
+			for _, line := range l.Config().Values("sync") {
+				f := strings.Fields(line)
+				if len(f) < 2 || len(f) > 3 || len(f) == 3 && f[2] != "push" {
+					log.Printf("%s: invalid sync config %q: want kind arg [push]", l.Name(), line)
+					failed = true
+					continue
+				}
+				if err := syncConfig(l, f[0], f[1], *push || len(f) == 3); err != nil {
+					log.Printf("%s: %v", l.Name(), err)
+					failed = true
+				}
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	case 2:
+		if err := syncConfig(taskList(*dirFlag), fs.Arg(0), fs.Arg(1), *push); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// syncConfig syncs l with the tracker of the given kind and argument.
+func syncConfig(l *task.List, kind, arg string, push bool) error {
+	newTracker := trackerKinds[kind]
+	if newTracker == nil {
+		return fmt.Errorf("unknown tracker kind %q", kind)
+	}
+	tr, err := newTracker(arg)
+	if err != nil {
+		return err
+	}
+	return syncTracker(l, tr, push)
+}
+
+const syncTime = time.RFC3339
+
+// syncTracker mirrors the open issues in tr into l.
+// It creates tasks for new issues, appends new comments to existing tasks,
+// and marks tasks done when their issues are closed.
+// If push is set, it also closes open issues whose tasks are marked done.
+func syncTracker(l *task.List, tr tracker, push bool) error {
+	l.AllowWrites() // a mirror may be read-only to everyone else
+	l.Importing()
+	eids, err := l.ExternalIDs()
+	if err != nil {
+		return err
+	}
+	open, err := tr.open()
+	if err != nil {
+		return err
+	}
+
+	isOpen := make(map[string]bool)
+	for _, ri := range open {
+		isOpen[ri.id] = true
+		t := eids[tr.prefix()+ri.id]
+		if t == nil {
+			t, err = createFromIssue(l, tr, ri)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s\t%s\n", t.ID(), t.Title())
+			continue
+		}
+		if t.Done() {
+			// Leave tasks done or muted locally alone,
+			// other than reporting completion upstream.
+			if push && t.Header("todo") == "done" {
+				if err := tr.close(ri.id); errors.Is(err, errCannotClose) {
+					log.Print(err)
+					continue
+				} else if err != nil {
+					return err
+				}
+				log.Printf("closed %s%s", tr.prefix(), ri.id)
+			}
+			continue
+		}
+		if err := updateFromIssue(l, tr, t, ri); err != nil {
+			return err
+		}
+	}
+
+	// Check tasks whose issues are no longer in the open set.
+	var ids []string
+	for eid, t := range eids {
+		if strings.HasPrefix(eid, tr.prefix()) && !t.Done() {
+			if id := strings.TrimPrefix(eid, tr.prefix()); !isOpen[id] {
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		ri, err := tr.issue(id)
+		if err != nil {
+			return err
+		}
+		if err := updateFromIssue(l, tr, eids[tr.prefix()+id], ri); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createFromIssue creates a task mirroring ri,
+// using the issue's own ID as the task ID when possible.
+func createFromIssue(l *task.List, tr tracker, ri *remoteIssue) (*task.Task, error) {
+	hdr := map[string]string{
+		"#id":    tr.prefix() + ri.id,
+		"title":  oneLine(ri.title),
+		"synced": ri.updated.UTC().Format(syncTime),
+	}
+	if ri.url != "" {
+		hdr["url"] = ri.url
+	}
+	for k, v := range ri.hdr {
+		if v != "" {
+			hdr[k] = v
+		}
+	}
+	id := ri.id
+	if ri.taskID != "" {
+		id = ri.taskID
+	}
+	if l.Exists(id) {
+		id = ""
+	}
+	// Fetch comments first: some trackers fill in ri.body
+	// from the first comment.
+	comments, err := tr.comments(ri, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	t, err := l.Create(id, ri.created, hdr, []byte(ri.body))
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range comments {
+		if err := l.Write(t, c.time, nil, []byte(c.text)); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// updateFromIssue brings t up to date with ri, appending new comments
+// and recording changes to the issue's title, headers, and state.
+func updateFromIssue(l *task.List, tr tracker, t *task.Task, ri *remoteIssue) error {
+	synced, _ := time.Parse(syncTime, t.Header("synced"))
+	if !ri.updated.After(synced) {
+		return nil
+	}
+	comments, err := tr.comments(ri, synced)
+	if err != nil {
+		return err
+	}
+	for _, c := range comments {
+		if err := l.Write(t, c.time, nil, []byte(c.text)); err != nil {
+			return err
+		}
+	}
+
+	hdr := map[string]string{"synced": ri.updated.UTC().Format(syncTime)}
+	if title := oneLine(ri.title); title != t.Title() {
+		hdr["title"] = title
+	}
+	for k, v := range ri.hdr {
+		if t.Header(k) != v {
+			hdr[k] = v
+		}
+	}
+	if ri.closed {
+		hdr["todo"] = "done"
+	}
+	return l.Write(t, ri.updated, hdr, nil)
+}
+
+// trackerRequest makes an HTTP request to a tracker's API,
+// sending body (if non-nil) as JSON and decoding the JSON response
+// into out (if non-nil). If auth is non-nil, it is called to add
+// credentials to the request before sending it.
+// It returns the response header.
+func trackerRequest(method, u string, auth func(*http.Request), body, out interface{}) (http.Header, error) {
+	var js []byte
+	if body != nil {
+		var err error
+		if js, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(js))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if auth != nil {
+		auth(req)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s %s: %s\n%s", method, u, resp.Status, bytes.TrimSpace(data))
+	}
+	if out != nil {
+		// Gerrit prefixes JSON responses with )]}' to defeat XSSI.
+		data = bytes.TrimPrefix(data, []byte(")]}'"))
+		if err := json.Unmarshal(data, out); err != nil {
+			return nil, fmt.Errorf("%s %s: %v", method, u, err)
+		}
+	}
+	return resp.Header, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Query aliases.
+//
+// A list's configuration can define shorthand for queries,
+// using settings of the form
+//
+//	alias: name = query
+//
+// as in "alias: soon = due:<2019-07-01 -todo:snooze".
+// A query term equal to an alias name is replaced by the alias's query,
+// which may itself use aliases. Aliases defined in a list apply to
+// its sublists too, unless a sublist defines the same name.
+// An alias hides the plain-text search for its name.
+
+// Aliases returns the query aliases that apply to the list,
+// mapping each name to its query.
+func (l *List) Aliases() map[string]string {
+	var names []string
+	for name := l.name; ; name = path.Dir(name) {
+		names = append(names, name)
+		if name == "." || name == "/" || name == "" {
+			break
+		}
+	}
+	aliases := make(map[string]string)
+	for i := len(names) - 1; i >= 0; i-- {
+		for _, line := range OpenList(names[i]).Config().Values("alias") {
+			if name, q, ok := parseAlias(line); ok {
+				aliases[name] = q
+			}
+		}
+	}
+	return aliases
+}
+
+// AddAlias defines the alias name in the list's configuration.
+func (l *List) AddAlias(name, query string) error {
+	if !isAliasName(name) {
+		return fmt.Errorf("invalid alias name %q", name)
+	}
+	if strings.TrimSpace(query) == "" {
+		return fmt.Errorf("empty query for alias %s", name)
+	}
+	if _, err := ParseQuery(query); err != nil {
+		return fmt.Errorf("alias %s: %v", name, err)
+	}
+	return l.AddConfig("alias", name+" = "+strings.TrimSpace(query))
+}
+
+// parseAlias parses an alias setting "name = query".
+func parseAlias(line string) (name, query string, ok bool) {
+	i := strings.Index(line, "=")
+	if i < 0 {
+		return "", "", false
+	}
+	name = strings.TrimSpace(line[:i])
+	query = strings.TrimSpace(line[i+1:])
+	if !isAliasName(name) || query == "" {
+		return "", "", false
+	}
+	return name, query, true
+}
+
+// isAliasName reports whether name can be an alias name:
+// a single word that is not a header term or a negation.
+func isAliasName(name string) bool {
+	return name != "" && name != "all" && !strings.ContainsAny(name, ": \t=") && !strings.HasPrefix(name, "-")
+}
+
+// expandAliases returns the query terms in fields
+// with the aliases replaced by their queries.
+// The stack lists the aliases being expanded, to detect loops.
+func expandAliases(fields []string, aliases map[string]string, stack []string) ([]string, error) {
+	var out []string
+	for _, f := range fields {
+		q, ok := aliases[f]
+		if !ok {
+			out = append(out, f)
+			continue
+		}
+		for _, name := range stack {
+			if name == f {
+				return nil, fmt.Errorf("alias loop: %s -> %s", strings.Join(stack, " -> "), f)
+			}
+		}
+		sub, err := expandAliases(strings.Fields(q), aliases, append(stack, f))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sub...)
+	}
+	return out, nil
+}
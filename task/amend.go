@@ -0,0 +1,192 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"time"
+)
+
+// amendedRE matches the note Amend adds to the end of an amended comment.
+var amendedRE = regexp.MustCompile(`\n*\(amended \d{4}-\d\d-\d\d \d\d:\d\d:\d\d\)\s*$`)
+
+// Amend replaces the comment in the task's update with index n,
+// counting from 0 as in Updates, and returns the rewritten task.
+// The update's time and headers are kept, and a note recording
+// the time of the amendment is added at the end of the comment,
+// replacing any earlier such note. The comment may not contain
+// update marker lines, and other lines beginning with "— " are indented
+// by a space, so that reading the file does not take them for the start
+// of an update. The task file is replaced atomically, as by rewrite.
+func (l *List) Amend(id string, n int, comment []byte, now time.Time) (*Task, error) {
+	if err := l.checkWritable(); err != nil {
+		return nil, err
+	}
+	for _, line := range bytes.Split(comment, nl) {
+		if isMarker(line) {
+			return nil, fmt.Errorf("%w comment: contains update marker line %q", ErrMalformed, line)
+		}
+	}
+	comment = escapeComment(bytes.TrimSpace(amendedRE.ReplaceAll(comment, nil)))
+	note := fmt.Sprintf("(amended %s)", now.Local().Format("2006-01-02 15:04:05"))
+
+	t, err := l.rewrite(id, func(t *Task, updates [][]byte, marked []int) error {
+		if n < 0 || n >= len(marked) {
+			return fmt.Errorf("task %s update %d %w", l.ref(id), n, ErrNotExist)
+		}
+		i := marked[n]
+		updates[i] = amendUpdate(updates[i], comment, note)
+		// Drop the rest of the old comment, split off
+		// by unescaped lines beginning with "— ".
+		for j := i + 1; j < len(updates) && (n+1 >= len(marked) || j < marked[n+1]); j++ {
+			updates[j] = nil
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	l.journal("amend", id, fmt.Sprintf("update %d", n))
+	return t, nil
+}
+
+// rewrite rewrites the task file for id after edit changes updates,
+// the raw text of the task's updates, in place. The indexes in marked
+// identify the updates that begin with a marker line, as counted by Updates.
+//
+// The file is rewritten by writing a temporary file and renaming it
+// over the original, so that readers see either the old or the new file.
+// If the file grows while the new one is being written, as when another
+// program appends an update, rewrite starts over, calling edit again.
+// An update appended between that check and the rename lands in the
+// old file, which rewrite keeps linked under another name until the
+// rename is done, so that it can carry the update over to the new file.
+func (l *List) rewrite(id string, edit func(t *Task, updates [][]byte, marked []int) error) (*Task, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for try := 0; ; try++ {
+		delete(l.cache, id)
+		t, err := l.read(id)
+		if err != nil {
+			return nil, err
+		}
+		updates := t.rawUpdates()
+		var marked []int
+		for i, u := range updates {
+			if isMarker(bytes.SplitN(u, nl, 2)[0]) {
+				marked = append(marked, i)
+			}
+		}
+		if err := edit(t, updates, marked); err != nil {
+			return nil, err
+		}
+
+		tmp := t.file + ".rewrite"
+		if err := ioutil.WriteFile(tmp, bytes.Join(updates, nil), 0666); err != nil {
+			os.Remove(tmp)
+			return nil, err
+		}
+		info, err := os.Stat(t.file)
+		if err != nil {
+			os.Remove(tmp)
+			return nil, err
+		}
+		if info.Size() != int64(len(t.body)) {
+			os.Remove(tmp)
+			if try >= 2 {
+				return nil, fmt.Errorf("task %s %w; try again", l.ref(id), ErrConflict)
+			}
+			continue
+		}
+		old := t.file + ".old"
+		os.Remove(old)
+		if err := os.Link(t.file, old); err != nil {
+			old = "" // cannot check for late appends
+		}
+		if err := os.Rename(tmp, t.file); err != nil {
+			os.Remove(tmp)
+			if old != "" {
+				os.Remove(old)
+			}
+			return nil, err
+		}
+		if old != "" {
+			err := carryAppends(old, t.file, int64(len(t.body)))
+			os.Remove(old)
+			if err != nil {
+				return nil, err
+			}
+		}
+		delete(l.cache, id)
+		return l.read(id)
+	}
+}
+
+// escapeComment returns comment with each line beginning with "— "
+// indented by a space.
+func escapeComment(comment []byte) []byte {
+	lines := bytes.SplitAfter(comment, nl)
+	for i, line := range lines {
+		if bytes.HasPrefix(line, emSpace) {
+			lines[i] = append([]byte(" "), line...)
+		}
+	}
+	return bytes.Join(lines, nil)
+}
+
+// carryAppends appends to the file dst anything written to the file
+// old past its first size bytes.
+func carryAppends(old, dst string, size int64) error {
+	data, err := ioutil.ReadFile(old)
+	if err != nil || int64(len(data)) <= size {
+		return err
+	}
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	_, err1 := f.Write(data[size:])
+	err2 := f.Close()
+	if err1 != nil {
+		return err1
+	}
+	return err2
+}
+
+// amendUpdate returns the raw update text u with its comment
+// replaced by comment followed by note.
+func amendUpdate(u, comment []byte, note string) []byte {
+	head, _ := splitUpdate(u)
+	var buf bytes.Buffer
+	buf.Write(head)
+	if len(comment) > 0 {
+		buf.Write(comment)
+		buf.WriteString("\n\n")
+	}
+	buf.WriteString(note + "\n\n")
+	return buf.Bytes()
+}
+
+// splitUpdate splits the raw update text u into its head,
+// the marker and header lines followed by a blank line,
+// and its comment, which is the rest.
+func splitUpdate(u []byte) (head, comment []byte) {
+	lines := bytes.SplitAfter(u, nl)
+	n := len(lines[0])
+	for _, line := range lines[1:] {
+		if len(bytes.TrimSpace(line)) == 0 || !bytes.Contains(line, []byte(":")) {
+			break
+		}
+		n += len(line)
+	}
+	head = append(u[:n:n], '\n')
+	comment = bytes.TrimLeft(u[n:], "\n")
+	return head, comment
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Attachments are files too large to record in a task's updates,
+// such as big diffs. They are stored in _attach/id/ in the list's directory
+// and are meant to be referred to by file name in the task's text.
+
+// Attach stores data as the attachment name of the task id
+// and returns the name of the file holding it.
+// The task need not exist yet, so that programs creating a task
+// can refer to the attachment in its first update.
+func (l *List) Attach(id, name string, data []byte) (string, error) {
+	if !isAttachName(id) || !isAttachName(name) {
+		return "", fmt.Errorf("invalid attachment %s/%s", id, name)
+	}
+	if err := l.checkWritable(); err != nil {
+		return "", err
+	}
+	return l.attach(id, name, data)
+}
+
+// attach is like Attach but does not check its arguments
+// or whether l is writable.
+func (l *List) attach(id, name string, data []byte) (string, error) {
+	dir := l.attachDir(id)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(file, data, 0666); err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
+// Attachments returns the names of the files attached to t.
+func (l *List) Attachments(t *Task) ([]string, error) {
+	infos, err := ioutil.ReadDir(l.attachDir(t.id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return nil, err
+	}
+	var files []string
+	for _, info := range infos {
+		if !info.IsDir() {
+			files = append(files, filepath.Join(l.attachDir(t.id), info.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func (l *List) attachDir(id string) string {
+	// An archived task's attachments are archived with it,
+	// as in _done/2019-06/_attach/123 (see ArchiveDone).
+	if dir, name := path.Split(id); strings.HasPrefix(dir, "_done/") {
+		return filepath.Join(l.dir, filepath.FromSlash(dir), "_attach", name)
+	}
+	return filepath.Join(l.dir, "_attach", id)
+}
+
+func isAttachName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, `/\`) && !strings.HasPrefix(name, ".")
+}
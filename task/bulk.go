@@ -0,0 +1,116 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// A BulkResult reports what BulkWrite did to one task.
+type BulkResult struct {
+	ID      string
+	Task    *Task // the task, or nil if it could not be read
+	Changed bool  // whether an update was written
+	Err     error
+}
+
+// BulkWrite writes the same update, setting the headers in hdr and
+// adding comment, to each of the tasks ids, as Write does for one task.
+// It checks every task before changing any: each must exist and parse,
+// and its file must be writable. If any check fails, BulkWrite changes
+// nothing and returns an error, with results describing the failures.
+// Otherwise it writes the update to each task in turn, leaving out
+// the headers a task already has with the same values and skipping
+// tasks for which that leaves nothing to write. The results, one per
+// task in the order of ids, report what happened to each task,
+// and the error is non-nil if any write failed.
+func (l *List) BulkWrite(ids []string, now time.Time, hdr map[string]string, comment []byte) ([]BulkResult, error) {
+	if err := l.checkWritable(); err != nil {
+		return nil, err
+	}
+	hdr = canonicalizeHeaders(hdr, l.HeaderAliases())
+	normalizeDates(hdr, now)
+	if err := l.checkState(hdr); err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	results := make([]BulkResult, len(ids))
+	seen := make(map[string]bool)
+	bad := 0
+	for i, id := range ids {
+		r := &results[i]
+		r.ID = id
+		if seen[id] {
+			r.Err = fmt.Errorf("task %s listed twice", id)
+			bad++
+			continue
+		}
+		seen[id] = true
+		delete(l.cache, id) // reread: another program may have changed it
+		t, err := l.read(id)
+		if err != nil {
+			r.Err = err
+			bad++
+			continue
+		}
+		r.Task = t
+		f, err := os.OpenFile(t.file, os.O_WRONLY|os.O_APPEND, 0666)
+		if err != nil {
+			r.Err = err
+			bad++
+			continue
+		}
+		f.Close()
+	}
+	if bad > 0 {
+		l.mu.Unlock()
+		return results, fmt.Errorf("%d of %d tasks cannot be written; nothing changed", bad, len(ids))
+	}
+
+	events := make([]string, len(ids))
+	writes := make([]map[string]string, len(ids))
+	failed := 0
+	for i := range results {
+		r := &results[i]
+		t := r.Task
+		h := make(map[string]string)
+		for k, v := range hdr {
+			if t.Header(k) != v {
+				h[k] = v
+			}
+		}
+		if len(h) == 0 && len(comment) == 0 {
+			continue
+		}
+		h = statusHeaders(t, now, h, l.importing)
+		wasDone := t.Done()
+		if err := l.write(t, now, h, comment); err != nil {
+			r.Err = err
+			failed++
+			continue
+		}
+		r.Changed = true
+		events[i] = "update"
+		if t.Done() && !wasDone {
+			events[i] = "done"
+		}
+		writes[i] = h
+	}
+	l.mu.Unlock()
+
+	for i, r := range results {
+		if r.Changed {
+			l.journal(events[i], r.ID, changeDetail(writes[i], comment))
+			l.runHooks(events[i], r.Task, now, writes[i], comment)
+		}
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("failed to update %d of %d tasks", failed, len(ids))
+	}
+	return results, nil
+}
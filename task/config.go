@@ -0,0 +1,140 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// A Config holds a list's configuration settings,
+// read from the _config file in the list's directory.
+//
+// The file uses the same "key: value" syntax as task headers,
+// one setting per line. Blank lines and lines beginning with #
+// are ignored. A key may be repeated to give multiple values.
+type Config struct {
+	vals map[string][]string
+}
+
+// Config returns the list's configuration.
+// A list with no _config file has an empty configuration.
+func (l *List) Config() *Config {
+	c := &Config{vals: make(map[string][]string)}
+	data, err := ioutil.ReadFile(filepath.Join(l.dir, "_config"))
+	if err != nil {
+		return c
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		k := strings.ToLower(strings.TrimSpace(line[:i]))
+		c.vals[k] = append(c.vals[k], strings.TrimSpace(line[i+1:]))
+	}
+	return c
+}
+
+// checkConfig drops the list's cached settings, and the tasks read
+// using them, if the _config files of the list and the lists
+// containing it have changed since they were read.
+func (l *List) checkConfig() {
+	// l is locked
+	var stamp []string
+	for name := l.name; ; name = path.Dir(name) {
+		if info, err := os.Stat(filepath.Join(dir(name), "_config")); err == nil {
+			stamp = append(stamp, fmt.Sprint(info.ModTime().UnixNano(), info.Size()))
+		} else {
+			stamp = append(stamp, "-")
+		}
+		if name == "." || name == "/" || name == "" {
+			break
+		}
+	}
+	s := strings.Join(stamp, " ")
+	if s == l.cfgStamp {
+		return
+	}
+	l.cfgStamp = s
+	l.stateCfg = nil
+	l.hdrAlias = nil
+	l.cache = nil
+}
+
+// About returns the contents of the list's _about file,
+// which describes the list and its conventions for people using it,
+// or "" if there is none.
+func (l *List) About() string {
+	data, err := ioutil.ReadFile(filepath.Join(l.dir, "_about"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Get returns the last value for key, or "" if there is none.
+func (c *Config) Get(key string) string {
+	v := c.vals[strings.ToLower(key)]
+	if len(v) == 0 {
+		return ""
+	}
+	return v[len(v)-1]
+}
+
+// Values returns all the values for key, in file order.
+func (c *Config) Values(key string) []string {
+	return c.vals[strings.ToLower(key)]
+}
+
+// Setting returns the last value for key in the configuration
+// of the list or, if it has none, of the nearest list containing it,
+// for settings that apply to sublists too. It returns "" if no list
+// has a value for key.
+func (l *List) Setting(key string) string {
+	for name := l.name; ; name = path.Dir(name) {
+		if v := OpenList(name).Config().Get(key); v != "" {
+			return v
+		}
+		if name == "." || name == "/" || name == "" {
+			return ""
+		}
+	}
+}
+
+// AddConfig appends the setting "key: value" to the list's _config file,
+// creating it if necessary.
+func (l *List) AddConfig(key, value string) error {
+	if key == "" || strings.ContainsAny(key, ":\n") || strings.Contains(value, "\n") {
+		return fmt.Errorf("invalid setting %q: %q", key, value)
+	}
+	file := filepath.Join(l.dir, "_config")
+	data, err := ioutil.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+	data = append(data, key+": "+value+"\n"...)
+	return ioutil.WriteFile(file, data, 0666)
+}
+
+// Template returns the named task template,
+// read from the file _template/name in the list's directory.
+func (l *List) Template(name string) ([]byte, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("invalid template name %q", name)
+	}
+	return ioutil.ReadFile(filepath.Join(l.dir, "_template", name))
+}
@@ -0,0 +1,217 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseDate parses a date given relative to now, returning midnight
+// at the start of that day in now's location. The date can be
+// an explicit date (2006-01-02);
+// today, tomorrow, or yesterday;
+// a weekday name, such as monday or mon, meaning the next such day
+// after today, or preceded by next, meaning the one a week after that;
+// next week or next month;
+// a number of days or weeks from today, such as 3d, 2w, or +3;
+// in followed by a number of days, weeks, or months, such as in 3 weeks;
+// or a month and day, such as jun 5 or 5 june, meaning the next such day
+// on or after today, or with a year, such as jun 5 2027.
+// Names are English, whatever the locale, and case is ignored.
+// A bare number, such as 3 or 2027, is not a date: it could as well
+// be a day of the month or a year.
+func ParseDate(s string, now time.Time) (time.Time, error) {
+	orig := s
+	s = strings.ToLower(strings.TrimSpace(s))
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t, nil
+	}
+	f := strings.Fields(strings.Replace(s, ",", " ", -1))
+	switch strings.Join(f, " ") {
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	case "next week":
+		return today.AddDate(0, 0, 7), nil
+	case "next month":
+		return today.AddDate(0, 1, 0), nil
+	}
+	next := 0
+	if len(f) == 2 && f[0] == "next" {
+		f = f[1:]
+		next = 7
+	}
+	if len(f) == 1 {
+		if d, ok := parseWeekday(f[0]); ok {
+			n := (int(d)-int(today.Weekday())+6)%7 + 1
+			return today.AddDate(0, 0, n+next), nil
+		}
+	}
+	if len(f) == 3 && f[0] == "in" {
+		if n, err := strconv.Atoi(f[1]); err == nil {
+			switch strings.TrimSuffix(f[2], "s") {
+			case "day":
+				return today.AddDate(0, 0, n), nil
+			case "week":
+				return today.AddDate(0, 0, 7*n), nil
+			case "month":
+				return today.AddDate(0, n, 0), nil
+			}
+		}
+	}
+	if t, ok := parseMonthDay(f, today); ok {
+		return t, nil
+	}
+	if len(f) == 1 && next == 0 {
+		s, unit := strings.TrimPrefix(f[0], "+"), 0
+		switch {
+		case strings.HasSuffix(s, "d"):
+			s, unit = strings.TrimSuffix(s, "d"), 1
+		case strings.HasSuffix(s, "w"):
+			s, unit = strings.TrimSuffix(s, "w"), 7
+		case strings.HasPrefix(f[0], "+"):
+			unit = 1
+		}
+		if n, err := strconv.Atoi(s); err == nil && unit > 0 && s[0] != '+' {
+			return today.AddDate(0, 0, n*unit), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q: want 2006-01-02, tomorrow, weekday, jun 5, count of days (3d) or weeks (2w), or in 3 weeks", orig)
+}
+
+// parseWeekday parses a weekday name, such as tuesday or tue.
+func parseWeekday(s string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name[:3] {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// parseMonth parses a month name, such as june or jun.
+func parseMonth(s string) (time.Month, bool) {
+	for m := time.January; m <= time.December; m++ {
+		name := strings.ToLower(m.String())
+		if s == name || s == name[:3] || s == "sept" && m == time.September {
+			return m, true
+		}
+	}
+	return 0, false
+}
+
+// parseMonthDay parses a month and day, in either order,
+// optionally followed by a year. Without a year, the date is
+// the next such day on or after today.
+func parseMonthDay(f []string, today time.Time) (time.Time, bool) {
+	if len(f) != 2 && len(f) != 3 {
+		return time.Time{}, false
+	}
+	m, ok := parseMonth(f[0])
+	day := f[1]
+	if !ok {
+		m, ok = parseMonth(f[1])
+		day = f[0]
+	}
+	if !ok {
+		return time.Time{}, false
+	}
+	d, err := strconv.Atoi(day)
+	if err != nil || d < 1 || d > 31 {
+		return time.Time{}, false
+	}
+	year := today.Year()
+	if len(f) == 3 {
+		if year, err = strconv.Atoi(f[2]); err != nil {
+			return time.Time{}, false
+		}
+	}
+	t := time.Date(year, m, d, 0, 0, 0, 0, today.Location())
+	if t.Month() != m {
+		return time.Time{}, false // jun 31
+	}
+	if len(f) == 2 && t.Before(today) {
+		t = time.Date(year+1, m, d, 0, 0, 0, 0, today.Location())
+	}
+	return t, true
+}
+
+// NormalizeDate returns the header value s with a date
+// written as understood by ParseDate, such as tomorrow 15:00,
+// rewritten in the form 2006-01-02, keeping any time of day.
+// It returns s unchanged if s is already in that form or is not a date.
+func NormalizeDate(s string, now time.Time) string {
+	s = strings.TrimSpace(s)
+	date, clock := s, ""
+	if i := strings.LastIndex(s, " "); i >= 0 && isClock(s[i+1:]) {
+		date, clock = s[:i], s[i:]
+	}
+	if _, err := time.Parse("2006-01-02", date); err == nil {
+		return s
+	}
+	t, err := ParseDate(date, now)
+	if err != nil {
+		return s
+	}
+	return t.Format("2006-01-02") + clock
+}
+
+// isClock reports whether s is a time of day, such as 15:04 or 15:04:05.
+func isClock(s string) bool {
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// dateHeaders lists the headers holding dates, which are
+// normalized when written and compared as dates in queries.
+var dateHeaders = map[string]bool{
+	"due":       true,
+	"scheduled": true,
+}
+
+// normalizeDates rewrites the dates in hdr using NormalizeDate,
+// including the date in a todo header that sets a snooze, waiting,
+// or mute until state and the time in a remind header,
+// as normalizeRemind does.
+func normalizeDates(hdr map[string]string, now time.Time) {
+	for k, v := range hdr {
+		if dateHeaders[k] && v != "" {
+			hdr[k] = NormalizeDate(v, now)
+		}
+		if k == "todo" {
+			hdr[k] = normalizeSnooze(normalizeMute(normalizeWaiting(v, now), now), now)
+		}
+		if k == "remind" && v != "" {
+			hdr[k] = normalizeRemind(v, now)
+		}
+	}
+}
+
+// queryDate returns the date query value v, such as tomorrow or jun-5,
+// rewritten in the form 2006-01-02. Because query terms cannot contain
+// spaces, dashes separate the words of a date, as in next-tue or in-3-weeks.
+// It returns v unchanged if v is not a date.
+func queryDate(v string, now time.Time) string {
+	if v == "" || v[0] >= '0' && v[0] <= '9' && strings.Contains(v, "-") {
+		return v
+	}
+	t, err := ParseDate(strings.Replace(v, "-", " ", -1), now)
+	if err != nil {
+		return v
+	}
+	return t.Format("2006-01-02")
+}
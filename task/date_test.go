@@ -0,0 +1,151 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// dateNow is a Thursday.
+var dateNow = time.Date(2019, time.July, 4, 15, 30, 0, 0, time.UTC)
+
+var parseDateTests = []struct {
+	in  string
+	out string // "" for an error
+}{
+	{"2019-07-10", "2019-07-10"},
+	{"today", "2019-07-04"},
+	{"Tomorrow", "2019-07-05"},
+	{"yesterday", "2019-07-03"},
+	{"next week", "2019-07-11"},
+	{"next month", "2019-08-04"},
+
+	// A weekday is the next one after today;
+	// next weekday is the one a week after that.
+	{"fri", "2019-07-05"},
+	{"friday", "2019-07-05"},
+	{"next fri", "2019-07-12"},
+	{"thu", "2019-07-11"},
+	{"next thu", "2019-07-18"},
+	{"mon", "2019-07-08"},
+	{"next mon", "2019-07-15"},
+
+	{"3d", "2019-07-07"},
+	{"+3d", "2019-07-07"},
+	{"+3", "2019-07-07"},
+	{"2w", "2019-07-18"},
+	{"in 3 days", "2019-07-07"},
+	{"in 2 weeks", "2019-07-18"},
+	{"in 1 month", "2019-08-04"},
+
+	{"jul 10", "2019-07-10"},
+	{"10 july", "2019-07-10"},
+	{"jul 1", "2020-07-01"},
+	{"jun 5 2027", "2027-06-05"},
+	{"Jun 5, 2027", "2027-06-05"},
+
+	// Ambiguous: a bare number could be a count of days,
+	// a day of the month, or a year, and slashed dates
+	// could put the month or the day first.
+	{"3", ""},
+	{"2027", ""},
+	{"7/1", ""},
+	{"1/7/2019", ""},
+	{"next 3d", ""},
+	{"jun 31", ""},
+
+	// Names are English whatever the locale.
+	{"lundi", ""},
+	{"demain", ""},
+	{"5 juin", ""},
+}
+
+func TestParseDate(t *testing.T) {
+	for _, tt := range parseDateTests {
+		d, err := ParseDate(tt.in, dateNow)
+		if tt.out == "" {
+			if err == nil {
+				t.Errorf("ParseDate(%q) = %s, want error", tt.in, d.Format("2006-01-02"))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseDate(%q): %v", tt.in, err)
+			continue
+		}
+		if out := d.Format("2006-01-02"); out != tt.out {
+			t.Errorf("ParseDate(%q) = %s, want %s", tt.in, out, tt.out)
+		}
+	}
+}
+
+func TestParseDateLocale(t *testing.T) {
+	for _, key := range []string{"LANG", "LC_ALL", "LC_TIME"} {
+		old, ok := os.LookupEnv(key)
+		os.Setenv(key, "fr_FR.UTF-8")
+		if ok {
+			defer os.Setenv(key, old)
+		} else {
+			defer os.Unsetenv(key)
+		}
+	}
+	TestParseDate(t)
+}
+
+func TestParseDateLocation(t *testing.T) {
+	// Dates are days in now's location, not UTC.
+	loc := time.FixedZone("UTC-10", -10*60*60)
+	now := time.Date(2019, time.July, 4, 20, 0, 0, 0, loc) // July 5 in UTC
+	d, err := ParseDate("tomorrow", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2019, time.July, 5, 0, 0, 0, 0, loc); !d.Equal(want) {
+		t.Errorf("ParseDate(tomorrow) = %v, want %v", d, want)
+	}
+}
+
+func TestNormalizeDate(t *testing.T) {
+	for _, tt := range []struct{ in, out string }{
+		{"tomorrow 15:00", "2019-07-05 15:00"},
+		{"2019-07-10", "2019-07-10"},
+		{"next fri", "2019-07-12"},
+		{"2027", "2027"},
+		{"7/1", "7/1"},
+	} {
+		if out := NormalizeDate(tt.in, dateNow); out != tt.out {
+			t.Errorf("NormalizeDate(%q) = %q, want %q", tt.in, out, tt.out)
+		}
+	}
+}
+
+func TestNormalizeDeferred(t *testing.T) {
+	for _, tt := range []struct {
+		in, out string
+		ok      bool
+	}{
+		{"snooze next fri", "snooze 2019-07-12", true},
+		{"snooze 2019-07-10", "snooze 2019-07-10", true},
+		{"snooze 3d", "snooze 2019-07-07", true},
+		{"snooze someday", "snooze someday", false},
+		{"snooze", "snooze", false},
+		{"waiting alice tomorrow", "waiting alice 2019-07-05", true},
+		{"waiting alice", "waiting alice", true},
+		{"mute until jul 10", "mute until 2019-07-10", true},
+		{"mute until later", "mute until later", false},
+		{"mute", "mute", true},
+	} {
+		hdr := map[string]string{"todo": tt.in}
+		normalizeDates(hdr, dateNow)
+		if out := hdr["todo"]; out != tt.out {
+			t.Errorf("normalizeDates(todo: %s) = %q, want %q", tt.in, out, tt.out)
+		}
+		if err := checkDeferred(hdr["todo"]); (err == nil) != tt.ok {
+			t.Errorf("checkDeferred(%q) = %v, want ok=%v", hdr["todo"], err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Drafts.
+//
+// A draft is the text of a new task being composed, kept in the
+// _drafts directory in the list's directory until the task is created,
+// so that the text survives a failed creation or an interrupted editor.
+// Drafts are named for the time they were started, as in 20190605-150405,
+// with a suffix to keep the names unique, as in 20190605-150405.2.
+
+// draftTime is the layout of draft names.
+const draftTime = "20060102-150405"
+
+// A Draft is a saved draft of a new task.
+type Draft struct {
+	Name  string    // draft name
+	Title string    // title in the draft text, if any
+	Mtime time.Time // time the draft was last saved
+}
+
+// NewDraft saves text as a new draft in l and returns the draft's name.
+func (l *List) NewDraft(now time.Time, text []byte) (string, error) {
+	if err := l.checkWritable(); err != nil {
+		return "", err
+	}
+	dir := filepath.Join(l.dir, "_drafts")
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return "", err
+	}
+	base := now.Local().Format(draftTime)
+	for i := 1; ; i++ {
+		name := base
+		if i > 1 {
+			name += "." + strconv.Itoa(i)
+		}
+		f, err := os.OpenFile(l.DraftFile(name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = f.Write(text)
+		if err1 := f.Close(); err == nil {
+			err = err1
+		}
+		return name, err
+	}
+}
+
+// DraftFile returns the name of the file holding the named draft,
+// for programs such as editors that work on files.
+func (l *List) DraftFile(name string) string {
+	return filepath.Join(l.dir, "_drafts", name)
+}
+
+// ReadDraft returns the text of the named draft.
+func (l *List) ReadDraft(name string) ([]byte, error) {
+	if !validDraftName(name) {
+		return nil, draftNotExist(name)
+	}
+	data, err := ioutil.ReadFile(l.DraftFile(name))
+	if err != nil {
+		return nil, fileError("draft "+name, err)
+	}
+	return data, nil
+}
+
+// WriteDraft replaces the text of the named draft.
+func (l *List) WriteDraft(name string, text []byte) error {
+	if !validDraftName(name) {
+		return draftNotExist(name)
+	}
+	if err := l.checkWritable(); err != nil {
+		return err
+	}
+	file := l.DraftFile(name)
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, text, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// RemoveDraft removes the named draft, as when its task has been created.
+func (l *List) RemoveDraft(name string) error {
+	if !validDraftName(name) {
+		return draftNotExist(name)
+	}
+	if err := os.Remove(l.DraftFile(name)); err != nil {
+		return fileError("draft "+name, err)
+	}
+	os.Remove(filepath.Join(l.dir, "_drafts")) // if empty
+	return nil
+}
+
+// Drafts returns the drafts saved in l, least recently saved first.
+func (l *List) Drafts() ([]*Draft, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(l.dir, "_drafts"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return nil, err
+	}
+	var out []*Draft
+	for _, info := range infos {
+		if !validDraftName(info.Name()) || info.IsDir() {
+			continue
+		}
+		d := &Draft{Name: info.Name(), Mtime: info.ModTime()}
+		if data, err := ioutil.ReadFile(l.DraftFile(d.Name)); err == nil {
+			d.Title = titleOf(data)
+		}
+		out = append(out, d)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Mtime.Before(out[j].Mtime) })
+	return out, nil
+}
+
+// draftNotExist returns the error for a missing draft.
+func draftNotExist(name string) error {
+	return fileError("draft "+name, os.ErrNotExist)
+}
+
+// validDraftName reports whether name is a draft name,
+// as opposed to a temporary file or a path outside _drafts.
+func validDraftName(name string) bool {
+	base := name
+	if i := strings.Index(name, "."); i >= 0 {
+		base = name[:i]
+		for _, c := range name[i+1:] {
+			if c < '0' || c > '9' {
+				return false
+			}
+		}
+	}
+	_, err := time.Parse(draftTime, base)
+	return err == nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Errors returned by the package, wrapped with details about the task
+// or list involved, so that callers can test for them with errors.Is.
+var (
+	ErrNotExist  = errors.New("does not exist")       // task, list, or update not found
+	ErrExists    = errors.New("already exists")       // task or list already exists
+	ErrMalformed = errors.New("malformed")            // task file or update cannot be parsed
+	ErrConflict  = errors.New("changed concurrently") // another program kept changing the task
+	ErrReadOnly  = errors.New("read-only")            // list is read-only; see ReadOnlyError
+)
+
+// Is reports whether target is ErrReadOnly,
+// so that errors.Is(err, ErrReadOnly) matches a *ReadOnlyError.
+func (e *ReadOnlyError) Is(target error) bool {
+	return target == ErrReadOnly
+}
+
+// fileError returns err, an error from the file system about the
+// thing named by what, such as "task work/12", wrapped so that
+// a missing file is reported as ErrNotExist and an existing one
+// as ErrExists. The original error remains available via errors.As.
+func fileError(what string, err error) error {
+	switch {
+	case os.IsNotExist(err):
+		return &wrapError{fmt.Sprintf("%s %v", what, ErrNotExist), ErrNotExist, err}
+	case os.IsExist(err):
+		return &wrapError{fmt.Sprintf("%s %v", what, ErrExists), ErrExists, err}
+	}
+	return fmt.Errorf("%s: %w", what, err)
+}
+
+// A wrapError is an error matching both kind and err.
+type wrapError struct {
+	msg  string
+	kind error
+	err  error
+}
+
+func (e *wrapError) Error() string { return e.msg }
+func (e *wrapError) Unwrap() error { return e.err }
+
+func (e *wrapError) Is(target error) bool {
+	return target == e.kind
+}
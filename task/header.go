@@ -0,0 +1,205 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Header aliases.
+//
+// A list's configuration can declare alternate names for headers,
+// with settings like
+//
+//	header: pri = priority
+//	header: owner = assignee
+//
+// An aliased header is read, written, and matched in queries
+// under its canonical name, so that a task file that says
+// "pri: 1" has a priority header and matches priority:1.
+// Header aliases defined in a list apply to its sublists too.
+
+// HeaderAliases returns the header aliases that apply to the list,
+// mapping each alias to its canonical header name.
+func (l *List) HeaderAliases() map[string]string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.checkConfig()
+	return l.headerAliases()
+}
+
+func (l *List) headerAliases() map[string]string {
+	// l is locked
+	if l.hdrAlias != nil {
+		return l.hdrAlias
+	}
+	var names []string
+	for name := l.name; ; name = path.Dir(name) {
+		names = append(names, name)
+		if name == "." || name == "/" || name == "" {
+			break
+		}
+	}
+	aliases := make(map[string]string)
+	for i := len(names) - 1; i >= 0; i-- {
+		for _, line := range OpenList(names[i]).Config().Values("header") {
+			if alias, name, ok := parseHeaderAlias(line); ok {
+				aliases[alias] = name
+			}
+		}
+	}
+	l.hdrAlias = aliases
+	return aliases
+}
+
+// parseHeaderAlias parses a header alias setting "alias = name".
+func parseHeaderAlias(line string) (alias, name string, ok bool) {
+	i := strings.Index(line, "=")
+	if i < 0 {
+		return "", "", false
+	}
+	alias = strings.ToLower(strings.TrimSpace(line[:i]))
+	name = strings.ToLower(strings.TrimSpace(line[i+1:]))
+	if !isHeaderName(alias) || !isHeaderName(name) || alias == name {
+		return "", "", false
+	}
+	return alias, name, true
+}
+
+// isHeaderName reports whether name can be used as a header key.
+func isHeaderName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, ": \t=\n") && !strings.HasPrefix(name, "#")
+}
+
+// canonicalHeader returns the canonical name for the header key k.
+func canonicalHeader(aliases map[string]string, k string) string {
+	if name, ok := aliases[strings.ToLower(k)]; ok {
+		return name
+	}
+	return k
+}
+
+// canonicalizeHeaders returns a copy of hdr with the aliased headers
+// renamed to their canonical names, leaving hdr itself unchanged,
+// so that writes can go on to rewrite the copy's values.
+// A header given under both names keeps the value given under
+// the canonical name.
+func canonicalizeHeaders(hdr map[string]string, aliases map[string]string) map[string]string {
+	out := make(map[string]string, len(hdr))
+	for k, v := range hdr {
+		if _, ok := aliases[k]; !ok {
+			out[k] = v
+		}
+	}
+	for k, v := range hdr {
+		name, ok := aliases[k]
+		if !ok {
+			continue
+		}
+		if _, ok := out[name]; !ok {
+			out[name] = v
+		}
+	}
+	return out
+}
+
+// RenameHeader renames the header old to new in every update
+// of the task id, rewriting the task file as Amend does.
+// It reports whether any update had the header.
+// Renaming to a header the update already sets is an error,
+// since one of the two values would be lost.
+func (l *List) RenameHeader(id, old, new string) (*Task, bool, error) {
+	if err := l.checkWritable(); err != nil {
+		return nil, false, err
+	}
+	old, new = strings.ToLower(old), strings.ToLower(new)
+	if !isHeaderName(old) || !isHeaderName(new) {
+		return nil, false, fmt.Errorf("invalid header rename %q to %q", old, new)
+	}
+	changed := false
+	t, err := l.rewrite(id, func(t *Task, updates [][]byte, marked []int) error {
+		changed = false
+		for _, i := range marked {
+			u, ok, err := renameUpdateHeader(updates[i], old, new)
+			if err != nil {
+				return fmt.Errorf("task %s: %w", l.ref(id), err)
+			}
+			if ok {
+				updates[i] = u
+				changed = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if changed {
+		l.journal("rename", id, old+" to "+new)
+	}
+	return t, changed, nil
+}
+
+// HeaderUpdates returns the number of t's updates that set the header
+// name as written in the task file, before header aliases apply:
+// the updates that RenameHeader would change.
+func (t *Task) HeaderUpdates(name string) int {
+	name = strings.ToLower(name)
+	n := 0
+	for _, u := range t.rawUpdates() {
+		if !isMarker(bytes.SplitN(u, nl, 2)[0]) {
+			continue
+		}
+		head, _ := splitUpdate(u)
+		for _, line := range bytes.SplitAfter(head, nl)[1:] {
+			j := bytes.IndexByte(line, ':')
+			if j < 0 {
+				break
+			}
+			if string(bytes.ToLower(bytes.TrimSpace(line[:j]))) == name {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+// renameUpdateHeader returns the raw update text u with the header
+// old renamed to new, and whether u had the header.
+func renameUpdateHeader(u []byte, old, new string) ([]byte, bool, error) {
+	head, comment := splitUpdate(u)
+	lines := bytes.SplitAfter(head, nl)
+	found, have := -1, false
+	for i, line := range lines[1:] {
+		j := bytes.IndexByte(line, ':')
+		if j < 0 {
+			break
+		}
+		switch string(bytes.ToLower(bytes.TrimSpace(line[:j]))) {
+		case old:
+			found = i + 1
+		case new:
+			have = true
+		}
+	}
+	if found < 0 {
+		return u, false, nil
+	}
+	if have {
+		return nil, false, fmt.Errorf("update %s sets both %s and %s: %w", bytes.TrimSpace(lines[0]), old, new, ErrConflict)
+	}
+	line := lines[found]
+	lines[found] = append([]byte(new), line[bytes.IndexByte(line, ':'):]...)
+	var buf bytes.Buffer
+	buf.Write(bytes.Join(lines, nil))
+	if len(comment) > 0 {
+		buf.Write(comment)
+	}
+	return buf.Bytes(), true, nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Hooks.
+//
+// A list's configuration can name programs to run or URLs to notify
+// when its tasks change, using settings of the form
+//
+//	hook: event target
+//
+// The event is create, update, done, or * for all three.
+// An update that marks a task done or muted is a done event, not an update.
+// If the target begins with http:// or https://, the hook POSTs
+// the event to that URL. Otherwise the target is a command line,
+// split into fields, which is run with the event on standard input.
+// Either way the event is encoded as a JSON HookEvent.
+//
+// Hooks run after the change has been written. A failing hook
+// does not undo the change; the failure is reported to HookError.
+
+// A HookEvent describes a change to a task, as sent to hooks.
+type HookEvent struct {
+	Event   string            `json:"event"`            // "create", "update", or "done"
+	List    string            `json:"list"`             // list name
+	ID      string            `json:"id"`               // task ID
+	Title   string            `json:"title"`            // task title, after the change
+	Time    string            `json:"time"`             // "2006-01-02 15:04:05"
+	Change  map[string]string `json:"change,omitempty"` // headers set (or, if empty, cleared)
+	Comment string            `json:"comment,omitempty"`
+	Header  map[string]string `json:"header"` // all headers, after the change
+}
+
+// HookError is called to report a hook that failed.
+// By default it prints the error to standard error.
+var HookError = func(target string, err error) {
+	fmt.Fprintf(os.Stderr, "todo: hook %s: %v\n", target, err)
+}
+
+// hookTimeout bounds how long a single hook may run.
+const hookTimeout = 30 * time.Second
+
+func (l *List) runHooks(event string, t *Task, now time.Time, hdr map[string]string, comment []byte) {
+	var targets []string
+	for _, line := range l.Config().Values("hook") {
+		f := strings.Fields(line)
+		if len(f) < 2 {
+			HookError(line, fmt.Errorf("invalid hook config: want event target"))
+			continue
+		}
+		if f[0] == event || f[0] == "*" {
+			targets = append(targets, strings.TrimSpace(line[len(f[0]):]))
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	e := &HookEvent{
+		Event:   event,
+		List:    l.name,
+		ID:      t.id,
+		Title:   t.Title(),
+		Time:    now.Local().Format("2006-01-02 15:04:05"),
+		Change:  hdr,
+		Comment: string(comment),
+		Header:  make(map[string]string),
+	}
+	for k, v := range t.hdr {
+		e.Header[k] = v
+	}
+	js, err := json.Marshal(e)
+	if err != nil {
+		HookError(targets[0], err)
+		return
+	}
+	for _, target := range targets {
+		if err := runHook(target, js); err != nil {
+			HookError(target, err)
+		}
+	}
+}
+
+func runHook(target string, js []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		req, err := http.NewRequest("POST", target, bytes.NewReader(js))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("%s", resp.Status)
+		}
+		return nil
+	}
+
+	args := strings.Fields(target)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(js)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v\n%s", err, out)
+	}
+	return nil
+}
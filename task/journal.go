@@ -0,0 +1,206 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The journal.
+//
+// Every change to a list's tasks is recorded in the _journal file in the
+// list's directory, one line per change, so that programs interested in
+// recent activity can read the journal instead of every task file.
+// Each line holds tab-separated fields:
+//
+//	time event id actor detail
+//
+// The time is in RFC 3339 format. The event is create, update, done,
+// move, delete, restore, amend, slim, or rename. The actor is $TODO_ACTOR,
+// or else the user name. For create, update, and done, the detail lists
+// the headers set, with "comment" if the change added one; for a move,
+// it names the other end of the move, as in "to work/123" or "from inbox/4".
+//
+// Once a journal reaches journalMax bytes, it is moved into the list's
+// _journals directory, in a file named for the time of the move,
+// as in _journals/20190701-150405, and a new _journal is started.
+// Reading the changes since a given time skips the older files.
+
+// A JournalEntry is a single change recorded in a list's journal.
+type JournalEntry struct {
+	Time   time.Time
+	Event  string
+	ID     string
+	Actor  string
+	Detail string
+}
+
+// journalMax is the size at which a list's journal is moved
+// into its _journals directory.
+const journalMax = 1 << 20
+
+// journalTime is the layout of the names of the files in _journals.
+const journalTime = "20060102-150405"
+
+// journalError reports a failure to record a change in a list's journal.
+// The change itself has already been made.
+func journalError(list string, err error) {
+	fmt.Fprintf(os.Stderr, "todo: journal for %s: %v\n", list, err)
+}
+
+// Journal returns the changes recorded in l's journal at or after since,
+// oldest first.
+func (l *List) Journal(since time.Time) ([]*JournalEntry, error) {
+	var files []string
+	old, err := ioutil.ReadDir(filepath.Join(l.dir, "_journals"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, info := range old {
+		// A file holds the changes made before the time in its name,
+		// give or take a change racing the move.
+		name := info.Name()
+		if len(name) < len(journalTime) {
+			continue
+		}
+		moved, err := time.ParseInLocation(journalTime, name[:len(journalTime)], time.Local)
+		if err != nil || moved.Add(time.Minute).Before(since) {
+			continue
+		}
+		files = append(files, filepath.Join(l.dir, "_journals", name))
+	}
+	files = append(files, filepath.Join(l.dir, "_journal"))
+
+	var list []*JournalEntry
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		list = append(list, parseJournal(data, since)...)
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Time.Before(list[j].Time) })
+	return list, nil
+}
+
+// parseJournal returns the changes at or after since recorded
+// in data, the contents of a journal file.
+func parseJournal(data []byte, since time.Time) []*JournalEntry {
+	var list []*JournalEntry
+	for _, line := range strings.Split(string(data), "\n") {
+		f := strings.Split(line, "\t")
+		if len(f) < 4 {
+			continue
+		}
+		tm, err := time.Parse(time.RFC3339, f[0])
+		if err != nil || tm.Before(since) {
+			continue
+		}
+		e := &JournalEntry{Time: tm, Event: f[1], ID: f[2], Actor: f[3]}
+		if len(f) > 4 {
+			e.Detail = f[4]
+		}
+		list = append(list, e)
+	}
+	return list
+}
+
+// journal records a change in l's journal, made now.
+// The time is the actual time of the change, not the time
+// given to Write or Create, which may be a historical time,
+// as when importing tasks. Failures are reported to journalError.
+func (l *List) journal(event, id, detail string) {
+	clean := strings.NewReplacer("\t", " ", "\n", " ")
+	line := strings.Join([]string{
+		time.Now().Format(time.RFC3339),
+		event,
+		clean.Replace(id),
+		clean.Replace(Actor()),
+		clean.Replace(detail),
+	}, "\t") + "\n"
+	file := filepath.Join(l.dir, "_journal")
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		journalError(l.name, err)
+		return
+	}
+	// A single small write to a file opened for appending
+	// is not interleaved with writes by other programs.
+	_, err1 := f.Write([]byte(line))
+	info, _ := f.Stat()
+	err2 := f.Close()
+	if err1 != nil {
+		journalError(l.name, err1)
+	} else if err2 != nil {
+		journalError(l.name, err2)
+	} else if info != nil && info.Size() >= journalMax {
+		if err := l.rotateJournal(file); err != nil {
+			journalError(l.name, err)
+		}
+	}
+}
+
+// rotateJournal moves the journal file into l's _journals directory.
+func (l *List) rotateJournal(file string) error {
+	dir := filepath.Join(l.dir, "_journals")
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	base := time.Now().Format(journalTime)
+	name := base
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(dir, name)); os.IsNotExist(err) {
+			break
+		}
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	err := os.Rename(file, filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		err = nil // moved by another program
+	}
+	return err
+}
+
+// changeDetail returns the journal detail for a change
+// setting the headers hdr and adding comment.
+func changeDetail(hdr map[string]string, comment []byte) string {
+	var keys []string
+	for k := range hdr {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if len(comment) > 0 {
+		keys = append(keys, "comment")
+	}
+	return strings.Join(keys, " ")
+}
+
+// Actor returns the name recorded in the journal as making changes:
+// $TODO_ACTOR, or else the user name.
+func Actor() string {
+	if a := os.Getenv("TODO_ACTOR"); a != "" {
+		return a
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// ref returns the name of the task id in l, as in work/123.
+func (l *List) ref(id string) string {
+	return path.Join(l.name, id)
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Profiles.
+//
+// A profile, such as work or personal, is a separate todo root
+// with its own lists and configuration. The default root's
+// configuration names each profile's root with settings like
+//
+//	profile: work ~/work/todo
+//
+// A profile without such a setting has its root next to the
+// default one, named for the profile, as in $HOME/todo-work.
+// A relative directory is taken relative to the default root.
+
+var profile struct {
+	sync.Mutex
+	name  string
+	set   bool
+	roots map[string]string // profile root, by default root and profile
+}
+
+// SetProfile selects the named profile, overriding $TODO_PROFILE.
+// The empty name selects the default root.
+func SetProfile(name string) error {
+	if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+	profile.Lock()
+	profile.name = name
+	profile.set = true
+	profile.Unlock()
+	return nil
+}
+
+// Profile returns the name of the selected profile:
+// the one passed to SetProfile, or else $TODO_PROFILE.
+// It returns "" when the default root is in use.
+func Profile() string {
+	profile.Lock()
+	defer profile.Unlock()
+	if profile.set {
+		return profile.name
+	}
+	return os.Getenv("TODO_PROFILE")
+}
+
+// profileRoot returns the root directory of the named profile,
+// as configured in the default root base.
+func profileRoot(base, name string) string {
+	profile.Lock()
+	defer profile.Unlock()
+	key := base + "\x00" + name
+	if dir, ok := profile.roots[key]; ok {
+		return dir
+	}
+	dir := filepath.Join(filepath.Dir(base), filepath.Base(base)+"-"+name)
+	c := (&List{name: ".", dir: base}).Config()
+	for _, line := range c.Values("profile") {
+		f := strings.Fields(line)
+		if len(f) != 2 || f[0] != name {
+			continue
+		}
+		dir = f[1]
+		if strings.HasPrefix(dir, "~/") || strings.HasPrefix(dir, "~"+string(filepath.Separator)) {
+			dir = filepath.Join(HomeDir(), dir[2:])
+		} else if !filepath.IsAbs(dir) {
+			dir = filepath.Join(base, dir)
+		}
+	}
+	if profile.roots == nil {
+		profile.roots = make(map[string]string)
+	}
+	profile.roots[key] = dir
+	return dir
+}
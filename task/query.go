@@ -0,0 +1,411 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Query is a parsed query, as accepted by Search:
+// a sequence of terms, all of which a task must match.
+// Programs can build or combine queries by editing Terms,
+// as in appending the terms of one query to another.
+type Query struct {
+	Terms []Term
+	Exact bool // match text and headers byte for byte, as in SearchExact
+}
+
+// A matcher is a compiled query.
+type matcher struct {
+	ms       []func(t *Task, now time.Time, body func() []byte) bool
+	exact    bool
+	needDone bool // query can match done tasks
+}
+
+// A Term is a single query term.
+type Term struct {
+	Neg   bool   // negated, as in -due:*
+	Key   string // header key for key:value and key~regexp terms, "body" for body~regexp, or query function name
+	Op    string // ":" or "~" for header terms and ID sets; "()" for query functions; "" for the term all and text terms
+	Value string // text to find; header value, such as p0, *, =, <2019-07-01, or =x; regexp; or function argument
+}
+
+// String returns the term in query syntax.
+func (t Term) String() string {
+	s := t.Key + t.Op + t.Value
+	if t.Op == "()" {
+		s = t.Key
+		if t.Value != "" {
+			s += "(" + t.Value + ")"
+		}
+	}
+	if t.Op == "~" && len(t.Value) >= 2 && t.Value[0] == '"' && t.Value[len(t.Value)-1] == '"' {
+		// Quotes around a regexp are stripped, so protect real ones.
+		s = t.Key + t.Op + `"` + t.Value + `"`
+	}
+	if t.Neg {
+		s = "-" + s
+	}
+	return s
+}
+
+// String returns the query in query syntax,
+// such that ParseQuery(q.String()) is equivalent to q.
+func (q *Query) String() string {
+	var fields []string
+	for _, t := range q.Terms {
+		fields = append(fields, t.String())
+	}
+	return strings.Join(fields, " ")
+}
+
+// ParseQuery parses the query text q.
+// It does not expand aliases; see List.ParseQuery for that.
+func ParseQuery(q string) (*Query, error) {
+	return parseQueryTerms(q, nil, nil, false)
+}
+
+// ParseQuery parses the query text q, expanding the list's query aliases
+// and using the canonical names for aliased headers.
+func (l *List) ParseQuery(q string) (*Query, error) {
+	return parseQueryTerms(q, l.Aliases(), l.HeaderAliases(), false)
+}
+
+// parseQueryTerms parses the query q, expanding the query aliases
+// and renaming the header keys in headers to their canonical names.
+func parseQueryTerms(q string, aliases, headers map[string]string, exact bool) (*Query, error) {
+	fields, err := expandAliases(strings.Fields(q), aliases, nil)
+	if err != nil {
+		return nil, err
+	}
+	query := &Query{Exact: exact}
+	for _, f := range fields {
+		var t Term
+		if strings.HasPrefix(f, "-") {
+			t.Neg = true
+			f = f[1:]
+		}
+		if name, arg, ok := parseFuncTerm(f); ok {
+			t.Key, t.Op, t.Value = name, "()", arg
+		} else if i := strings.IndexAny(f, ":~"); i > 0 && f[i] == '~' {
+			t.Key, t.Op, t.Value = canonicalHeader(headers, f[:i]), "~", f[i+1:]
+			if len(t.Value) >= 2 && t.Value[0] == '"' && t.Value[len(t.Value)-1] == '"' {
+				t.Value = t.Value[1 : len(t.Value)-1]
+			}
+		} else if i := strings.Index(f, ":"); i >= 0 {
+			t.Key, t.Op, t.Value = canonicalHeader(headers, f[:i]), ":", f[i+1:]
+		} else {
+			t.Value = f
+		}
+		query.Terms = append(query.Terms, t)
+	}
+	// Check the query now, so that errors such as
+	// an invalid regular expression are reported here.
+	if _, err := query.compile(); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+// Match reports whether the task t matches the query.
+// A query that does not compile, such as one with an invalid
+// regular expression, matches no tasks.
+// Match compiles the query on each call; SearchQuery
+// compiles it once for all the tasks it considers.
+func (q *Query) Match(t *Task) bool {
+	m, err := q.compile()
+	if err != nil {
+		return false
+	}
+	return m.match(t, time.Now())
+}
+
+// match reports whether the task t matches the query at time now,
+// which dates in words, as in due:<tomorrow, are relative to.
+func (m *matcher) match(t *Task, now time.Time) bool {
+	// Fold the task text at most once, and only if needed.
+	var folded []byte
+	body := func() []byte {
+		if m.exact {
+			return t.body
+		}
+		if folded == nil {
+			folded = []byte(foldString(string(t.body)))
+		}
+		return folded
+	}
+	for _, f := range m.ms {
+		if !f(t, now, body) {
+			return false
+		}
+	}
+	return true
+}
+
+// compile compiles the query's current terms.
+func (q *Query) compile() (*matcher, error) {
+	// Unless matching exactly, fold case and normalize
+	// both the query text and the task text it is matched against.
+	fold := func(s string) string { return s }
+	if !q.Exact {
+		fold = foldString
+	}
+
+	c := &matcher{exact: q.Exact}
+	hideHidden := true
+	var stateTerms []string
+	for _, term := range q.Terms {
+		var m func(*Task, time.Time, func() []byte) bool
+		k, v := term.Key, term.Value
+		switch {
+		case term.Op == "" && v == "all":
+			m = func(t *Task, _ time.Time, _ func() []byte) bool {
+				return t.Header("todo") != "done" && (t.State() == "mute" || !t.Done())
+			}
+
+		case term.Op == ":" && k == "id", term.Op == "" && strings.Contains(v, ",") && parseIDSet(v) != nil:
+			// A task ID, range, or list of them, as in id:17,
+			// id:100-120, or id:3,7,19, names tasks whatever
+			// their state, done or deferred. A bare list, as in
+			// 3,7,19, is one too, but a bare range, as in 2018-2019,
+			// is text.
+			ranges := parseIDSet(v)
+			if ranges == nil {
+				return nil, fmt.Errorf("invalid query term %s: want id:N, id:N-M, or a comma-separated list of them", term)
+			}
+			c.needDone = true
+			hideHidden = false
+			m = func(t *Task, _ time.Time, _ func() []byte) bool { return inIDSet(ranges, t.id) }
+
+		case term.Op == "()":
+			fn := lookupQueryFunc(k)
+			if fn == nil {
+				return nil, fmt.Errorf("invalid query term %s: unknown query function %s", term, k)
+			}
+			match, err := fn(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid query term %s: %v", term, err)
+			}
+			m = func(t *Task, _ time.Time, _ func() []byte) bool { return match(t) }
+
+		case term.Op == "~":
+			// key~regexp or body~regexp.
+			if _, err := regexp.Compile(v); err != nil {
+				return nil, fmt.Errorf("invalid query term %s: %v", term, err)
+			}
+			flags := "(?m)"
+			if !q.Exact {
+				flags = "(?mi)"
+			}
+			re := regexp.MustCompile(flags + v)
+			if k == "todo" {
+				c.needDone = true
+				stateTerms = append(stateTerms, v)
+			}
+			if k == "body" {
+				m = func(t *Task, _ time.Time, _ func() []byte) bool { return re.Match(t.body) }
+			} else {
+				m = func(t *Task, _ time.Time, _ func() []byte) bool { return re.MatchString(t.hdr[k]) }
+			}
+
+		case term.Op == ":":
+			if k == "todo" {
+				// Any state may be a closed one, filed as done.
+				c.needDone = true
+				stateTerms = append(stateTerms, v)
+			}
+			// A date to compare against may be written
+			// as a word, as in due:<tomorrow, relative to
+			// the time of matching.
+			date := func(now time.Time) string { return v[1:] }
+			if dateHeaders[k] && v != "" && strings.Contains("<>=", v[:1]) {
+				date = func(now time.Time) string { return queryDate(v[1:], now) }
+			}
+			if v == "*" {
+				m = func(t *Task, _ time.Time, _ func() []byte) bool { return t.hdr[k] != "" }
+			} else if v == "=" {
+				m = func(t *Task, _ time.Time, _ func() []byte) bool { return t.hdr[k] == "" }
+			} else if strings.HasPrefix(v, "<") {
+				m = func(t *Task, now time.Time, _ func() []byte) bool { return t.hdr[k] != "" && t.hdr[k] < date(now) }
+			} else if strings.HasPrefix(v, ">") {
+				m = func(t *Task, now time.Time, _ func() []byte) bool { return t.hdr[k] != "" && t.hdr[k] > date(now) }
+			} else if strings.HasPrefix(v, "=") {
+				m = func(t *Task, now time.Time, _ func() []byte) bool { return t.hdr[k] == date(now) }
+			} else {
+				v := fold(v)
+				m = func(t *Task, _ time.Time, _ func() []byte) bool { return strings.Contains(fold(t.hdr[k]), v) }
+			}
+
+		default:
+			b := []byte(fold(v))
+			m = func(t *Task, _ time.Time, body func() []byte) bool { return bytes.Contains(body(), b) }
+		}
+		if term.Neg {
+			m1 := m
+			m = func(t *Task, now time.Time, body func() []byte) bool { return !m1(t, now, body) }
+		}
+		c.ms = append(c.ms, m)
+	}
+
+	if hideHidden {
+		// Leave out deferred tasks and tasks in hidden states,
+		// unless the query asks about their state.
+		c.ms = append(c.ms, func(t *Task, now time.Time, _ func() []byte) bool {
+			s := t.State()
+			if !t.Deferred(now) && !t.states.Hidden(s) {
+				return true
+			}
+			for _, v := range stateTerms {
+				if strings.Contains(strings.ToLower(v), s) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+	return c, nil
+}
+
+// An idRange is an inclusive range of numeric task IDs.
+type idRange struct {
+	lo, hi int
+}
+
+// parseIDSet parses the set of numeric task IDs s: an ID, as in 17,
+// a range, as in 100-120, or a comma-separated list of IDs and ranges,
+// as in 3,7,19 or 3,10-12. It returns nil if s is not an ID set.
+func parseIDSet(s string) []idRange {
+	var ranges []idRange
+	for _, f := range strings.Split(s, ",") {
+		lo, hi := f, f
+		if i := strings.Index(f, "-"); i >= 0 {
+			lo, hi = f[:i], f[i+1:]
+		}
+		r := idRange{}
+		var ok1, ok2 bool
+		r.lo, ok1 = parseID(lo)
+		r.hi, ok2 = parseID(hi)
+		if !ok1 || !ok2 || r.hi < r.lo {
+			return nil
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// parseID parses the numeric task ID s.
+func parseID(s string) (int, bool) {
+	if s == "" || strings.Trim(s, "0123456789") != "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s)
+	return n, err == nil
+}
+
+// inIDSet reports whether the task ID id is in one of the ranges.
+func inIDSet(ranges []idRange, id string) bool {
+	n, ok := parseID(id)
+	if !ok {
+		return false
+	}
+	for _, r := range ranges {
+		if r.lo <= n && n <= r.hi {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchQuery returns the tasks in l matching the parsed query q,
+// giving up once ctx is done, as SearchContext does.
+func (l *List) SearchQuery(ctx context.Context, q *Query) ([]*Task, error) {
+	m, err := q.compile()
+	if err != nil {
+		return nil, err
+	}
+	all, err := l.AllContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var done []*Task
+	if m.needDone {
+		done, err = l.DoneContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	var tasks []*Task
+	for _, list := range [][]*Task{all, done} {
+		for _, t := range list {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if m.match(t, now) {
+				tasks = append(tasks, t)
+			}
+		}
+	}
+	return tasks, nil
+}
+
+// A QueryFunc returns the predicate for a query term naming it,
+// such as mine or stale(30d). The argument is the text between
+// the parentheses, or "" when the term is just the name.
+type QueryFunc func(arg string) (match func(*Task) bool, err error)
+
+var queryFuncs struct {
+	sync.Mutex
+	m map[string]QueryFunc
+}
+
+// RegisterQueryFunc registers fn as the query function name,
+// so that the query term name, or name(arg), matches the tasks
+// for which fn's predicate returns true, as in mine, stale(30d),
+// or -blocked. A query function hides the plain-text search
+// for its name, and a query alias of the same name hides it.
+// RegisterQueryFunc panics if name is not a valid name or is
+// already registered.
+func RegisterQueryFunc(name string, fn QueryFunc) {
+	if !isAliasName(name) || strings.ContainsAny(name, "()~") || fn == nil {
+		panic("task: invalid query function " + name)
+	}
+	queryFuncs.Lock()
+	defer queryFuncs.Unlock()
+	if queryFuncs.m[name] != nil {
+		panic("task: query function " + name + " registered twice")
+	}
+	if queryFuncs.m == nil {
+		queryFuncs.m = make(map[string]QueryFunc)
+	}
+	queryFuncs.m[name] = fn
+}
+
+// lookupQueryFunc returns the query function name, or nil if there is none.
+func lookupQueryFunc(name string) QueryFunc {
+	queryFuncs.Lock()
+	defer queryFuncs.Unlock()
+	return queryFuncs.m[name]
+}
+
+// parseFuncTerm parses f as a query function term, name or name(arg),
+// reporting whether f names a registered query function.
+func parseFuncTerm(f string) (name, arg string, ok bool) {
+	if lookupQueryFunc(f) != nil {
+		return f, "", true
+	}
+	if i := strings.Index(f, "("); i > 0 && strings.HasSuffix(f, ")") && lookupQueryFunc(f[:i]) != nil {
+		return f[:i], f[i+1 : len(f)-1], true
+	}
+	return "", "", false
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rank sorts tasks by their relevance to the query q, most relevant first.
+// Each plain text term in q scores 4 for a task with the term in its title,
+// 2 for a task with the term in another header, and 1 for a task with
+// the term only in its text. Tasks updated in the week before now
+// score 1 more, and those updated in the month before now, ½ more.
+// Tasks with equal scores keep their order.
+func Rank(tasks []*Task, q string, now time.Time) {
+	terms := TextTerms(q)
+	week := now.Add(-7 * 24 * time.Hour).Format("2006-01-02 15:04:05")
+	month := now.Add(-30 * 24 * time.Hour).Format("2006-01-02 15:04:05")
+
+	score := make(map[*Task]float64)
+	for _, t := range tasks {
+		s := 0.0
+		if len(terms) > 0 {
+			title := foldString(t.Title())
+			body := foldString(string(t.body))
+			for _, term := range terms {
+				switch {
+				case strings.Contains(title, term):
+					s += 4
+				case t.headerContains(term):
+					s += 2
+				case strings.Contains(body, term):
+					s += 1
+				}
+			}
+		}
+		switch mtime := t.Header("mtime"); {
+		case mtime >= week:
+			s += 1
+		case mtime >= month:
+			s += 0.5
+		}
+		score[t] = s
+	}
+	sort.SliceStable(tasks, func(i, j int) bool { return score[tasks[i]] > score[tasks[j]] })
+}
+
+// TextTerms returns the plain text terms in the query q,
+// those matched against task text rather than headers,
+// with case folded and Unicode normalized for use with MatchTerms.
+func TextTerms(q string) []string {
+	var terms []string
+	for _, f := range strings.Fields(q) {
+		if f != "all" && !strings.HasPrefix(f, "-") && !strings.ContainsAny(f, ":~") {
+			terms = append(terms, foldString(f))
+		}
+	}
+	return terms
+}
+
+// MatchTerms returns the byte ranges in s matching any of terms,
+// as returned by TextTerms, as pairs of start and end offsets
+// in increasing order, or nil if there are no matches.
+func MatchTerms(s string, terms []string) [][2]int {
+	// Fold s one rune at a time to keep track of offsets in s.
+	var folded strings.Builder
+	var offsets []int
+	for i, r := range s {
+		f := foldString(string(r))
+		for j := 0; j < len(f); j++ {
+			offsets = append(offsets, i)
+		}
+		folded.WriteString(f)
+	}
+	offsets = append(offsets, len(s))
+	fs := folded.String()
+
+	var ranges [][2]int
+	for _, term := range terms {
+		for i := 0; term != "" && i <= len(fs)-len(term); {
+			j := strings.Index(fs[i:], term)
+			if j < 0 {
+				break
+			}
+			start, end := i+j, i+j+len(term)
+			ranges = append(ranges, [2]int{offsets[start], offsets[end]})
+			i = end
+		}
+	}
+	if len(ranges) == 0 {
+		return nil
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	out := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &out[len(out)-1]
+		if r[0] <= last[1] {
+			if r[1] > last[1] {
+				last[1] = r[1]
+			}
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// headerContains reports whether a header other than the title
+// contains term, which has been folded by foldString.
+func (t *Task) headerContains(term string) bool {
+	for k, v := range t.hdr {
+		if k != "title" && strings.Contains(foldString(v), term) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"path"
+	"strconv"
+)
+
+// Read-only lists.
+//
+// A list's configuration can mark it read-only with the setting
+//
+//	readonly: true
+//
+// as for a list mirrored from elsewhere or shared read-only by a team.
+// The setting applies to the list's sublists too. Write, Create, and Move
+// refuse to change a read-only list, returning a *ReadOnlyError,
+// which matches ErrReadOnly, unless the program maintaining the list calls AllowWrites.
+
+// A ReadOnlyError reports an attempt to change a read-only list.
+type ReadOnlyError struct {
+	List string // name of the list
+}
+
+func (e *ReadOnlyError) Error() string {
+	return "list " + e.List + " is read-only"
+}
+
+// ReadOnly reports whether the list, or a list containing it,
+// is marked read-only by its configuration.
+func (l *List) ReadOnly() bool {
+	for name := l.name; ; name = path.Dir(name) {
+		if ro, _ := strconv.ParseBool(OpenList(name).Config().Get("readonly")); ro {
+			return true
+		}
+		if name == "." || name == "/" || name == "" {
+			return false
+		}
+	}
+}
+
+// AllowWrites lets l be changed even if it is read-only,
+// for use by programs that maintain read-only lists, such as mirrors.
+func (l *List) AllowWrites() {
+	l.mu.Lock()
+	l.allowWrites = true
+	l.mu.Unlock()
+}
+
+// checkWritable returns a *ReadOnlyError if l cannot be changed.
+func (l *List) checkWritable() error {
+	l.mu.Lock()
+	allow := l.allowWrites
+	l.mu.Unlock()
+	if !allow && l.ReadOnly() {
+		return &ReadOnlyError{List: l.name}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Remind headers.
+//
+// A task's remind header says when to remind people about it.
+// It is either a lead time before the task is due, such as 30m, 2d,
+// or 1w, or a time of its own, such as 2019-07-01 09:00 or 2019-07-01.
+// When written, a time relative to the time of writing, such as +2d
+// or +3h, is rewritten as an absolute time, 2006-01-02 15:04,
+// and a date in words, such as tomorrow 09:00, as in NormalizeDate.
+
+// normalizeRemind returns the remind header value s
+// with relative times and dates in words rewritten.
+// It returns s unchanged if s is a lead time or not a time at all.
+func normalizeRemind(s string, now time.Time) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "+") {
+		if d, err := ParseLead(s[1:]); err == nil {
+			return now.Local().Add(d).Format("2006-01-02 15:04")
+		}
+		return s
+	}
+	if _, err := ParseLead(s); err == nil {
+		return s
+	}
+	return NormalizeDate(s, now)
+}
+
+// ParseLead parses a lead time, a duration as accepted by
+// time.ParseDuration or a number of days or weeks, such as 2d or 1w.
+func ParseLead(s string) (time.Duration, error) {
+	day := 24 * time.Hour
+	for _, u := range []struct {
+		suffix string
+		d      time.Duration
+	}{{"d", day}, {"w", 7 * day}} {
+		if strings.HasSuffix(s, u.suffix) {
+			n, err := strconv.Atoi(strings.TrimSuffix(s, u.suffix))
+			if err != nil {
+				return 0, fmt.Errorf("invalid lead time %q", s)
+			}
+			return time.Duration(n) * u.d, nil
+		}
+	}
+	return time.ParseDuration(s)
+}
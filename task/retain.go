@@ -0,0 +1,131 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Done-task retention.
+//
+// A list's configuration can move done tasks out of the way
+// once they have gone unchanged long enough, using the setting
+//
+//	archive: age
+//
+// as in "archive: 180d", which applies to the list's sublists too.
+// ArchiveDone, run by todo gc, moves such tasks into the _done directory
+// in the list's directory, in a subdirectory named for the month of the
+// task's last update, as in _done/2019-06/123.done. Archived tasks
+// are not read by All, Done, or Search; SearchArchived searches them.
+// An archived task's ID is qualified by its place in the archive,
+// as in _done/2019-06/123, so that it cannot be confused with
+// a newer task reusing the ID, and Read accepts such IDs.
+
+// ArchiveDone moves the done tasks in l last updated before the given time
+// into l's _done directory and returns the number moved.
+func (l *List) ArchiveDone(before time.Time) (int, error) {
+	if err := l.checkWritable(); err != nil {
+		return 0, err
+	}
+	done, err := l.Done()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, t := range done {
+		mtime, err := time.ParseInLocation("2006-01-02 15:04:05", t.mtime, time.Local)
+		if err != nil || !mtime.Before(before) || !strings.HasSuffix(t.file, ".done") {
+			continue
+		}
+		dir := filepath.Join(l.dir, "_done", mtime.Format("2006-01"))
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			return n, err
+		}
+		// An ID can be reused after its task is archived,
+		// so the archive may already have one by that name.
+		name := t.id
+		for i := 2; ; i++ {
+			if _, err := os.Stat(filepath.Join(dir, name+".done")); os.IsNotExist(err) {
+				break
+			}
+			name = fmt.Sprintf("%s-%d", t.id, i)
+		}
+		if err := os.Rename(t.file, filepath.Join(dir, name+".done")); err != nil {
+			return n, err
+		}
+		if _, err := os.Stat(l.attachDir(t.id)); err == nil {
+			if err := os.MkdirAll(filepath.Join(dir, "_attach"), 0777); err != nil {
+				return n, err
+			}
+			if err := os.Rename(l.attachDir(t.id), filepath.Join(dir, "_attach", name)); err != nil {
+				return n, err
+			}
+		}
+		l.mu.Lock()
+		delete(l.cache, t.id)
+		l.mu.Unlock()
+		l.journal("archive", t.id, "to _done/"+mtime.Format("2006-01")+"/"+name)
+		n++
+	}
+	return n, nil
+}
+
+// Archived returns the tasks moved into l's _done directory by ArchiveDone,
+// with IDs qualified by their archive directories.
+func (l *List) Archived() ([]*Task, error) {
+	files, err := filepath.Glob(filepath.Join(l.dir, "_done", "*", "*.done"))
+	if err != nil {
+		return nil, err
+	}
+	aliases := l.HeaderAliases()
+	var tasks []*Task
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(l.dir, file)
+		if err != nil {
+			continue
+		}
+		t, err := parseTask(strings.TrimSuffix(filepath.ToSlash(rel), ".done"), file, data, aliases)
+		if err != nil {
+			continue
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+// SearchArchived returns the archived tasks in l matching the query q,
+// matching text and headers exactly if exact is set, as in SearchExact.
+func (l *List) SearchArchived(q string, exact bool) ([]*Task, error) {
+	query, err := parseQueryTerms(q, l.Aliases(), l.HeaderAliases(), exact)
+	if err != nil {
+		return nil, err
+	}
+	m, err := query.compile()
+	if err != nil {
+		return nil, err
+	}
+	archived, err := l.Archived()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var tasks []*Task
+	for _, t := range archived {
+		if m.match(t, now) {
+			tasks = append(tasks, t)
+		}
+	}
+	return tasks, nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Update size limits.
+//
+// Large comments, such as the diffs recorded by vcs-todo, make
+// task files slow to display and search. A list's configuration
+// can limit the size of a comment with the setting
+//
+//	maxupdate: size
+//
+// as in "maxupdate: 64K". Write and Create store a longer comment
+// as an attachment, recording in the update only the comment's first
+// line and a note naming the attachment file, relative to the list's
+// directory, as in _attach/123/update-20190701-120000.txt, so that the
+// note stays right when the list is moved. The setting applies to
+// the list's sublists too. Slim applies the same policy to the updates
+// already in a task.
+
+// MaxUpdate returns the largest comment size in bytes allowed by the
+// maxupdate setting of the list or a list containing it, or 0 for no limit.
+func (l *List) MaxUpdate() int {
+	n, err := ParseSize(l.Setting("maxupdate"))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// ParseSize parses a size in bytes, such as 4096,
+// optionally followed by K or M for kilobytes or megabytes.
+func ParseSize(s string) (int, error) {
+	orig := s
+	s = strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	unit := 1
+	switch {
+	case strings.HasSuffix(s, "K"):
+		unit = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		unit = 1 << 20
+	}
+	if unit != 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", orig)
+	}
+	return n * unit, nil
+}
+
+// externalize stores comment, from the update of the task id
+// made at time ts, as an attachment, returning the comment to
+// record in the update instead.
+func (l *List) externalize(id string, ts time.Time, comment []byte) ([]byte, error) {
+	base := "update-" + ts.Local().Format("20060102-150405")
+	name := base + ".txt"
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(l.attachDir(id), name)); os.IsNotExist(err) {
+			break
+		}
+		name = fmt.Sprintf("%s-%d.txt", base, i)
+	}
+	file, err := l.attach(id, name, comment)
+	if err != nil {
+		return nil, err
+	}
+	if rel, err := filepath.Rel(l.dir, file); err == nil {
+		file = filepath.ToSlash(rel)
+	}
+	first := comment
+	if i := bytes.IndexByte(first, '\n'); i >= 0 {
+		first = first[:i]
+	}
+	if len(first) > 200 {
+		first = append(first[:200:200], "..."...)
+	}
+	return []byte(fmt.Sprintf("%s\nComment (%d bytes) attached as %s\n", first, len(comment), file)), nil
+}
+
+// LargeComments returns the number of t's updates with comments
+// larger than max bytes: the comments Slim would move.
+func (t *Task) LargeComments(max int) int {
+	n := 0
+	for _, u := range t.rawUpdates() {
+		if isMarker(bytes.SplitN(u, nl, 2)[0]) {
+			if _, comment := splitUpdate(u); len(bytes.TrimRight(comment, "\n")) > max {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Slim moves the comments larger than max bytes in the task's
+// existing updates into attachments, as Write does for new ones,
+// and returns the rewritten task and the number of comments moved.
+// The task file is replaced atomically, as by rewrite.
+func (l *List) Slim(id string, max int) (*Task, int, error) {
+	if err := l.checkWritable(); err != nil {
+		return nil, 0, err
+	}
+	t, err := l.Read(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	if t.LargeComments(max) == 0 {
+		return t, 0, nil
+	}
+
+	// Attach each large comment once, before rewriting, so that
+	// rewrite's retries do not attach the same comment again.
+	// A comment appended by another program in the meantime
+	// is attached when the rewrite finds it.
+	stubs := make(map[string][]byte)
+	stub := func(head, comment []byte) ([]byte, error) {
+		key := string(head) + string(comment)
+		if s, ok := stubs[key]; ok {
+			return s, nil
+		}
+		marker := string(bytes.SplitN(head, nl, 2)[0])
+		ts, err := time.ParseInLocation("2006-01-02 15:04:05", strings.TrimSpace(marker[len(emSpace):len(marker)-len(spaceEm)]), time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("task %s: %w update marker %q", l.ref(id), ErrMalformed, marker)
+		}
+		s, err := l.externalize(id, ts, comment)
+		if err != nil {
+			return nil, err
+		}
+		stubs[key] = s
+		return s, nil
+	}
+	for _, u := range t.rawUpdates() {
+		if !isMarker(bytes.SplitN(u, nl, 2)[0]) {
+			continue
+		}
+		head, comment := splitUpdate(u)
+		if comment = bytes.TrimRight(comment, "\n"); len(comment) > max {
+			if _, err := stub(head, comment); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+
+	n := 0
+	t, err = l.rewrite(id, func(t *Task, updates [][]byte, marked []int) error {
+		n = 0
+		for _, i := range marked {
+			head, comment := splitUpdate(updates[i])
+			comment = bytes.TrimRight(comment, "\n")
+			if len(comment) <= max {
+				continue
+			}
+			s, err := stub(head, comment)
+			if err != nil {
+				return err
+			}
+			updates[i] = append(append(head, s...), '\n')
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	l.journal("slim", id, fmt.Sprintf("%d comments attached", n))
+	return t, n, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Slug IDs.
+//
+// A list whose configuration (or that of a list containing it)
+// has the setting "ids: slug" gives new tasks IDs derived from their
+// titles, such as fix-parser-panic for "Fix parser panic", instead of
+// numbers, so that references to tasks in commit messages and chat
+// mean something. If the ID is taken, a suffix makes it unique,
+// as in fix-parser-panic-2. A title without letters or digits,
+// or with only digits, gets the usual numeric ID.
+
+// maxSlug is the maximum length of a slug ID, before any suffix.
+const maxSlug = 40
+
+// reservedIDs are names that mean something other than a task
+// to todo and its acme windows, so slug IDs avoid them.
+// Programs can reserve more names with ReserveIDs.
+var reservedIDs = map[string]bool{
+	"agenda":     true,
+	"all":        true,
+	"board":      true,
+	"bulkedit":   true,
+	"dashboard":  true,
+	"last":       true,
+	"milestones": true,
+	"new":        true,
+	"search":     true,
+}
+
+var reservedFuncs struct {
+	sync.Mutex
+	list []func(id string) bool
+}
+
+// ReserveIDs arranges for slug IDs to avoid the names for which
+// reserved returns true, such as a program's command names,
+// which would otherwise be taken for the task.
+func ReserveIDs(reserved func(id string) bool) {
+	reservedFuncs.Lock()
+	defer reservedFuncs.Unlock()
+	reservedFuncs.list = append(reservedFuncs.list, reserved)
+}
+
+// isReservedID reports whether id is reserved.
+func isReservedID(id string) bool {
+	if reservedIDs[id] {
+		return true
+	}
+	reservedFuncs.Lock()
+	defer reservedFuncs.Unlock()
+	for _, f := range reservedFuncs.list {
+		if f(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// slug returns the slug ID for a task with the given title,
+// or "" if the title should get a numeric ID instead.
+// Letters are lowercased and stripped of accents,
+// and runs of other characters become single dashes.
+func slug(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range norm.NFD.String(strings.ToLower(title)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// accent
+		case 'a' <= r && r <= 'z' || '0' <= r && r <= '9':
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		default:
+			dash = true
+		}
+	}
+	s := b.String()
+	if len(s) > maxSlug {
+		s = s[:maxSlug]
+		if i := strings.LastIndex(s, "-"); i > 0 {
+			s = s[:i]
+		}
+	}
+	if strings.Trim(s, "0123456789") == "" {
+		return ""
+	}
+	return s
+}
+
+// reserveSlug is like reserve but creates the file for a new task
+// with the ID s or, if that is taken, s-2, s-3, and so on.
+// An ID is taken if it is reserved or names a task or sublist.
+func (l *List) reserveSlug(s string) (string, string, error) {
+	// l is locked
+
+	for n := 1; ; n++ {
+		id := s
+		if n > 1 {
+			id = fmt.Sprintf("%s-%d", s, n)
+		}
+		if isReservedID(id) || l.cache[id] != nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(l.dir, id+".done")); err == nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(l.dir, id)); err == nil {
+			continue
+		}
+		file := filepath.Join(l.dir, id+".todo")
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", "", err
+		}
+		f.Close()
+		return id, file, nil
+	}
+}
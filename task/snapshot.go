@@ -0,0 +1,65 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Snapshot calls fn for each file belonging to the list: its task files
+// and the files in its _ directories, such as _config, _attach, and _trash,
+// but not the files of its sublists. The name passed to fn is the file's
+// slash-separated path relative to the root, as in work/123.todo.
+// Temporary files left by interrupted writes are skipped.
+//
+// Snapshot holds the list's lock while it reads the files, so that
+// changes made through l wait until it is done. Changes made by other
+// programs replace or append to whole files, so each file read is
+// consistent on its own.
+func (l *List) Snapshot(fn func(name string, info os.FileInfo, data []byte) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	root := Root()
+	return filepath.Walk(l.dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil // removed during the walk
+			}
+			return err
+		}
+		base := info.Name()
+		if file != l.dir && strings.HasPrefix(base, ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			if filepath.Dir(file) == l.dir && !strings.HasPrefix(base, "_") {
+				return filepath.SkipDir // a sublist
+			}
+			return nil
+		}
+		if strings.HasSuffix(base, ".tmp") || strings.HasSuffix(base, ".rewrite") || strings.HasSuffix(base, ".old") {
+			return nil
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel), info, data)
+	})
+}
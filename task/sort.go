@@ -0,0 +1,92 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"sort"
+	"strings"
+)
+
+// Compare returns a function comparing tasks by the given sort key,
+// which has the same meaning as the argument to the acme Sort command.
+// The key "id" orders tasks by ID, numerically, with non-numeric IDs last.
+// The key "title" (or "") orders tasks by title.
+// Any other key orders tasks by the value of that header.
+// A leading "-", as in "-priority", reverses the order.
+// A comma-separated list of keys, as in "priority,due,-mtime",
+// orders tasks by the first key, breaking ties using the next, and so on.
+// Remaining ties are broken by title and then ID.
+//
+// The returned function returns a negative number if x sorts before y,
+// a positive number if x sorts after y, and zero if they are equal.
+func Compare(key string) func(x, y *Task) int {
+	var cmps []func(x, y *Task) int
+	for _, k := range strings.Split(key, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			cmps = append(cmps, compare1(k))
+		}
+	}
+	cmps = append(cmps, compare1("title"), compare1("id"))
+	return func(x, y *Task) int {
+		for _, cmp := range cmps {
+			if c := cmp(x, y); c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+}
+
+// compare1 returns a function comparing tasks by a single sort key.
+func compare1(key string) func(x, y *Task) int {
+	rev := false
+	if strings.HasPrefix(key, "-") {
+		rev = true
+		key = key[1:]
+	}
+	var cmp func(x, y *Task) int
+	switch key {
+	case "id":
+		cmp = func(x, y *Task) int {
+			nx := idNumber(x.ID())
+			ny := idNumber(y.ID())
+			switch {
+			case nx < ny:
+				return -1
+			case nx > ny:
+				return +1
+			}
+			return strings.Compare(x.ID(), y.ID())
+		}
+	case "title", "":
+		cmp = func(x, y *Task) int { return strings.Compare(x.Title(), y.Title()) }
+	default:
+		key = strings.ToLower(key)
+		cmp = func(x, y *Task) int { return strings.Compare(x.Header(key), y.Header(key)) }
+	}
+	if rev {
+		return func(x, y *Task) int { return cmp(y, x) }
+	}
+	return cmp
+}
+
+// Sort sorts the tasks by the given sort key, as described by Compare.
+func Sort(tasks []*Task, key string) {
+	cmp := Compare(key)
+	sort.SliceStable(tasks, func(i, j int) bool { return cmp(tasks[i], tasks[j]) < 0 })
+}
+
+// idNumber returns the numeric value of the task ID id,
+// or a large number if id is not numeric.
+func idNumber(id string) int {
+	n := 0
+	for i := 0; i < len(id); i++ {
+		if id[i] < '0' || '9' < id[i] {
+			return 999999999
+		}
+		n = n*10 + int(id[i]-'0')
+	}
+	return n
+}
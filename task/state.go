@@ -0,0 +1,84 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The _state file in a list's directory records information for
+// programs that update the list, such as the last commit processed
+// by vcs-todo. Like _config, it holds "key: value" lines,
+// but it is written by programs, not people.
+
+// State returns the value recorded for key in the list's state,
+// or "" if there is none.
+func (l *List) State(key string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lines, _ := l.readState()
+	for _, line := range lines {
+		if k, v := splitState(line); k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+// SetState records value for key in the list's state.
+// An empty value removes the key.
+func (l *List) SetState(key, value string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lines, err := l.readState()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var out []string
+	for _, line := range lines {
+		if k, _ := splitState(line); k != key {
+			out = append(out, line)
+		}
+	}
+	if value != "" {
+		out = append(out, key+": "+value)
+	}
+
+	file := filepath.Join(l.dir, "_state")
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strings.Join(out, "\n")+"\n"), 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// readState returns the non-blank lines of the list's _state file.
+func (l *List) readState() ([]string, error) {
+	// l is locked
+	data, err := ioutil.ReadFile(filepath.Join(l.dir, "_state"))
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, err
+}
+
+// splitState splits a state line into key and value.
+// The key is everything before the last ": ",
+// so that keys can contain colons.
+func splitState(line string) (key, value string) {
+	i := strings.LastIndex(line, ": ")
+	if i < 0 {
+		return strings.TrimSpace(line), ""
+	}
+	return line[:i], strings.TrimSpace(line[i+2:])
+}
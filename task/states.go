@@ -0,0 +1,157 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Task states.
+//
+// A task's todo header holds its state, named by the header's first word,
+// as in "snooze 2019-07-01". A task without the header is open.
+// The built-in states are done and mute, which close the task,
+// and snooze and waiting, which defer it. A list's configuration
+// (or that of a list containing it) can define a workflow with
+// settings such as
+//
+//	states: triage accepted doing review done
+//	closed-states: wontfix
+//	hidden-states: someday
+//	state-color: review yellow
+//
+// The states setting lists the list's states in workflow order;
+// once it is set, writes using any other state, apart from the
+// built-in ones, fail. Closed states count as done: tasks in them
+// are left out of open queries and filed as done. Hidden states are
+// left out of queries, like deferred ones, unless the query asks about
+// the state, as in todo:someday. A state-color setting gives the color
+// for showing tasks in a state: red, green, yellow, blue, magenta,
+// cyan, bold, or dim.
+
+// builtinStates lists the states every list allows.
+var builtinStates = []string{"done", "mute", "snooze", "waiting"}
+
+// States describes a list's task states, as configured by its settings.
+// A nil *States describes the built-in states alone.
+type States struct {
+	Order  []string          // states in workflow order, from the states setting
+	closed map[string]bool   // configured closed states
+	hidden map[string]bool   // configured hidden states
+	colors map[string]string // state colors
+}
+
+// States returns the list's task states.
+func (l *List) States() *States {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.checkConfig()
+	return l.states()
+}
+
+func (l *List) states() *States {
+	// l is locked
+	if l.stateCfg != nil {
+		return l.stateCfg
+	}
+	s := &States{
+		Order:  strings.Fields(l.Setting("states")),
+		closed: make(map[string]bool),
+		hidden: make(map[string]bool),
+		colors: make(map[string]string),
+	}
+	for _, name := range strings.Fields(l.Setting("closed-states")) {
+		s.closed[name] = true
+	}
+	for _, name := range strings.Fields(l.Setting("hidden-states")) {
+		s.hidden[name] = true
+	}
+	for name := l.name; ; name = path.Dir(name) {
+		for _, line := range OpenList(name).Config().Values("state-color") {
+			if f := strings.Fields(line); len(f) == 2 && s.colors[f[0]] == "" {
+				s.colors[f[0]] = f[1]
+			}
+		}
+		if name == "." || name == "/" || name == "" {
+			break
+		}
+	}
+	l.stateCfg = s
+	return s
+}
+
+// State returns the name of the task's state, the first word of its
+// todo header, as in snooze for "snooze 2019-07-01", or "" if it is open.
+func (t *Task) State() string {
+	return stateName(t.Header("todo"))
+}
+
+// stateName returns the name of the state in the todo header value v.
+func stateName(v string) string {
+	f := strings.Fields(v)
+	if len(f) == 0 {
+		return ""
+	}
+	return f[0]
+}
+
+// Closed reports whether state is a closed state,
+// one that counts as done.
+func (s *States) Closed(state string) bool {
+	if state == "done" || state == "mute" {
+		return true
+	}
+	return s != nil && s.closed[state]
+}
+
+// Hidden reports whether state is a configured hidden state.
+func (s *States) Hidden(state string) bool {
+	return s != nil && s.hidden[state]
+}
+
+// Color returns the configured color for state, or "" if there is none.
+func (s *States) Color(state string) string {
+	if s == nil {
+		return ""
+	}
+	return s.colors[state]
+}
+
+// check returns an error if the todo header value v
+// sets a state the list does not allow.
+func (s *States) check(list, v string) error {
+	state := stateName(v)
+	if s == nil || len(s.Order) == 0 || state == "" {
+		return nil
+	}
+	for _, name := range builtinStates {
+		if state == name {
+			return nil
+		}
+	}
+	for _, name := range s.Order {
+		if state == name {
+			return nil
+		}
+	}
+	if s.closed[state] || s.hidden[state] {
+		return nil
+	}
+	return fmt.Errorf("invalid state %q: list %s allows %s", state, list, strings.Join(s.Order, ", "))
+}
+
+// checkState returns an error if hdr sets a state l does not allow.
+func (l *List) checkState(hdr map[string]string) error {
+	v, ok := hdr["todo"]
+	if !ok {
+		return nil
+	}
+	if err := checkDeferred(v); err != nil {
+		return err
+	}
+	return l.States().check(l.name, v)
+}
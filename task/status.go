@@ -0,0 +1,77 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import "time"
+
+// Status timestamps.
+//
+// Writes record when a task's status changes, so that reports can
+// measure how long tasks take. The first update after a task is created
+// sets its began header to the time of the update, unless the update
+// closes the task, and marking the task done, or moving it to another
+// of its list's closed states, sets its closed header, which is cleared
+// if the task is reopened. Together with the task's creation time,
+// these give its lead time, from creation to closing, and its cycle time,
+// from the first update to closing. (The started header is taken:
+// it belongs to time tracking, which clears it whenever work stops.)
+// Tasks updated before began was recorded are left without it,
+// and reports use their first update instead.
+// An update that sets began or closed itself is left alone,
+// as are writes by programs copying history from elsewhere,
+// such as importers, which call Importing so that their
+// writes do not count as starting work.
+
+// statusTime is the layout of the began and closed headers.
+const statusTime = "2006-01-02 15:04:05"
+
+// Importing marks writes through l as copying history from elsewhere,
+// as importers and mirrors do, so that they do not set began headers.
+func (l *List) Importing() {
+	l.mu.Lock()
+	l.importing = true
+	l.mu.Unlock()
+}
+
+// statusHeaders returns the headers to write for an update to t
+// setting hdr at now: hdr plus any began and closed changes.
+// If importing is set, the update does not set began.
+// It does not modify hdr.
+func statusHeaders(t *Task, now time.Time, hdr map[string]string, importing bool) map[string]string {
+	was := t.Header("todo")
+	state := was
+	if v, ok := hdr["todo"]; ok {
+		state = v
+	} else if t.Done() && was != "mute" {
+		// write clears a done task's state, reopening it.
+		state = ""
+	}
+
+	// Done and the list's other closed states close a task;
+	// muting it only silences it.
+	closes := func(v string) bool {
+		s := stateName(v)
+		return s != "mute" && t.states.Closed(s)
+	}
+
+	add := make(map[string]string)
+	if _, ok := hdr["began"]; !ok && t.hdr["began"] == "" && !importing && !closes(state) && len(t.Updates()) == 1 {
+		add["began"] = now.Local().Format(statusTime)
+	}
+	if _, ok := hdr["closed"]; !ok {
+		if closes(state) && !closes(was) {
+			add["closed"] = now.Local().Format(statusTime)
+		} else if !closes(state) && t.hdr["closed"] != "" {
+			add["closed"] = ""
+		}
+	}
+	if len(add) == 0 {
+		return hdr
+	}
+	for k, v := range hdr {
+		add[k] = v
+	}
+	return add
+}
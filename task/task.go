@@ -6,16 +6,20 @@ package task
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 type Task struct {
@@ -26,6 +30,8 @@ type Task struct {
 	_id   []string
 	ctime string
 	mtime string
+
+	states *States // the list's states, for Done
 }
 
 func (t *Task) ID() string    { return t.id }
@@ -44,6 +50,20 @@ func (t *Task) Header(key string) string {
 
 func (t *Task) EIDs() []string { return t._id }
 
+// Text returns the task's file contents: its updates, in order,
+// each beginning with a marker line. The caller must not modify it.
+func (t *Task) Text() []byte { return t.body }
+
+// Keys returns the task's header keys in sorted order.
+func (t *Task) Keys() []string {
+	var keys []string
+	for k := range t.hdr {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 type List struct {
 	name     string
 	dir      string
@@ -51,6 +71,12 @@ type List struct {
 	haveAll  bool
 	haveDone bool
 	cache    map[string]*Task
+
+	allowWrites bool              // see AllowWrites
+	hdrAlias    map[string]string // see HeaderAliases
+	stateCfg    *States           // see States
+	cfgStamp    string            // see checkConfig
+	importing   bool              // see Importing
 }
 
 var (
@@ -64,7 +90,47 @@ func isMarker(line []byte) bool {
 }
 
 func dir(name string) string {
-	return filepath.Join(os.Getenv("HOME"), "todo", name)
+	return filepath.Join(Root(), filepath.FromSlash(name))
+}
+
+// Root returns the directory holding all the task lists:
+// $TODO_DIR if set, or else $HOME/todo (%USERPROFILE%\todo on Windows)
+// if it exists, or else $XDG_DATA_HOME/todo if $XDG_DATA_HOME is set,
+// or else $HOME/todo. If a profile is selected, Root returns
+// the profile's root instead (see SetProfile).
+func Root() string {
+	base := os.Getenv("TODO_DIR")
+	if base == "" {
+		base = defaultRoot()
+	}
+	if p := Profile(); p != "" {
+		return profileRoot(base, p)
+	}
+	return base
+}
+
+// defaultRoot returns the root used when $TODO_DIR is not set.
+// An existing $HOME/todo wins over $XDG_DATA_HOME/todo,
+// so that setting $XDG_DATA_HOME does not hide existing lists.
+func defaultRoot() string {
+	home := filepath.Join(HomeDir(), "todo")
+	if _, err := os.Stat(home); err == nil {
+		return home
+	}
+	if xdg := os.Getenv("XDG_DATA_HOME"); xdg != "" && filepath.IsAbs(xdg) {
+		return filepath.Join(xdg, "todo")
+	}
+	return home
+}
+
+// HomeDir returns the user's home directory:
+// $HOME, or %USERPROFILE% on Windows.
+func HomeDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return os.Getenv("HOME")
+	}
+	return home
 }
 
 func OpenList(name string) *List {
@@ -80,6 +146,128 @@ func IsList(name string) bool {
 	return err == nil && info.IsDir()
 }
 
+// MakeList creates the directory for the named list,
+// if it does not already exist.
+func MakeList(name string) error {
+	return os.MkdirAll(dir(name), 0777)
+}
+
+// NewList creates the named list, which must not already exist,
+// seeding its _config file with a commented header and its _about
+// file with about, if about is not empty. Lists containing it are
+// created as needed.
+func NewList(name, about string) (*List, error) {
+	name = path.Clean(strings.Trim(name, "/"))
+	if name == "." {
+		return nil, fmt.Errorf("invalid list name %q", name)
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if elem == ".." || strings.HasPrefix(elem, "_") || strings.HasPrefix(elem, ".") {
+			return nil, fmt.Errorf("invalid list name %q", name)
+		}
+	}
+	if IsList(name) {
+		return nil, fmt.Errorf("list %s %w", name, ErrExists)
+	}
+	if err := MakeList(name); err != nil {
+		return nil, err
+	}
+	l := OpenList(name)
+	config := "# Settings for the list " + name + ", one \"key: value\" per line.\n"
+	if err := ioutil.WriteFile(filepath.Join(l.dir, "_config"), []byte(config), 0666); err != nil {
+		return nil, err
+	}
+	if about = strings.TrimSpace(about); about != "" {
+		if err := ioutil.WriteFile(filepath.Join(l.dir, "_about"), []byte(about+"\n"), 0666); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// keptDirs lists the directories in a list holding data worth keeping:
+// deleted tasks, archived done tasks, attachments, and drafts.
+var keptDirs = []string{"_trash", "_done", "_attach", "_drafts"}
+
+// RemoveList removes the named list, which must be empty:
+// it may hold configuration and state files but no tasks or sublists,
+// nor any deleted tasks, archived tasks, attachments, or drafts.
+func RemoveList(name string) error {
+	l, err := existingList(name)
+	if err != nil {
+		return err
+	}
+	if err := l.checkWritable(); err != nil {
+		return err
+	}
+	files, _ := filepath.Glob(filepath.Join(l.dir, "*.todo"))
+	done, _ := filepath.Glob(filepath.Join(l.dir, "*.done"))
+	var kept []string
+	for _, d := range keptDirs {
+		if infos, err := ioutil.ReadDir(filepath.Join(l.dir, d)); err == nil && len(infos) > 0 {
+			kept = append(kept, d)
+		}
+	}
+	if n, subs := len(files)+len(done), len(l.Sublists()); n > 0 || subs > 0 || len(kept) > 0 {
+		return &NotEmptyError{List: l.name, Tasks: n, Sublists: subs, Kept: kept}
+	}
+	return os.RemoveAll(l.dir)
+}
+
+// A NotEmptyError reports an attempt to remove a list that is not empty.
+type NotEmptyError struct {
+	List     string   // name of the list
+	Tasks    int      // number of tasks in the list
+	Sublists int      // number of sublists
+	Kept     []string // non-empty directories of kept data, such as _trash
+}
+
+func (e *NotEmptyError) Error() string {
+	s := fmt.Sprintf("list %s is not empty: %d tasks, %d sublists", e.List, e.Tasks, e.Sublists)
+	if len(e.Kept) > 0 {
+		s += ", and " + strings.Join(e.Kept, ", ")
+	}
+	return s
+}
+
+// ArchiveList moves the named list, with its tasks and sublists,
+// into the _archive tree in the root, where Sublists does not see it,
+// and returns the directory now holding it. If the list has been archived
+// before, the new archive's directory name has the time appended.
+func ArchiveList(name string, now time.Time) (string, error) {
+	l, err := existingList(name)
+	if err != nil {
+		return "", err
+	}
+	if err := l.checkWritable(); err != nil {
+		return "", err
+	}
+	dst := filepath.Join(Root(), "_archive", filepath.FromSlash(l.name))
+	if _, err := os.Stat(dst); err == nil {
+		dst += "." + now.Format("20060102-150405")
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+		return "", err
+	}
+	if err := os.Rename(l.dir, dst); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// existingList returns the named list, which must exist
+// and must not be the root.
+func existingList(name string) (*List, error) {
+	name = path.Clean(strings.Trim(name, "/"))
+	if name == "." || strings.HasPrefix(name, "..") {
+		return nil, fmt.Errorf("invalid list name %q", name)
+	}
+	if !IsList(name) {
+		return nil, fmt.Errorf("list %s %w", name, ErrNotExist)
+	}
+	return OpenList(name), nil
+}
+
 func (l *List) Sublists() []string {
 	var out []string
 	infos, _ := ioutil.ReadDir(l.dir)
@@ -106,11 +294,44 @@ func (l *List) Exists(id string) bool {
 }
 
 func (l *List) Read(id string) (*Task, error) {
-	l.mu.Lock()
+	return l.ReadContext(context.Background(), id)
+}
+
+// ReadContext is like Read but gives up, returning ctx.Err(),
+// if ctx is done before the task can be read, as when another
+// goroutine holds the list while scanning a slow file system.
+// A file read already in progress cannot be interrupted.
+func (l *List) ReadContext(ctx context.Context, id string) (*Task, error) {
+	if err := l.lock(ctx); err != nil {
+		return nil, err
+	}
 	defer l.mu.Unlock()
 	return l.read(id)
 }
 
+// lock locks l, unless ctx is done first.
+func (l *List) lock(ctx context.Context) error {
+	if ctx.Done() == nil {
+		l.mu.Lock()
+		return nil
+	}
+	locked := make(chan bool)
+	go func() {
+		l.mu.Lock()
+		select {
+		case locked <- true:
+		case <-ctx.Done():
+			l.mu.Unlock()
+		}
+	}()
+	select {
+	case <-locked:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (l *List) read(id string) (*Task, error) {
 	// l is locked
 	if t := l.cache[id]; t != nil {
@@ -127,12 +348,24 @@ func (l *List) read(id string) (*Task, error) {
 		file = filepath.Join(l.dir, id+".done")
 		d, err1 = ioutil.ReadFile(file)
 		if err1 != nil {
-			return nil, err
+			return nil, fileError("task "+l.ref(id), err)
 		}
 	}
 
+	t, err := parseTask(id, file, d, l.headerAliases())
+	if err != nil {
+		return nil, err
+	}
+	t.states = l.states()
+	l.cache[id] = t
+	return t, nil
+}
+
+// parseTask parses the task id from the contents d of its file,
+// renaming headers according to aliases.
+func parseTask(id, file string, d []byte, aliases map[string]string) (*Task, error) {
 	if !bytes.HasPrefix(d, emSpace) {
-		return nil, fmt.Errorf("malformed task file")
+		return nil, fmt.Errorf("%w task file %s", ErrMalformed, file)
 	}
 
 	t := &Task{
@@ -174,6 +407,7 @@ func (l *List) read(id string) (*Task, error) {
 			if strings.HasPrefix(k, "#") {
 				continue
 			}
+			k = canonicalHeader(aliases, k)
 			if v == "" {
 				delete(t.hdr, k)
 			} else {
@@ -181,28 +415,60 @@ func (l *List) read(id string) (*Task, error) {
 			}
 		}
 	}
-
-	l.cache[id] = t
 	return t, nil
 }
 
+// Done reports whether t is closed: done, muted,
+// or in one of its list's closed states.
+// A task muted until a date is not closed, only deferred.
 func (t *Task) Done() bool {
 	switch t.Header("todo") {
 	case "done", "mute":
 		return true
 	}
-	return false
+	if _, ok := ParseMuteUntil(t.Header("todo")); ok {
+		return false
+	}
+	return t.states.Closed(t.State())
 }
 
 func (l *List) Write(t *Task, now time.Time, hdr map[string]string, comment []byte) error {
+	if err := l.checkWritable(); err != nil {
+		return err
+	}
+	hdr = canonicalizeHeaders(hdr, l.HeaderAliases())
+	normalizeDates(hdr, now)
+	if err := l.checkState(hdr); err != nil {
+		return err
+	}
 	l.mu.Lock()
-	defer l.mu.Unlock()
+	wasDone := t.Done()
+	hdr = statusHeaders(t, now, hdr, l.importing)
+	err := l.write(t, now, hdr, comment)
+	l.mu.Unlock()
+	if err != nil {
+		return err
+	}
 
-	return l.write(t, now, hdr, comment)
+	event := "update"
+	if t.Done() && !wasDone {
+		event = "done"
+	}
+	l.journal(event, t.id, changeDetail(hdr, comment))
+	l.runHooks(event, t, now, hdr, comment)
+	return nil
 }
 
 func (l *List) write(t *Task, now time.Time, hdr map[string]string, comment []byte) error {
 	// l is locked
+	if max := l.MaxUpdate(); max > 0 && len(comment) > max {
+		c, err := l.externalize(t.id, now, comment)
+		if err != nil {
+			return err
+		}
+		comment = c
+	}
+
 	var buf bytes.Buffer
 	var keys []string
 	for k := range hdr {
@@ -245,6 +511,10 @@ func (l *List) write(t *Task, now time.Time, hdr map[string]string, comment []by
 	// Range keys, not hdr, to pick up todo change.
 	for _, k := range keys {
 		v := hdr[k]
+		if k == "#id" {
+			t._id = append(t._id, v)
+			continue
+		}
 		if v == "" {
 			delete(t.hdr, k)
 		} else {
@@ -272,15 +542,81 @@ func (l *List) write(t *Task, now time.Time, hdr map[string]string, comment []by
 }
 
 func (l *List) Create(id string, now time.Time, hdr map[string]string, comment []byte) (*Task, error) {
+	if err := l.checkWritable(); err != nil {
+		return nil, err
+	}
+	hdr = canonicalizeHeaders(hdr, l.HeaderAliases())
+	normalizeDates(hdr, now)
+	if err := l.checkState(hdr); err != nil {
+		return nil, err
+	}
+	slugID := ""
+	if id == "" && l.Setting("ids") == "slug" {
+		slugID = slug(hdr["title"])
+	}
 	l.mu.Lock()
-	defer l.mu.Unlock()
+	t, err := l.create(id, slugID, now, hdr, comment)
+	l.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	l.journal("create", t.id, changeDetail(hdr, comment))
+	l.runHooks("create", t, now, hdr, comment)
+	// The record is a convenience; failing to save it is not an error.
+	l.SetState("last", t.id)
+	return t, nil
+}
+
+// LastCreated returns the ID of the task most recently created in l,
+// or "" if none has been recorded.
+func (l *List) LastCreated() string {
+	return l.State("last")
+}
+
+func (l *List) create(id, slugID string, now time.Time, hdr map[string]string, comment []byte) (*Task, error) {
+	// l is locked
+
+	var file string
+	var err error
+	if slugID != "" {
+		id, file, err = l.reserveSlug(slugID)
+	} else {
+		id, file, err = l.reserve(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Task{
+		file:   file,
+		id:     id,
+		hdr:    make(map[string]string),
+		states: l.states(),
+	}
+	if l.cache == nil {
+		l.cache = make(map[string]*Task)
+	}
+	l.cache[id] = t
+
+	if err := l.write(t, now, hdr, comment); err != nil {
+		os.Remove(file)
+		return nil, err
+	}
+	return t, nil
+}
+
+// reserve creates an empty file for a new task with the given id,
+// or, if id is empty, the next unused numeric ID,
+// returning the ID and file name.
+func (l *List) reserve(id string) (string, string, error) {
+	// l is locked
 
 	var file string
 	var f *os.File
 	if id == "" {
 		names, err := filepath.Glob(filepath.Join(l.dir, "*.*"))
 		if err != nil {
-			return nil, err
+			return "", "", err
 		}
 		// TODO cache max
 		max := 0
@@ -300,7 +636,7 @@ func (l *List) Create(id string, now time.Time, hdr map[string]string, comment [
 			f, err = os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
 			if err != nil {
 				if try >= 2 {
-					return nil, err
+					return "", "", err
 				}
 				continue
 			}
@@ -311,36 +647,80 @@ func (l *List) Create(id string, now time.Time, hdr map[string]string, comment [
 			if '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || c == '-' || c == '_' {
 				continue
 			}
-			return nil, fmt.Errorf("invalid task name %q - must be /[0-9a-z_\\-]+/", id)
+			return "", "", fmt.Errorf("invalid task name %q - must be /[0-9a-z_\\-]+/", id)
 		}
 
 		if l.cache[id] != nil {
-			return nil, fmt.Errorf("task already exists")
+			return "", "", fmt.Errorf("task %s %w", l.ref(id), ErrExists)
 		}
 		file = filepath.Join(l.dir, id+".todo")
 		var err error
 		f, err = os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
 		if err != nil {
-			return nil, err
+			return "", "", fileError("task "+l.ref(id), err)
 		}
 	}
 	f.Close()
+	return id, file, nil
+}
 
-	t := &Task{
-		file: file,
-		id:   id,
-		hdr:  make(map[string]string),
+// Move moves the task t from l to the list dst, returning the moved task.
+// The task keeps its ID if it is a name; a numeric ID is replaced
+// by the next unused ID in dst. Its attachments move with it.
+func (l *List) Move(t *Task, dst *List) (*Task, error) {
+	if dst.dir == l.dir {
+		return t, nil
+	}
+	if err := l.checkWritable(); err != nil {
+		return nil, err
+	}
+	if err := dst.checkWritable(); err != nil {
+		return nil, err
+	}
+	id := t.id
+	if _, err := strconv.Atoi(id); err == nil {
+		id = ""
+	} else if dst.Exists(id) {
+		return nil, fmt.Errorf("task %s %w", dst.ref(id), ErrExists)
 	}
-	l.cache[id] = t
 
-	if err := l.write(t, now, hdr, comment); err != nil {
+	dst.mu.Lock()
+	id, file, err := dst.reserve(id)
+	dst.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if ext := filepath.Ext(t.file); ext != ".todo" {
+		os.Remove(file)
+		file = strings.TrimSuffix(file, ".todo") + ext
+	}
+	if err := os.Rename(t.file, file); err != nil {
 		os.Remove(file)
 		return nil, err
 	}
-	return t, nil
+	if _, err := os.Stat(l.attachDir(t.id)); err == nil {
+		if err := os.MkdirAll(filepath.Dir(dst.attachDir(id)), 0777); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(l.attachDir(t.id), dst.attachDir(id)); err != nil {
+			return nil, err
+		}
+	}
+
+	l.mu.Lock()
+	delete(l.cache, t.id)
+	l.mu.Unlock()
+
+	l.journal("move", t.id, "to "+dst.ref(id))
+	dst.journal("move", id, "from "+l.ref(t.id))
+
+	dst.mu.Lock()
+	defer dst.mu.Unlock()
+	delete(dst.cache, id)
+	return dst.read(id)
 }
 
-func (l *List) readAll(glob string) ([]*Task, error) {
+func (l *List) readAll(ctx context.Context, glob string) ([]*Task, error) {
 	// l is locked
 	names, err := filepath.Glob(filepath.Join(l.dir, glob))
 	if err != nil {
@@ -348,6 +728,9 @@ func (l *List) readAll(glob string) ([]*Task, error) {
 	}
 	var tasks []*Task
 	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		t, err := l.read(strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)))
 		if err != nil {
 			continue
@@ -358,11 +741,22 @@ func (l *List) readAll(glob string) ([]*Task, error) {
 }
 
 func (l *List) All() ([]*Task, error) {
-	l.mu.Lock()
+	return l.AllContext(context.Background())
+}
+
+// AllContext is like All but stops reading tasks,
+// returning ctx.Err(), once ctx is done.
+func (l *List) AllContext(ctx context.Context) ([]*Task, error) {
+	if err := l.lock(ctx); err != nil {
+		return nil, err
+	}
 	defer l.mu.Unlock()
+	l.checkConfig()
 
 	if !l.haveAll {
-		l.readAll("*.todo")
+		if _, err := l.readAll(ctx, "*.todo"); err != nil {
+			return nil, err
+		}
 	}
 
 	var list []*Task
@@ -378,11 +772,22 @@ func (l *List) All() ([]*Task, error) {
 }
 
 func (l *List) Done() ([]*Task, error) {
-	l.mu.Lock()
+	return l.DoneContext(context.Background())
+}
+
+// DoneContext is like Done but stops reading tasks,
+// returning ctx.Err(), once ctx is done.
+func (l *List) DoneContext(ctx context.Context) ([]*Task, error) {
+	if err := l.lock(ctx); err != nil {
+		return nil, err
+	}
 	defer l.mu.Unlock()
+	l.checkConfig()
 
 	if !l.haveDone {
-		l.readAll("*.done")
+		if _, err := l.readAll(ctx, "*.done"); err != nil {
+			return nil, err
+		}
 	}
 
 	var list []*Task
@@ -397,102 +802,105 @@ func (l *List) Done() ([]*Task, error) {
 	return list, nil
 }
 
-func (l *List) Search(q string) ([]*Task, error) {
-	m, needDone, err := parseQuery(q)
-	if err != nil {
-		return nil, err
+// Modified returns the IDs of the tasks whose files have been modified
+// after the given time, rereading those tasks so that later calls
+// see changes made by other programs.
+func (l *List) Modified(since time.Time) ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.checkConfig()
+
+	var ids []string
+	for _, glob := range []string{"*.todo", "*.done"} {
+		names, err := filepath.Glob(filepath.Join(l.dir, glob))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			info, err := os.Stat(name)
+			if err != nil || !info.ModTime().After(since) {
+				continue
+			}
+			id := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+			delete(l.cache, id)
+			if _, err := l.read(id); err == nil {
+				ids = append(ids, id)
+			}
+		}
 	}
+	sort.Strings(ids)
+	return ids, nil
+}
 
+// ExternalIDs returns a map from external ID (as recorded in #id headers)
+// to the task carrying that ID, covering both open and done tasks.
+func (l *List) ExternalIDs() (map[string]*Task, error) {
 	all, err := l.All()
 	if err != nil {
 		return nil, err
 	}
-	var done []*Task
-	if needDone {
-		done, err = l.Done()
-		if err != nil {
-			return nil, err
-		}
+	done, err := l.Done()
+	if err != nil {
+		return nil, err
 	}
-
-	var tasks []*Task
+	m := make(map[string]*Task)
 	for _, list := range [][]*Task{all, done} {
 		for _, t := range list {
-			if m(t) {
-				tasks = append(tasks, t)
+			for _, id := range t._id {
+				m[id] = t
 			}
 		}
 	}
-	return tasks, nil
+	return m, nil
 }
 
-func parseQuery(q string) (match func(*Task) bool, needDone bool, err error) {
-	var ms []func(*Task) bool
-	applySnooze := true
-	for _, f := range strings.Fields(q) {
-		var m func(*Task) bool
-		neg := false
-		if strings.HasPrefix(f, "-") {
-			neg = true
-			f = f[1:]
-		}
-		if f == "all" {
-			m = func(t *Task) bool { return t.Header("todo") != "done" }
-		} else if i := strings.Index(f, ":"); i >= 0 {
-			k := f[:i]
-			v := f[i+1:]
-			if k == "todo" && (strings.Contains(v, "done") || strings.Contains(v, "mute")) {
-				needDone = true
-			}
-			if k == "todo" && strings.Contains(v, "snooze") {
-				applySnooze = false
-			}
-			if strings.HasPrefix(v, "<") {
-				m = func(t *Task) bool { return t.hdr[k] != "" && t.hdr[k] < v[1:] }
-			} else if strings.HasPrefix(v, ">") {
-				m = func(t *Task) bool { return t.hdr[k] != "" && t.hdr[k] > v[1:] }
-			} else if strings.HasPrefix(v, "=") {
-				m = func(t *Task) bool { return t.hdr[k] == v[1:] }
-			} else {
-				m = func(t *Task) bool { return strings.Contains(t.hdr[k], v) }
-			}
-		} else {
-			b := []byte(f)
-			m = func(t *Task) bool { return bytes.Contains(t.body, b) }
-		}
-		if neg {
-			m1 := m
-			m = func(t *Task) bool { return !m1(t) }
-		}
-		ms = append(ms, m)
-	}
+// Search returns the tasks matching the query q.
+// Text and header substring matches ignore case
+// and differences in Unicode composition.
+func (l *List) Search(q string) ([]*Task, error) {
+	return l.search(context.Background(), q, false)
+}
 
-	if applySnooze {
-		snoozeTime := "snooze " + time.Now().Format("2006-01-02")
-		ms = append(ms, func(t *Task) bool {
-			s := t.Header("todo")
-			if strings.HasPrefix(s, "snooze ") && s > snoozeTime {
-				return false
-			}
-			return true
-		})
-	}
+// SearchExact is like Search but matches text and headers byte for byte.
+func (l *List) SearchExact(q string) ([]*Task, error) {
+	return l.search(context.Background(), q, true)
+}
 
-	m := func(t *Task) bool {
-		for _, m1 := range ms {
-			if !m1(t) {
-				return false
-			}
-		}
-		return true
+// SearchContext is like Search but gives up, returning ctx.Err(),
+// once ctx is done, for servers abandoning slow scans of large lists.
+func (l *List) SearchContext(ctx context.Context, q string) ([]*Task, error) {
+	return l.search(ctx, q, false)
+}
+
+// SearchExactContext is like SearchExact but gives up,
+// returning ctx.Err(), once ctx is done.
+func (l *List) SearchExactContext(ctx context.Context, q string) ([]*Task, error) {
+	return l.search(ctx, q, true)
+}
+
+func (l *List) search(ctx context.Context, q string, exact bool) ([]*Task, error) {
+	query, err := parseQueryTerms(q, l.Aliases(), l.HeaderAliases(), exact)
+	if err != nil {
+		return nil, err
 	}
+	return l.SearchQuery(ctx, query)
+}
 
-	return m, needDone, nil
+// foldString returns s in NFC form with its case folded, for searching.
+func foldString(s string) string {
+	return strings.ToLower(norm.NFC.String(s))
 }
 
 var nlEmSpace = []byte("\n— ")
 
 func (t *Task) PrintTo(w io.Writer) {
+	t.PrintRecentTo(w, -1)
+}
+
+// PrintRecentTo is like PrintTo but prints only the n most recent
+// updates, or all of them if n < 0. It returns the number of updates
+// left out.
+func (t *Task) PrintRecentTo(w io.Writer, n int) (hidden int) {
 	var keys []string
 	for k := range t.hdr {
 		if k != "title" {
@@ -509,6 +917,20 @@ func (t *Task) PrintTo(w io.Writer) {
 	}
 	fmt.Fprintf(w, "\n")
 
+	update := t.rawUpdates()
+	if n >= 0 && n < len(update) {
+		hidden = len(update) - n
+		update = update[hidden:]
+	}
+	for i := len(update) - 1; i >= 0; i-- {
+		w.Write(update[i])
+	}
+	return hidden
+}
+
+// rawUpdates splits the task body into the text of its individual updates,
+// each beginning with a timestamp marker line.
+func (t *Task) rawUpdates() [][]byte {
 	var update [][]byte
 	start := 0
 	for {
@@ -519,11 +941,42 @@ func (t *Task) PrintTo(w io.Writer) {
 		update = append(update, t.body[start:start+i+1])
 		start += i + 1
 	}
-	update = append(update, t.body[start:])
+	return append(update, t.body[start:])
+}
 
-	for i := len(update) - 1; i >= 0; i-- {
-		w.Write(update[i])
+// An Update is a single timestamped entry in a task's history.
+type Update struct {
+	Time    string            // "2006-01-02 15:04:05", like ctime and mtime
+	Header  map[string]string // headers set (or, if empty, cleared) by the update
+	Comment string            // comment text, if any
+}
+
+// Updates returns the task's history, oldest first.
+func (t *Task) Updates() []*Update {
+	var list []*Update
+	for _, text := range t.rawUpdates() {
+		lines := strings.Split(string(text), "\n")
+		if !isMarker([]byte(lines[0])) {
+			continue
+		}
+		u := &Update{
+			Time:   strings.TrimSpace(lines[0][len(emSpace) : len(lines[0])-len(spaceEm)]),
+			Header: make(map[string]string),
+		}
+		lines = lines[1:]
+		for len(lines) > 0 {
+			line := lines[0]
+			i := strings.Index(line, ":")
+			if strings.TrimSpace(line) == "" || i < 0 {
+				break
+			}
+			u.Header[strings.ToLower(strings.TrimSpace(line[:i]))] = strings.TrimSpace(line[i+1:])
+			lines = lines[1:]
+		}
+		u.Comment = strings.TrimSpace(strings.Join(lines, "\n"))
+		list = append(list, u)
 	}
+	return list
 }
 
 func Common(tasks []*Task) *Task {
@@ -0,0 +1,197 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The trash.
+//
+// Delete does not remove a task but moves it, with its attachments,
+// into the _trash directory in the list's directory, in a subdirectory
+// named for the time of the deletion, as in _trash/20190605-150405/123.todo.
+// Restore moves a deleted task back, and EmptyTrash removes
+// deleted tasks for good once they have been in the trash long enough.
+
+// trashTime is the layout of the trash subdirectory names.
+const trashTime = "20060102-150405"
+
+// A Trashed is a deleted task in a list's trash.
+type Trashed struct {
+	ID      string    // task ID at the time of deletion
+	Title   string    // task title
+	Deleted time.Time // time of deletion
+	file    string
+}
+
+// Delete moves the task t from l into l's trash.
+func (l *List) Delete(t *Task, now time.Time) error {
+	if err := l.checkWritable(); err != nil {
+		return err
+	}
+	dir := filepath.Join(l.dir, "_trash", now.Local().Format(trashTime))
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	if err := os.Rename(t.file, filepath.Join(dir, filepath.Base(t.file))); err != nil {
+		return err
+	}
+	if _, err := os.Stat(l.attachDir(t.id)); err == nil {
+		if err := os.MkdirAll(filepath.Join(dir, "_attach"), 0777); err != nil {
+			return err
+		}
+		if err := os.Rename(l.attachDir(t.id), filepath.Join(dir, "_attach", t.id)); err != nil {
+			return err
+		}
+	}
+	l.mu.Lock()
+	delete(l.cache, t.id)
+	l.mu.Unlock()
+	l.journal("delete", t.id, "")
+	return nil
+}
+
+// Trash returns the deleted tasks in l's trash, oldest deletion first.
+func (l *List) Trash() ([]*Trashed, error) {
+	dirs, err := ioutil.ReadDir(filepath.Join(l.dir, "_trash"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return nil, err
+	}
+	var out []*Trashed
+	for _, d := range dirs {
+		when, err := time.ParseInLocation(trashTime, d.Name(), time.Local)
+		if err != nil || !d.IsDir() {
+			continue
+		}
+		dir := filepath.Join(l.dir, "_trash", d.Name())
+		files, _ := filepath.Glob(filepath.Join(dir, "*.todo"))
+		done, _ := filepath.Glob(filepath.Join(dir, "*.done"))
+		for _, file := range append(files, done...) {
+			id := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+			tr := &Trashed{ID: id, Deleted: when, file: file}
+			if data, err := ioutil.ReadFile(file); err == nil {
+				tr.Title = titleOf(data)
+			}
+			out = append(out, tr)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Deleted.Before(out[j].Deleted) })
+	return out, nil
+}
+
+// titleOf returns the last title set in the task file data.
+func titleOf(data []byte) string {
+	title := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "title:") {
+			title = strings.TrimSpace(strings.TrimPrefix(line, "title:"))
+		}
+	}
+	return title
+}
+
+// Restore moves the most recently deleted task with the given ID
+// from l's trash back into l, returning the restored task.
+// If the ID has been reused since the deletion, a numeric ID is
+// replaced by the next unused one, as in Move.
+func (l *List) Restore(id string) (*Task, error) {
+	if err := l.checkWritable(); err != nil {
+		return nil, err
+	}
+	trash, err := l.Trash()
+	if err != nil {
+		return nil, err
+	}
+	var tr *Trashed
+	for _, t := range trash {
+		if t.ID == id {
+			tr = t
+		}
+	}
+	if tr == nil {
+		return nil, fmt.Errorf("task %s in trash %w", l.ref(id), ErrNotExist)
+	}
+
+	newID := id
+	if l.Exists(id) {
+		if _, err := strconv.Atoi(id); err != nil {
+			return nil, fmt.Errorf("task %s %w", l.ref(id), ErrExists)
+		}
+		newID = ""
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	newID, file, err := l.reserve(newID)
+	if err != nil {
+		return nil, err
+	}
+	if ext := filepath.Ext(tr.file); ext != ".todo" {
+		os.Remove(file)
+		file = strings.TrimSuffix(file, ".todo") + ext
+	}
+	if err := os.Rename(tr.file, file); err != nil {
+		os.Remove(file)
+		return nil, err
+	}
+	dir := filepath.Dir(tr.file)
+	if _, err := os.Stat(filepath.Join(dir, "_attach", id)); err == nil {
+		if err := os.MkdirAll(filepath.Dir(l.attachDir(newID)), 0777); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(filepath.Join(dir, "_attach", id), l.attachDir(newID)); err != nil {
+			return nil, err
+		}
+	}
+	os.Remove(filepath.Join(dir, "_attach"))
+	os.Remove(dir)
+	delete(l.cache, newID)
+	detail := ""
+	if newID != id {
+		detail = "was " + id
+	}
+	l.journal("restore", newID, detail)
+	return l.read(newID)
+}
+
+// EmptyTrash permanently removes the tasks deleted from l before
+// the given time and returns the number removed.
+func (l *List) EmptyTrash(before time.Time) (int, error) {
+	if err := l.checkWritable(); err != nil {
+		return 0, err
+	}
+	dirs, err := ioutil.ReadDir(filepath.Join(l.dir, "_trash"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return 0, err
+	}
+	n := 0
+	for _, d := range dirs {
+		when, err := time.ParseInLocation(trashTime, d.Name(), time.Local)
+		if err != nil || !d.IsDir() || !when.Before(before) {
+			continue
+		}
+		dir := filepath.Join(l.dir, "_trash", d.Name())
+		files, _ := filepath.Glob(filepath.Join(dir, "*.todo"))
+		done, _ := filepath.Glob(filepath.Join(dir, "*.done"))
+		if err := os.RemoveAll(dir); err != nil {
+			return n, err
+		}
+		n += len(files) + len(done)
+	}
+	return n, nil
+}
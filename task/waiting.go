@@ -0,0 +1,141 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package task
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Deferred states.
+//
+// A task whose todo header is "snooze date", "waiting who date",
+// or "mute until date" is deferred: queries leave it out until the date
+// arrives, when it resurfaces on its own, unless they ask about the state,
+// as in todo:snooze or todo:waiting. Waiting records a task handed to
+// someone else, named by a single word, such as "waiting alice 2019-07-01".
+// The date is optional; a waiting task without one stays out of queries
+// until its todo header changes. Unlike a plain mute, which closes a task
+// for good, mute until leaves the task open, so that it can resurface.
+// When written, a date given in words, as in "snooze next fri",
+// "waiting alice next fri", or "mute until jan 5", is rewritten
+// as in NormalizeDate, and a date that cannot be understood is an error.
+
+// Deferred reports whether t is deferred at time now,
+// so that queries not asking about its state leave it out.
+func (t *Task) Deferred(now time.Time) bool {
+	s := t.Header("todo")
+	var until string
+	if strings.HasPrefix(s, "snooze ") {
+		until = strings.TrimPrefix(s, "snooze ")
+	} else if _, date, ok := ParseWaiting(s); ok {
+		if date == "" {
+			return true
+		}
+		until = date
+	} else if date, ok := ParseMuteUntil(s); ok {
+		until = date
+	} else {
+		return false
+	}
+	return until > now.Format("2006-01-02")
+}
+
+// ParseWaiting parses a todo header value of the form "waiting who [date]",
+// returning who the task is waiting on and the date, or "" if there is none.
+func ParseWaiting(s string) (who, date string, ok bool) {
+	f := strings.Fields(s)
+	if len(f) < 2 || f[0] != "waiting" {
+		return "", "", false
+	}
+	if len(f) == 3 && isDate(f[2]) {
+		date = f[2]
+	}
+	return f[1], date, true
+}
+
+// ParseMuteUntil parses a todo header value of the form "mute until date",
+// returning the date.
+func ParseMuteUntil(s string) (date string, ok bool) {
+	f := strings.Fields(s)
+	if len(f) != 3 || f[0] != "mute" || f[1] != "until" {
+		return "", false
+	}
+	return f[2], true
+}
+
+// checkDeferred returns an error if the todo header value v,
+// as rewritten by normalizeDates, is a snooze, mute until, or waiting state
+// whose date is not a date.
+func checkDeferred(v string) error {
+	f := strings.Fields(v)
+	switch {
+	case len(f) >= 1 && f[0] == "snooze":
+		if len(f) != 2 || !isDate(f[1]) {
+			return fmt.Errorf("invalid state %q: want snooze date", v)
+		}
+	case len(f) >= 2 && f[0] == "mute" && f[1] == "until":
+		if len(f) != 3 || !isDate(f[2]) {
+			return fmt.Errorf("invalid state %q: want mute until date", v)
+		}
+	case len(f) >= 3 && f[0] == "waiting":
+		if len(f) != 3 || !isDate(f[2]) {
+			return fmt.Errorf("invalid state %q: want waiting who [date]", v)
+		}
+	}
+	return nil
+}
+
+// isDate reports whether s is a date of the form 2006-01-02.
+func isDate(s string) bool {
+	_, err := time.Parse("2006-01-02", s)
+	return err == nil
+}
+
+// normalizeSnooze returns the todo header value s with the date
+// of a snooze state rewritten as in NormalizeDate.
+// It returns s unchanged if s is not a snooze state with a date.
+func normalizeSnooze(s string, now time.Time) string {
+	f := strings.Fields(s)
+	if len(f) < 2 || f[0] != "snooze" {
+		return s
+	}
+	date := NormalizeDate(strings.Join(f[1:], " "), now)
+	if !isDate(date) {
+		return s
+	}
+	return "snooze " + date
+}
+
+// normalizeMute returns the todo header value s with the date
+// of a mute until state rewritten as in NormalizeDate.
+// It returns s unchanged if s is not a mute until state with a date.
+func normalizeMute(s string, now time.Time) string {
+	f := strings.Fields(s)
+	if len(f) < 3 || f[0] != "mute" || f[1] != "until" {
+		return s
+	}
+	date := NormalizeDate(strings.Join(f[2:], " "), now)
+	if !isDate(date) {
+		return s
+	}
+	return "mute until " + date
+}
+
+// normalizeWaiting returns the todo header value s with the date
+// of a waiting state rewritten as in NormalizeDate.
+// It returns s unchanged if s is not a waiting state with a date.
+func normalizeWaiting(s string, now time.Time) string {
+	f := strings.Fields(s)
+	if len(f) < 3 || f[0] != "waiting" {
+		return s
+	}
+	date := NormalizeDate(strings.Join(f[2:], " "), now)
+	if !isDate(date) {
+		return s
+	}
+	return "waiting " + f[1] + " " + date
+}
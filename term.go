@@ -0,0 +1,129 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// isTerminal reports whether f is a terminal.
+// Other character devices, such as /dev/null, which acme, cron,
+// and scripts often give as standard input, are not terminals.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// termSize returns the width and height of the controlling terminal,
+// using $COLUMNS and $LINES or else 80x24 if the terminal size is unknown.
+func termSize() (width, height int) {
+	width, height = 80, 24
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		width = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("LINES")); err == nil && n > 0 {
+		height = n
+	}
+	if runtime.GOOS == "windows" {
+		return width, height
+	}
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return width, height
+	}
+	defer tty.Close()
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	if err != nil {
+		return width, height
+	}
+	f := strings.Fields(string(out))
+	if len(f) == 2 {
+		h, err1 := strconv.Atoi(f[0])
+		w, err2 := strconv.Atoi(f[1])
+		if err1 == nil && err2 == nil && h > 0 && w > 0 {
+			width, height = w, h
+		}
+	}
+	return width, height
+}
+
+// shellCommand returns a command that runs the shell command line cmdline
+// with the given arguments, as in sh -c 'cmdline "$@"', so that settings
+// like $PAGER and $EDITOR can include arguments of their own.
+// Windows has no sh, so there the command line is instead run directly,
+// followed by args: if it names a program as a whole, as in
+// C:\Program Files\Vim\gvim.exe, that program is run; otherwise its first
+// word, which may be double-quoted to include spaces, names the program
+// and the remaining words are its arguments.
+func shellCommand(cmdline string, args ...string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		if _, err := exec.LookPath(cmdline); err == nil {
+			return exec.Command(cmdline, args...)
+		}
+		var f []string
+		if strings.HasPrefix(cmdline, `"`) {
+			if i := strings.Index(cmdline[1:], `"`); i >= 0 {
+				f = append([]string{cmdline[1 : 1+i]}, strings.Fields(cmdline[2+i:])...)
+			}
+		}
+		if f == nil {
+			f = strings.Fields(cmdline)
+		}
+		if len(f) == 0 {
+			f = []string{cmdline}
+		}
+		return exec.Command(f[0], append(f[1:], args...)...)
+	}
+	return exec.Command("sh", append([]string{"-c", cmdline + ` "$@"`, cmdline}, args...)...)
+}
+
+// rawMode puts the terminal tty in raw mode without echo, using stty,
+// and returns a function that restores its previous state.
+// Windows has no stty, so there rawMode always fails.
+func rawMode(tty *os.File) (restore func(), err error) {
+	if runtime.GOOS == "windows" {
+		return nil, fmt.Errorf("raw terminal mode not supported on %s", runtime.GOOS)
+	}
+	stty := func(args ...string) (string, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = tty
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { stty(saved) }, nil
+}
+
+// confirm asks the user the yes-or-no question prompt on the terminal,
+// reporting whether the answer is yes. Without a terminal to ask,
+// the answer is no.
+func confirm(prompt string) bool {
+	if !isTerminal(os.Stdin) {
+		fmt.Fprintf(os.Stderr, "%s no terminal to confirm; use -yes\n", prompt)
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", prompt)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A thingsItem is an item in the Things JSON format
+// (https://culturedcode.com/things/support/articles/2803573/).
+type thingsItem struct {
+	Type       string
+	Attributes struct {
+		ID             string
+		Title          string
+		Notes          string
+		When           string
+		Deadline       string
+		Tags           []string
+		Completed      bool
+		Canceled       bool
+		CreationDate   string       `json:"creation-date"`
+		ChecklistItems []thingsItem `json:"checklist-items"`
+		Items          []thingsItem
+	}
+}
+
+// parseThings parses a list of Things items in JSON form.
+// Projects become sublists, headings and tags become tags,
+// deadlines become due headers, and when dates become scheduled headers.
+// Checklist items are appended to the task body.
+func parseThings(file string, data []byte) ([]*importTask, error) {
+	var items []thingsItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+	var tasks []*importTask
+	var walk func(items []thingsItem, project, heading string) error
+	walk = func(items []thingsItem, project, heading string) error {
+		for _, item := range items {
+			a := &item.Attributes
+			switch item.Type {
+			case "project":
+				if err := walk(a.Items, listName(a.Title), ""); err != nil {
+					return err
+				}
+			case "heading":
+				if err := walk(a.Items, project, listName(a.Title)); err != nil {
+					return err
+				}
+			case "to-do":
+				it, err := thingsTask(item, project, heading)
+				if err != nil {
+					return err
+				}
+				tasks = append(tasks, it)
+			}
+		}
+		return nil
+	}
+	if err := walk(items, "", ""); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func thingsTask(item thingsItem, project, heading string) (*importTask, error) {
+	a := &item.Attributes
+	if a.Title == "" {
+		return nil, fmt.Errorf("to-do has no title")
+	}
+	it := &importTask{
+		list: project,
+		hdr:  map[string]string{"title": oneLine(a.Title)},
+		body: a.Notes,
+	}
+	if a.ID != "" {
+		it.eid = "things:" + a.ID
+	} else {
+		it.eid = hashEID("things", project, a.Title)
+	}
+	if tm, err := parseImportTime(a.CreationDate); err == nil {
+		it.created = tm
+	}
+	var tags []string
+	if heading != "" {
+		tags = append(tags, heading)
+	}
+	for _, t := range a.Tags {
+		tags = append(tags, listName(t))
+	}
+	if len(tags) > 0 {
+		it.hdr["tag"] = strings.Join(tags, " ")
+	}
+	if _, err := time.Parse("2006-01-02", a.Deadline); err == nil {
+		it.hdr["due"] = a.Deadline
+	}
+	if _, err := time.Parse("2006-01-02", a.When); err == nil {
+		it.hdr["scheduled"] = a.When
+	}
+	if a.Completed || a.Canceled {
+		it.hdr["todo"] = "done"
+	}
+	if len(a.ChecklistItems) > 0 {
+		var b strings.Builder
+		b.WriteString(strings.TrimSpace(it.body))
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		for _, c := range a.ChecklistItems {
+			mark := " "
+			if c.Attributes.Completed {
+				mark = "x"
+			}
+			fmt.Fprintf(&b, "- [%s] %s\n", mark, c.Attributes.Title)
+		}
+		it.body = b.String()
+	}
+	return it, nil
+}
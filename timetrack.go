@@ -0,0 +1,249 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+// Time tracking uses two headers. While work on a task is under way,
+// its started header records when the work started. Stopping the work
+// clears started and adds the elapsed time to the spent header,
+// which holds the total time spent on the task, as in "spent: 1h30m0s".
+// A timesheet can then find the time spent during a period
+// from the changes to spent in the task's history.
+
+// startTask records that work on t has started at now.
+func startTask(l *task.List, t *task.Task, now time.Time) error {
+	if s := t.Header("started"); s != "" {
+		return fmt.Errorf("%s already started at %s", taskRef(l, t), s)
+	}
+	hdr := map[string]string{"started": now.Format("2006-01-02 15:04:05")}
+	return l.Write(t, now, hdr, nil)
+}
+
+// stopTask records that work on t has stopped at now,
+// adding the time since it started to its spent header.
+// It returns the time added.
+func stopTask(l *task.List, t *task.Task, now time.Time) (time.Duration, error) {
+	s := t.Header("started")
+	if s == "" {
+		return 0, fmt.Errorf("%s not started", taskRef(l, t))
+	}
+	start, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.Local)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid started header %q", taskRef(l, t), s)
+	}
+	d := now.Sub(start).Round(time.Second)
+	if d < 0 {
+		d = 0
+	}
+	total := spent(t.Header("spent")) + d
+	hdr := map[string]string{
+		"started": "",
+		"spent":   total.String(),
+	}
+	comment := []byte(fmt.Sprintf("worked %v", d))
+	return d, l.Write(t, now, hdr, comment)
+}
+
+// spent parses a spent header, returning 0 if it is missing or invalid.
+func spent(s string) time.Duration {
+	d, _ := task.ParseLead(s)
+	return d
+}
+
+func cmdStart(args []string) { cmdTrack("start", args) }
+func cmdStop(args []string)  { cmdTrack("stop", args) }
+
+// cmdTrack implements the start and stop commands.
+func cmdTrack(name string, args []string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo %s id...\n", name)
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+	}
+	l := writableList(*dirFlag)
+	failed := false
+	for _, id := range fs.Args() {
+		t, err := readTask(l, id)
+		if err != nil {
+			log.Print(err)
+			failed = true
+			continue
+		}
+		notePrevious(l, t)
+		now := time.Now()
+		if name == "start" {
+			err = startTask(l, t, now)
+		} else {
+			var d time.Duration
+			d, err = stopTask(l, t, now)
+			if err == nil {
+				fmt.Printf("%s\t%v\t%s\n", taskRef(l, t), d, t.Title())
+			}
+		}
+		if err != nil {
+			log.Print(err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func cmdTimesheet(args []string) {
+	fs := flag.NewFlagSet("timesheet", flag.ExitOnError)
+	since := fs.String("since", "1w", "report time spent since `when`, a duration ago, such as 8h, 3d, or 1w, or a date, such as mon or 2019-07-01")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo timesheet [-since when]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+	}
+	start, err := parseSince(*since, time.Now())
+	if err != nil {
+		log.Fatalf("invalid -since: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := writeTimesheet(&buf, taskList(*dirFlag), start); err != nil {
+		log.Fatal(err)
+	}
+	page(buf.Bytes())
+}
+
+// writeTimesheet writes to w the time spent since start
+// on each task in l and its sublists, followed by the
+// time spent on each tag and the total.
+func writeTimesheet(w io.Writer, l *task.List, start time.Time) error {
+	type entry struct {
+		ref   string
+		title string
+		d     time.Duration
+	}
+	var entries []entry
+	tags := make(map[string]time.Duration)
+	var total time.Duration
+	for _, sub := range allLists(l) {
+		all, err := sub.All()
+		if err != nil {
+			return err
+		}
+		done, err := sub.Done()
+		if err != nil {
+			return err
+		}
+		for _, t := range append(all, done...) {
+			d := spentSince(t, start)
+			if d == 0 {
+				continue
+			}
+			entries = append(entries, entry{taskRef(sub, t), t.Title(), d})
+			total += d
+			for _, tag := range strings.FieldsFunc(t.Header("tag"), isTagSep) {
+				tags[tag] += d
+			}
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].d != entries[j].d {
+			return entries[i].d > entries[j].d
+		}
+		return entries[i].ref < entries[j].ref
+	})
+	for _, e := range entries {
+		fmt.Fprintf(w, "%v\t%s\t%s\n", e.d, e.ref, e.title)
+	}
+	if len(tags) > 0 {
+		var names []string
+		for tag := range tags {
+			names = append(names, tag)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(w, "\n")
+		for _, tag := range names {
+			fmt.Fprintf(w, "%v\ttag %s\n", tags[tag], tag)
+		}
+	}
+	fmt.Fprintf(w, "\n%v\ttotal\n", total)
+	return nil
+}
+
+// spentSince returns the time spent on t since start,
+// according to the changes to its spent header since then.
+func spentSince(t *task.Task, start time.Time) time.Duration {
+	startTime := start.Format("2006-01-02 15:04:05")
+	var d, last time.Duration
+	for _, u := range t.Updates() {
+		s, ok := u.Header["spent"]
+		if !ok {
+			continue
+		}
+		next := spent(s)
+		if u.Time >= startTime && next > last {
+			d += next - last
+		}
+		last = next
+	}
+	return d
+}
+
+// ExecStart starts time tracking for the task in a single-task window.
+func (w *awin) ExecStart() {
+	w.track(func(l *task.List, t *task.Task) error {
+		return startTask(l, t, time.Now())
+	})
+}
+
+// ExecStop stops time tracking for the task in a single-task window.
+func (w *awin) ExecStop() {
+	w.track(func(l *task.List, t *task.Task) error {
+		_, err := stopTask(l, t, time.Now())
+		return err
+	})
+}
+
+func (w *awin) track(f func(*task.List, *task.Task) error) {
+	if w.mode != modeSingle {
+		w.acme.Err("Start and Stop can only be used in single task windows")
+		return
+	}
+	if w.dirty() {
+		w.acme.Err(w.name + " has unsaved changes; Put or Get first")
+		return
+	}
+	l := w.list()
+	t, err := l.Read(w.id())
+	if err != nil {
+		w.acme.Err(err.Error())
+		return
+	}
+	if err := f(l, t); err != nil {
+		w.acme.Err(err.Error())
+		return
+	}
+	w.ExecGet()
+	w.changed([]string{t.ID()})
+}
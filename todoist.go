@@ -0,0 +1,99 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parseTodoist parses a Todoist project exported as a CSV template.
+// Each row has a TYPE of task, note, or section; notes are comments
+// on the preceding task, and sections become the tag of the tasks
+// that follow. The project, named by the file, becomes the sublist.
+// Todoist labels, written as @label in the task content, become tags.
+func parseTodoist(file string, data []byte) ([]*importTask, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	col := make(map[string]int)
+	for i, name := range rows[0] {
+		col[strings.ToUpper(strings.TrimSpace(name))] = i
+	}
+	if _, ok := col["TYPE"]; !ok {
+		return nil, fmt.Errorf("not a Todoist CSV export: missing TYPE column")
+	}
+	get := func(row []string, name string) string {
+		i, ok := col[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	project := listName(baseName(file))
+	var tasks []*importTask
+	var last *importTask
+	section := ""
+	for i, row := range rows[1:] {
+		content := get(row, "CONTENT")
+		switch get(row, "TYPE") {
+		case "section":
+			section = content
+		case "note":
+			if last == nil {
+				return nil, fmt.Errorf("line %d: note without task", i+2)
+			}
+			last.comments = append(last.comments, importComment{text: content})
+		case "task":
+			it := &importTask{
+				list: project,
+				hdr:  make(map[string]string),
+				body: get(row, "DESCRIPTION"),
+			}
+			var title, tags []string
+			for _, w := range strings.Fields(content) {
+				if len(w) > 1 && w[0] == '@' {
+					tags = append(tags, w[1:])
+				} else {
+					title = append(title, w)
+				}
+			}
+			if section != "" {
+				tags = append(tags, listName(section))
+			}
+			it.hdr["title"] = strings.Join(title, " ")
+			if len(tags) > 0 {
+				it.hdr["tag"] = strings.Join(tags, " ")
+			}
+			// Todoist's priority 1 is the highest; 4 means no priority.
+			if p := get(row, "PRIORITY"); p != "" && p != "4" {
+				it.hdr["priority"] = "p" + p
+			}
+			if who := get(row, "RESPONSIBLE"); who != "" {
+				it.hdr["assignee"] = who
+			}
+			if due := get(row, "DATE"); due != "" {
+				if tm, err := time.Parse("2006-01-02", due); err == nil {
+					due = tm.Format("2006-01-02")
+				}
+				it.hdr["due"] = oneLine(due)
+			}
+			it.eid = hashEID("todoist", project, content)
+			tasks = append(tasks, it)
+			last = it
+		}
+	}
+	return tasks, nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+// Support for the todo.txt format (https://github.com/todotxt/todo.txt).
+//
+// A todo.txt line looks like
+//
+//	x (A) 2019-06-02 2019-06-01 Call mom +family @phone due:2019-06-05
+//
+// The leading x marks a completed task, (A) is the priority,
+// the dates are the completion and creation dates,
+// +family is a project, @phone is a context, and due:2019-06-05
+// is a key:value extension.
+//
+// On import, the priority becomes a priority header, contexts
+// become a context header, key:value pairs become headers of
+// the same name, and projects become either a tag header or,
+// if projectLists is set, the sublist holding the task.
+
+const todoTxtDate = "2006-01-02"
+
+func isTodoTxtDate(s string) bool {
+	_, err := time.Parse(todoTxtDate, s)
+	return err == nil
+}
+
+// parseTodoTxt parses the todo.txt data into tasks.
+func parseTodoTxt(data []byte, projectLists bool) ([]*importTask, error) {
+	var tasks []*importTask
+	s := bufio.NewScanner(bytes.NewReader(data))
+	lineno := 0
+	for s.Scan() {
+		lineno++
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		it, err := parseTodoTxtLine(line, projectLists)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineno, err)
+		}
+		tasks = append(tasks, it)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func parseTodoTxtLine(line string, projectLists bool) (*importTask, error) {
+	it := &importTask{hdr: make(map[string]string)}
+	f := strings.Fields(line)
+
+	if len(f) > 0 && f[0] == "x" {
+		it.hdr["todo"] = "done"
+		f = f[1:]
+		if len(f) > 1 && isTodoTxtDate(f[0]) && isTodoTxtDate(f[1]) {
+			f = f[1:] // drop completion date; keep creation date
+		}
+	}
+	if len(f) > 0 && len(f[0]) == 3 && f[0][0] == '(' && f[0][2] == ')' && 'A' <= f[0][1] && f[0][1] <= 'Z' {
+		it.hdr["priority"] = f[0][1:2]
+		f = f[1:]
+	}
+	if len(f) > 0 && isTodoTxtDate(f[0]) {
+		it.created, _ = time.ParseInLocation(todoTxtDate, f[0], time.Local)
+		f = f[1:]
+	}
+
+	var title, projects, contexts []string
+	for _, w := range f {
+		switch {
+		case len(w) > 1 && w[0] == '+':
+			projects = append(projects, w[1:])
+		case len(w) > 1 && w[0] == '@':
+			contexts = append(contexts, w[1:])
+		default:
+			i := strings.Index(w, ":")
+			if i <= 0 || i == len(w)-1 || strings.HasPrefix(w[i+1:], "//") {
+				title = append(title, w)
+				break
+			}
+			k, v := strings.ToLower(w[:i]), w[i+1:]
+			switch {
+			case k == "id":
+				it.eid = v
+			case k == "pri" && it.hdr["priority"] == "":
+				it.hdr["priority"] = v
+			case validHeaderKey(k):
+				it.hdr[k] = v
+			default:
+				title = append(title, w)
+			}
+		}
+	}
+	if len(title) == 0 {
+		return nil, fmt.Errorf("task has no title")
+	}
+	it.hdr["title"] = strings.Join(title, " ")
+	if len(contexts) > 0 {
+		it.hdr["context"] = strings.Join(contexts, " ")
+	}
+	if projectLists && len(projects) > 0 {
+		it.list = listName(projects[0])
+		projects = projects[1:]
+	}
+	if len(projects) > 0 {
+		it.hdr["tag"] = strings.Join(projects, " ")
+	}
+	return it, nil
+}
+
+// listName converts s into a valid list directory name.
+func listName(s string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(s) {
+		if '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || c == '-' || c == '_' {
+			b.WriteRune(c)
+		} else {
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
+
+// todoTxtSpecial lists the headers given special treatment
+// by writeTodoTxt; other space-free headers are written
+// as key:value extensions.
+var todoTxtSpecial = map[string]bool{
+	"title":    true,
+	"todo":     true,
+	"priority": true,
+	"tag":      true,
+	"context":  true,
+	"done":     true,
+}
+
+// writeTodoTxt writes the tasks to w in todo.txt format.
+func writeTodoTxt(w io.Writer, tasks []*task.Task, opt *exportOptions) error {
+	bw := bufio.NewWriter(w)
+	for _, t := range tasks {
+		var f []string
+		if t.Done() {
+			f = append(f, "x", dateOf(t.Header("mtime")))
+		} else if p := t.Header("priority"); len(p) == 1 && 'A' <= p[0] && p[0] <= 'Z' {
+			f = append(f, "("+p+")")
+		}
+		f = append(f, dateOf(t.Header("ctime")), oneLine(t.Title()))
+		for _, tag := range strings.FieldsFunc(t.Header("tag"), isTagSep) {
+			f = append(f, "+"+tag)
+		}
+		for _, ctx := range strings.FieldsFunc(t.Header("context"), isTagSep) {
+			f = append(f, "@"+ctx)
+		}
+		for _, k := range t.Keys() {
+			v := t.Header(k)
+			if todoTxtSpecial[k] || strings.ContainsAny(v, " \t") {
+				continue
+			}
+			f = append(f, k+":"+v)
+		}
+		f = append(f, "id:"+opt.ref(t))
+		fmt.Fprintf(bw, "%s\n", strings.Join(f, " "))
+	}
+	return bw.Flush()
+}
+
+// dateOf returns the date part of a task timestamp.
+func dateOf(ts string) string {
+	if i := strings.Index(ts, " "); i >= 0 {
+		return ts[:i]
+	}
+	return ts
+}
+
+func isTagSep(c rune) bool {
+	return c == ' ' || c == ','
+}
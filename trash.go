@@ -0,0 +1,145 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+// defaultTrashRetention is how long deleted tasks stay in the trash
+// in lists without a trash setting.
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+func cmdDelete(args []string) {
+	fs := flag.NewFlagSet("delete", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo delete id...\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+	}
+	l := writableList(*dirFlag)
+	failed := false
+	for _, id := range fs.Args() {
+		t, err := readTask(l, id)
+		if err == nil {
+			err = l.Delete(t, time.Now())
+		}
+		if err != nil {
+			log.Print(err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func cmdTrash(args []string) {
+	fs := flag.NewFlagSet("trash", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo trash\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+	}
+	var buf bytes.Buffer
+	for _, l := range allLists(taskList(*dirFlag)) {
+		trash, err := l.Trash()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, tr := range trash {
+			fmt.Fprintf(&buf, "%s\t%s\tdeleted %s\n", path.Join(l.Name(), tr.ID), tr.Title, tr.Deleted.Format("2006-01-02 15:04"))
+		}
+	}
+	page(buf.Bytes())
+}
+
+func cmdRestore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo restore id...\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+	}
+	l := writableList(*dirFlag)
+	failed := false
+	for _, id := range fs.Args() {
+		t, err := l.Restore(id)
+		if err != nil {
+			log.Print(err)
+			failed = true
+			continue
+		}
+		fmt.Println(taskRef(l, t))
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func cmdEmptyTrash(args []string) {
+	fs := flag.NewFlagSet("empty-trash", flag.ExitOnError)
+	older := fs.String("older", "", "remove tasks deleted more than `duration` ago, such as 30d (default the list's trash setting, or 30d)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo empty-trash [-older duration]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+	}
+	now := time.Now()
+	failed := false
+	for _, l := range allLists(taskList(*dirFlag)) {
+		if l.ReadOnly() {
+			continue
+		}
+		keep := *older
+		if keep == "" {
+			keep = l.Setting("trash")
+		}
+		d := defaultTrashRetention
+		if keep != "" {
+			var err error
+			if d, err = task.ParseLead(keep); err != nil {
+				log.Printf("list %s: invalid trash retention: %v", l.Name(), err)
+				failed = true
+				continue
+			}
+		}
+		n, err := l.EmptyTrash(now.Add(-d))
+		if err != nil {
+			log.Printf("list %s: %v", l.Name(), err)
+			failed = true
+		}
+		if n > 0 {
+			fmt.Printf("%s\t%d deleted tasks removed\n", l.Name(), n)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type trelloBoard struct {
+	Name  string
+	Lists []struct {
+		ID   string
+		Name string
+	}
+	Cards []struct {
+		ID          string
+		Name        string
+		Desc        string
+		IDList      string
+		Closed      bool
+		Due         string
+		DueComplete bool
+		ShortURL    string
+		Labels      []struct {
+			Name  string
+			Color string
+		}
+	}
+	Actions []struct {
+		Type string
+		Date string
+		Data struct {
+			Text string
+			Card struct {
+				ID string
+			}
+		}
+		MemberCreator struct {
+			FullName string
+		}
+	}
+}
+
+// parseTrello parses a Trello board exported as JSON.
+// The board becomes the sublist, each card a task,
+// the card's Trello list its status header,
+// its labels tags, and its comments updates.
+func parseTrello(file string, data []byte) ([]*importTask, error) {
+	var b trelloBoard
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, err
+	}
+	project := listName(b.Name)
+	if project == "" {
+		project = listName(baseName(file))
+	}
+	listNames := make(map[string]string)
+	for _, l := range b.Lists {
+		listNames[l.ID] = l.Name
+	}
+
+	byCard := make(map[string]*importTask)
+	var tasks []*importTask
+	for _, c := range b.Cards {
+		it := &importTask{
+			eid:     "trello:" + c.ID,
+			list:    project,
+			created: trelloIDTime(c.ID),
+			hdr:     map[string]string{"title": oneLine(c.Name)},
+			body:    c.Desc,
+		}
+		if s := listNames[c.IDList]; s != "" {
+			it.hdr["status"] = oneLine(s)
+		}
+		var tags []string
+		for _, l := range c.Labels {
+			name := l.Name
+			if name == "" {
+				name = l.Color
+			}
+			if name != "" {
+				tags = append(tags, listName(name))
+			}
+		}
+		if len(tags) > 0 {
+			it.hdr["tag"] = strings.Join(tags, " ")
+		}
+		if due, err := time.Parse(time.RFC3339, c.Due); err == nil {
+			it.hdr["due"] = due.Local().Format("2006-01-02")
+		}
+		if c.ShortURL != "" {
+			it.hdr["url"] = c.ShortURL
+		}
+		if c.Closed || c.DueComplete {
+			it.hdr["todo"] = "done"
+		}
+		byCard[c.ID] = it
+		tasks = append(tasks, it)
+	}
+
+	for _, a := range b.Actions {
+		it := byCard[a.Data.Card.ID]
+		if it == nil || a.Type != "commentCard" {
+			continue
+		}
+		tm, _ := time.Parse(time.RFC3339, a.Date)
+		text := a.Data.Text
+		if who := a.MemberCreator.FullName; who != "" {
+			text = who + ":\n" + text
+		}
+		it.comments = append(it.comments, importComment{time: tm, text: text})
+	}
+	for _, it := range tasks {
+		sort.SliceStable(it.comments, func(i, j int) bool {
+			return it.comments[i].time.Before(it.comments[j].time)
+		})
+	}
+	return tasks, nil
+}
+
+// trelloIDTime returns the creation time encoded in a Trello ID,
+// whose first 8 hex digits are a Unix timestamp.
+func trelloIDTime(id string) time.Time {
+	if len(id) < 8 {
+		return time.Time{}
+	}
+	n, err := strconv.ParseInt(id[:8], 16, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(n, 0)
+}
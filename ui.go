@@ -0,0 +1,402 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+// A full-screen terminal interface.
+//
+// The screen shows the tasks matching the current query in the top pane
+// and the selected task's history in the bottom pane, like an acme
+// search window above a task window. It drives the terminal with
+// ANSI escape sequences, using stty to put the terminal in raw mode,
+// so it is not available on Windows.
+
+const (
+	uiHelp         = "j/k move  / query  d done  m mute  s snooze  e edit  n new  g get  q quit"
+	uiSnoozePrompt = "snooze until: "
+)
+
+func cmdUI(args []string) {
+	fs := flag.NewFlagSet("ui", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo ui [query]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+
+	u := &tui{l: taskList(*dirFlag), query: strings.Join(fs.Args(), " ")}
+	if u.query == "" {
+		u.query = defaultQuery(u.l)
+	}
+	if err := u.run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+type tui struct {
+	l       *task.List
+	query   string
+	ids     []string // task IDs in list pane
+	lines   []string // list pane lines, from showQuery
+	sel     int      // selected line
+	top     int      // first line shown in list pane
+	detail  []string // detail pane lines, from showTask
+	status  string
+	editing bool // editing the query
+	prompt  string
+	width   int
+	height  int
+	restore func() // restores the terminal state, from rawMode
+}
+
+func (u *tui) run() error {
+	if err := u.raw(); err != nil {
+		return fmt.Errorf("ui needs a terminal: %v", err)
+	}
+	defer u.cooked()
+
+	// Read a key only when asked, so that no read is pending
+	// while the editor is using the terminal.
+	want := make(chan bool)
+	keys := make(chan string)
+	go func() {
+		buf := make([]byte, 64)
+		for range want {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			// Terminals send each escape sequence in a single write,
+			// so treat each read as a single key.
+			keys <- string(buf[:n])
+		}
+	}()
+
+	u.load()
+	last := time.Now()
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	reading := false
+	for {
+		u.draw()
+		if !reading {
+			want <- true
+			reading = true
+		}
+		select {
+		case k, ok := <-keys:
+			reading = false
+			if !ok || !u.key(k) {
+				return nil
+			}
+		case <-tick.C:
+			// Watch for changes made by other programs.
+			now := time.Now()
+			if ids, err := u.l.Modified(last); err == nil && len(ids) > 0 {
+				u.load()
+			}
+			last = now
+		}
+	}
+}
+
+// raw puts the terminal in raw mode and switches to the alternate screen.
+func (u *tui) raw() error {
+	restore, err := rawMode(os.Stdin)
+	if err != nil {
+		return err
+	}
+	u.restore = restore
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	return nil
+}
+
+// cooked restores the terminal to its original state.
+func (u *tui) cooked() {
+	fmt.Print("\x1b[?25h\x1b[?1049l")
+	if u.restore != nil {
+		u.restore()
+		u.restore = nil
+	}
+}
+
+// load rereads the query results and the selected task.
+func (u *tui) load() {
+	var cur string
+	if u.sel < len(u.ids) {
+		cur = u.ids[u.sel]
+	}
+	var buf bytes.Buffer
+	if err := showQuery(&buf, u.l, u.query, nil); err != nil {
+		u.status = err.Error()
+	}
+	u.lines = nil
+	u.ids = nil
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		i := strings.Index(line, "\t")
+		if i < 0 {
+			// Not a task line, such as a group heading.
+			continue
+		}
+		u.lines = append(u.lines, strings.Replace(line, "\t", "  ", 1))
+		u.ids = append(u.ids, line[:i])
+	}
+	u.sel = 0
+	for i, id := range u.ids {
+		if id == cur {
+			u.sel = i
+		}
+	}
+	u.loadDetail()
+}
+
+func (u *tui) loadDetail() {
+	u.detail = nil
+	if u.sel >= len(u.ids) {
+		return
+	}
+	var buf bytes.Buffer
+	if _, err := showTask(&buf, u.l, u.ids[u.sel]); err != nil {
+		u.detail = []string{err.Error()}
+		return
+	}
+	u.detail = strings.Split(buf.String(), "\n")
+}
+
+func (u *tui) draw() {
+	u.width, u.height = termSize()
+	var b bytes.Buffer
+	b.WriteString("\x1b[H\x1b[2J")
+	line := func(s string, attr string) {
+		if r := []rune(s); len(r) > u.width {
+			s = string(r[:u.width])
+		}
+		if attr != "" {
+			s = attr + s + "\x1b[m"
+		}
+		b.WriteString(s + "\x1b[K\r\n")
+	}
+
+	// Query line, list pane, separator, detail pane, status line.
+	q := "todo: " + u.query
+	if u.editing {
+		q = "query: " + u.query + "_"
+	}
+	line(q, "\x1b[1m")
+	listH := (u.height - 3) / 2
+	if listH < 1 {
+		listH = 1
+	}
+	if u.sel < u.top {
+		u.top = u.sel
+	}
+	if u.sel >= u.top+listH {
+		u.top = u.sel - listH + 1
+	}
+	for i := u.top; i < u.top+listH; i++ {
+		switch {
+		case i >= len(u.lines):
+			line("", "")
+		case i == u.sel:
+			line(u.lines[i], "\x1b[7m")
+		default:
+			line(u.lines[i], "")
+		}
+	}
+	line(strings.Repeat("─", u.width), "\x1b[2m")
+	detailH := u.height - 3 - listH
+	for i := 0; i < detailH; i++ {
+		if i < len(u.detail) {
+			line(strings.Replace(u.detail[i], "\t", "    ", -1), "")
+		} else {
+			line("", "")
+		}
+	}
+	status := u.status
+	if u.prompt != "" {
+		status = u.prompt
+	} else if status == "" {
+		status = fmt.Sprintf("%d tasks  %s", len(u.ids), uiHelp)
+	}
+	if r := []rune(status); len(r) > u.width {
+		status = string(r[:u.width])
+	}
+	b.WriteString("\x1b[2m" + status + "\x1b[m\x1b[K")
+	os.Stdout.Write(b.Bytes())
+}
+
+// key handles the key k, reporting whether to keep running.
+func (u *tui) key(k string) bool {
+	u.status = ""
+	if u.editing {
+		switch k {
+		case "\r", "\n", "\x1b":
+			u.editing = false
+			if strings.TrimSpace(u.query) == "" {
+				u.query = defaultQuery(u.l)
+				u.load()
+			}
+		case "\x7f", "\b":
+			if r := []rune(u.query); len(r) > 0 {
+				u.query = string(r[:len(r)-1])
+				u.load()
+			}
+		default:
+			if k >= " " && !strings.HasPrefix(k, "\x1b") {
+				u.query += k
+				u.load()
+			}
+		}
+		return true
+	}
+	if u.prompt != "" {
+		// Snooze date.
+		switch {
+		case k == "\r" || k == "\n":
+			v, err := snoozeValue(strings.TrimPrefix(u.prompt, uiSnoozePrompt))
+			u.prompt = ""
+			if err != nil {
+				u.status = err.Error()
+				break
+			}
+			u.setTodo(v)
+		case k == "\x1b":
+			u.prompt = ""
+		case k == "\x7f" || k == "\b":
+			if len(u.prompt) > len(uiSnoozePrompt) {
+				u.prompt = u.prompt[:len(u.prompt)-1]
+			}
+		case len(k) == 1 && (k >= "0" && k <= "9" || k >= "a" && k <= "z" || k == "-" || k == " "):
+			u.prompt += k
+		}
+		return true
+	}
+
+	switch k {
+	case "q", "\x03", "\x04":
+		return false
+	case "j", "\x1b[B", "\x0e":
+		if u.sel+1 < len(u.ids) {
+			u.sel++
+			u.loadDetail()
+		}
+	case "k", "\x1b[A", "\x10":
+		if u.sel > 0 {
+			u.sel--
+			u.loadDetail()
+		}
+	case "/":
+		u.editing = true
+		if u.query == "all" {
+			u.query = ""
+		}
+	case "g":
+		u.load()
+	case "d":
+		u.setTodo("done")
+	case "m":
+		u.setTodo("mute")
+	case "s":
+		u.prompt = uiSnoozePrompt
+	case "e":
+		if u.sel < len(u.ids) {
+			var buf bytes.Buffer
+			t, err := showTask(&buf, u.l, u.ids[u.sel])
+			if err != nil {
+				u.status = err.Error()
+				break
+			}
+			u.edit(buf.Bytes(), t)
+		}
+	case "n":
+		u.edit([]byte(createTemplate), nil)
+	}
+	return true
+}
+
+// setTodo sets the todo header of the selected task.
+func (u *tui) setTodo(v string) {
+	if u.sel >= len(u.ids) {
+		return
+	}
+	t, err := u.l.Read(u.ids[u.sel])
+	if err == nil {
+		err = u.l.Write(t, time.Now(), map[string]string{"todo": v}, nil)
+	}
+	if err != nil {
+		u.status = err.Error()
+		return
+	}
+	u.status = t.ID() + ": todo: " + v
+	u.load()
+}
+
+// edit runs the editor on text and applies the changes to t,
+// or creates a new task if t is nil, as todo -e does.
+// If the edit cannot be applied, it is kept, as todo -e keeps it:
+// a new task as a draft for todo resume, and an edit of an existing
+// task in the edited file, whose name the status line reports.
+func (u *tui) edit(text []byte, t *task.Task) {
+	u.cooked()
+	defer u.raw()
+
+	f, err := ioutil.TempFile("", "todo-edit-")
+	if err != nil {
+		u.status = err.Error()
+		return
+	}
+	name := f.Name()
+	f.Close()
+	keep := false
+	defer func() {
+		if !keep {
+			os.Remove(name)
+		}
+	}()
+	if err := ioutil.WriteFile(name, text, 0600); err != nil {
+		u.status = err.Error()
+		return
+	}
+	if err := runEditor(name); err != nil {
+		u.status = err.Error()
+		return
+	}
+	updated, err := ioutil.ReadFile(name)
+	if err != nil {
+		u.status = err.Error()
+		return
+	}
+	if bytes.Equal(text, updated) {
+		u.status = "no changes made"
+		return
+	}
+	if _, err := writeTask(u.l, t, updated); err != nil {
+		msg := strings.Replace(err.Error(), "\n", "; ", -1)
+		if t == nil {
+			if draft, derr := u.l.NewDraft(time.Now(), updated); derr == nil {
+				u.status = fmt.Sprintf("%s; edit saved as draft %s; use todo resume %s to continue", msg, draft, draft)
+				return
+			}
+		}
+		keep = true
+		u.status = fmt.Sprintf("%s; edit saved in %s", msg, name)
+		return
+	}
+	u.load()
+}
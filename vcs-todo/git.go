@@ -0,0 +1,195 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+type gitVCS struct {
+	dir string // repo root
+}
+
+func (gitVCS) name() string { return "git" }
+
+func (g gitVCS) head() (string, error) {
+	out, err := run(g.dir, "git", "rev-parse", "HEAD")
+	return strings.TrimSpace(string(out)), err
+}
+
+func (g gitVCS) exists(id string) bool {
+	return command(g.dir, "git", "cat-file", "-e", id+"^{commit}").Run() == nil
+}
+
+func (g gitVCS) log(opt *logOptions) ([]*commit, error) {
+	args := []string{"log", "--topo-order", "--format=format:%H%x00%B%x00%s%x00%ct%x00%an <%ae>%x00%cn <%ce>%x00"}
+	if opt.since != "" {
+		args = append(args, "--since="+opt.since)
+	}
+	if opt.author != "" {
+		args = append(args, "--author="+opt.author)
+	}
+	if opt.noMerges {
+		args = append(args, "--no-merges")
+	}
+	switch {
+	case opt.head:
+		args = append(args, "-n1", "HEAD")
+	case opt.rev != "":
+		args = append(args, opt.rev)
+	case opt.from != "":
+		args = append(args, opt.from+".."+opt.to)
+	default:
+		args = append(args, opt.to)
+	}
+	args = append(args, "--")
+	out, err := run(g.dir, "git", args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseLog(out)
+}
+
+func (g gitVCS) reachable() (map[string]bool, error) {
+	out, err := run(g.dir, "git", "rev-list", "--branches", "--remotes", "HEAD", "--")
+	if err != nil {
+		return nil, err
+	}
+	return lineSet(out), nil
+}
+
+func (g gitVCS) branch() string {
+	out, err := run(g.dir, "git", "rev-parse", "--abbrev-ref", "HEAD")
+	if b := strings.TrimSpace(string(out)); err == nil && b != "HEAD" {
+		return b
+	}
+	return ""
+}
+
+func (g gitVCS) files(c *commit) (int, error) {
+	if c.annotated {
+		return c.files, nil
+	}
+	out, err := run(g.dir, "git", "diff-tree", "--no-commit-id", "--name-only", "-r", "--root", c.hash)
+	return countLines(out), err
+}
+
+func (g gitVCS) release(c *commit) string {
+	if c.annotated {
+		return c.release
+	}
+	out, err := run(g.dir, "git", "describe", "--contains", "--tags", c.hash)
+	if err != nil {
+		return ""
+	}
+	return trimTagPath(strings.TrimSpace(string(out)))
+}
+
+// trimTagPath trims the path from a tag name printed by
+// git describe --contains, as in v1.2~3 or v1.2^2~1.
+func trimTagPath(tag string) string {
+	if i := strings.IndexAny(tag, "~^"); i >= 0 {
+		tag = tag[:i]
+	}
+	return tag
+}
+
+// nameRevBatch is the number of commits passed to each git name-rev
+// command run by annotate, to stay well under argument length limits.
+const nameRevBatch = 1000
+
+// annotate sets the files and release of each commit in cs,
+// using one git diff-tree command and a git name-rev command
+// for each nameRevBatch commits, rather than two commands per commit.
+// git name-rev is what git describe --contains runs.
+func (g gitVCS) annotate(cs []*commit) error {
+	if len(cs) == 0 {
+		return nil
+	}
+	byHash := make(map[string]*commit)
+	var hashes bytes.Buffer
+	for _, c := range cs {
+		byHash[c.hash] = c
+		hashes.WriteString(c.hash + "\n")
+	}
+
+	// With --stdin, diff-tree prints each commit's hash
+	// before the names of the files it changes.
+	cmd := command(g.dir, "git", "diff-tree", "--stdin", "-r", "--root", "--name-only")
+	cmd.Stdin = &hashes
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("git diff-tree --stdin: %v", err)
+	}
+	files := make(map[string]map[string]bool)
+	var cur map[string]bool
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if byHash[line] != nil {
+			cur = make(map[string]bool)
+			files[line] = cur
+			continue
+		}
+		if cur != nil {
+			cur[line] = true
+		}
+	}
+
+	tags := make(map[string]string)
+	for i := 0; i < len(cs); i += nameRevBatch {
+		batch := cs[i:]
+		if len(batch) > nameRevBatch {
+			batch = batch[:nameRevBatch]
+		}
+		args := []string{"name-rev", "--peel-tag", "--name-only", "--tags"}
+		for _, c := range batch {
+			args = append(args, c.hash)
+		}
+		out, err := run(g.dir, "git", args...)
+		if err != nil {
+			return err
+		}
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		if len(lines) != len(batch) {
+			return fmt.Errorf("git name-rev: printed %d names for %d commits", len(lines), len(batch))
+		}
+		for j, c := range batch {
+			if tag := strings.TrimSpace(lines[j]); tag != "undefined" {
+				tags[c.hash] = trimTagPath(tag)
+			}
+		}
+	}
+
+	for _, c := range cs {
+		c.annotated = true
+		c.files = len(files[c.hash])
+		c.release = tags[c.hash]
+	}
+	return nil
+}
+
+func (g gitVCS) describe(c *commit, stat bool) ([]byte, error) {
+	args := []string{"log", "-n1", c.hash}
+	if stat {
+		args = append(args, "--stat")
+	}
+	return run(g.dir, "git", args...)
+}
+
+func (g gitVCS) diff(c *commit) ([]byte, error) {
+	diff, err := run(g.dir, "git", "show", c.hash)
+	if err != nil {
+		return nil, err
+	}
+	// Drop the log entry preceding the diff.
+	if i := bytes.Index(diff, []byte("\ndiff")); i >= 0 {
+		diff = diff[i+1:]
+	}
+	return diff, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+)
+
+type hgVCS struct {
+	dir string // repo root
+}
+
+func (hgVCS) name() string { return "hg" }
+
+func (h hgVCS) head() (string, error) {
+	out, err := run(h.dir, "hg", "log", "-r", ".", "-T", "{node}")
+	return strings.TrimSpace(string(out)), err
+}
+
+func (h hgVCS) exists(id string) bool {
+	return command(h.dir, "hg", "log", "-r", id, "-T", "{node}").Run() == nil
+}
+
+func (h hgVCS) log(opt *logOptions) ([]*commit, error) {
+	args := []string{"log", "-T", `{node}\0{desc}\0{desc|firstline}\0{date|hgdate}\0{author}\0{author}\0`}
+	if opt.since != "" {
+		args = append(args, "-d", ">"+opt.since)
+	}
+	if opt.author != "" {
+		args = append(args, "-u", opt.author)
+	}
+	if opt.noMerges {
+		args = append(args, "-M")
+	}
+	var revs string
+	switch {
+	case opt.head:
+		revs = "."
+	case opt.rev != "":
+		revs = opt.rev
+	case opt.from != "":
+		revs = "(" + opt.from + "::" + opt.to + ") - " + opt.from
+	default:
+		revs = "::" + opt.to
+	}
+	args = append(args, "-r", "reverse("+revs+")")
+	out, err := run(h.dir, "hg", args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseLog(out)
+}
+
+// reachable returns the visible changesets: those rewritten
+// by a rebase or amend are hidden as obsolete.
+func (h hgVCS) reachable() (map[string]bool, error) {
+	out, err := run(h.dir, "hg", "log", "-r", "all()", "-T", "{node}\n")
+	if err != nil {
+		return nil, err
+	}
+	return lineSet(out), nil
+}
+
+func (h hgVCS) branch() string {
+	out, err := run(h.dir, "hg", "branch")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func (h hgVCS) files(c *commit) (int, error) {
+	out, err := run(h.dir, "hg", "log", "-r", c.hash, "-T", "{join(files, '\\n')}")
+	return countLines(out), err
+}
+
+func (h hgVCS) release(c *commit) string {
+	out, err := run(h.dir, "hg", "log", "-r", "first(tag() & descendants("+c.hash+") - tip)", "-T", "{tags}")
+	if err != nil {
+		return ""
+	}
+	f := strings.Fields(string(out))
+	if len(f) == 0 {
+		return ""
+	}
+	return f[0]
+}
+
+func (h hgVCS) describe(c *commit, stat bool) ([]byte, error) {
+	args := []string{"log", "-v", "-r", c.hash}
+	if stat {
+		args = append(args, "--stat")
+	}
+	return run(h.dir, "hg", args...)
+}
+
+func (h hgVCS) diff(c *commit) ([]byte, error) {
+	return run(h.dir, "hg", "diff", "-c", c.hash)
+}
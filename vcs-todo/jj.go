@@ -0,0 +1,109 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// jjVCS is Jujutsu. The working-copy commit @ is still being
+// edited, so the current commit is its parent, @-.
+type jjVCS struct {
+	dir string // repo root
+}
+
+func (jjVCS) name() string { return "jj" }
+
+func (j jjVCS) head() (string, error) {
+	out, err := run(j.dir, "jj", "log", "--no-graph", "-r", "@-", "-T", "commit_id")
+	return strings.TrimSpace(string(out)), err
+}
+
+func (j jjVCS) exists(id string) bool {
+	return command(j.dir, "jj", "log", "--no-graph", "-r", id, "-T", "commit_id").Run() == nil
+}
+
+const jjTemplate = `commit_id ++ "\0" ++ description ++ "\0" ++ description.first_line() ++ "\0" ++ ` +
+	`committer.timestamp().format("%s") ++ "\0" ++ ` +
+	`author.name() ++ " <" ++ author.email() ++ ">\0" ++ ` +
+	`committer.name() ++ " <" ++ committer.email() ++ ">\0"`
+
+func (j jjVCS) log(opt *logOptions) ([]*commit, error) {
+	var revs string
+	switch {
+	case opt.head:
+		revs = "@-"
+	case opt.rev != "":
+		revs = opt.rev
+	case opt.from != "":
+		revs = opt.from + ".." + opt.to
+	default:
+		revs = "::" + opt.to
+	}
+	revs = "(" + revs + ") ~ root()"
+	if opt.since != "" {
+		revs += " & committer_date(after:" + strconv.Quote(opt.since) + ")"
+	}
+	if opt.author != "" {
+		revs += " & author(" + strconv.Quote(opt.author) + ")"
+	}
+	if opt.noMerges {
+		revs += " & ~merges()"
+	}
+	out, err := run(j.dir, "jj", "log", "--no-graph", "-r", revs, "-T", jjTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return parseLog(out)
+}
+
+func (j jjVCS) reachable() (map[string]bool, error) {
+	out, err := run(j.dir, "jj", "log", "--no-graph", "-r", "::(bookmarks() | @)", "-T", `commit_id ++ "\n"`)
+	if err != nil {
+		return nil, err
+	}
+	return lineSet(out), nil
+}
+
+func (j jjVCS) branch() string {
+	out, err := run(j.dir, "jj", "log", "--no-graph", "-r", "@-", "-T", "bookmarks")
+	if err != nil {
+		return ""
+	}
+	f := strings.Fields(string(out))
+	if len(f) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(f[0], "*")
+}
+
+func (j jjVCS) files(c *commit) (int, error) {
+	out, err := run(j.dir, "jj", "diff", "--name-only", "-r", c.hash)
+	return countLines(out), err
+}
+
+func (j jjVCS) release(c *commit) string {
+	out, err := run(j.dir, "jj", "log", "--no-graph", "-r", "roots(tags() & "+c.hash+"::)", "-T", `tags ++ "\n"`)
+	if err != nil {
+		return ""
+	}
+	f := strings.Fields(string(out))
+	if len(f) == 0 {
+		return ""
+	}
+	return f[0]
+}
+
+func (j jjVCS) describe(c *commit, stat bool) ([]byte, error) {
+	if stat {
+		return run(j.dir, "jj", "show", "--stat", c.hash)
+	}
+	return run(j.dir, "jj", "log", "--no-graph", "-r", c.hash, "-T", "builtin_log_detailed")
+}
+
+func (j jjVCS) diff(c *commit) ([]byte, error) {
+	return run(j.dir, "jj", "diff", "--git", "-r", c.hash)
+}
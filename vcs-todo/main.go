@@ -0,0 +1,695 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Vcs-todo records the commits in git, Mercurial, or Jujutsu repositories
+// as tasks, one list per repository, for reviewing them with todo.
+//
+//	usage: vcs-todo [-root list | -list list] [-rev range] [-since date]
+//	                [-author pattern] [-no-merges] [-close regexp]
+//	                [-diff none|stat|full] [-max-diff-bytes n]
+//	                [-watch [-poll duration] | -hook] [-prune] [-j n] [repo...]
+//
+// By default, the commits in a repository are recorded in the list
+// git/name, hg/name, or jj/name, according to the repository's kind,
+// where name is the base name of the repository directory.
+// The -root flag changes the parent list, and the -list flag
+// names the list for the commits directly, so that repositories
+// with the same base name can use different lists.
+// List names are relative to the todo root: $TODO_DIR, or else $HOME/todo.
+// With no arguments, vcs-todo records the commits in the repository
+// containing the current directory. Given multiple repositories,
+// vcs-todo updates up to four at a time (or -j n), prefixing
+// each message with the repository it is about. Repositories
+// sharing a list are updated one after another.
+//
+// Vcs-todo records the commits leading to the current one
+// (HEAD in git, . in Mercurial, and @- in Jujutsu), remembering the last
+// commit it saw so that later runs only look at newer commits.
+// The -rev flag records the commits in a revision range instead,
+// such as origin/master..HEAD in git, or a revset in Mercurial and Jujutsu.
+// The -since, -author, and -no-merges flags record only commits
+// after a date, by a matching author, or that are not merges,
+// so that a large shared repository can be mirrored selectively.
+//
+// Each task records the commit's hash, author, committer, and
+// Reviewed-on URL, along with its Fixes, Updates, Change-Id,
+// Co-authored-by, and Reviewed-by trailers, as headers such as
+// "fixes: #123". The Change-Id is also the task's external ID:
+// a later commit with the same Change-Id, such as a cherry-pick,
+// is noted in the existing task's cherry-picks header instead
+// of creating a new task.
+//
+// Each task also has headers for triage: "reviewed: no", to be changed
+// to yes (or the reviewer's name) after reviewing the commit;
+// branch, the current branch when the commit was recorded;
+// files, the number of files changed; and release, the earliest tag
+// containing the commit, if there was one when it was recorded.
+// Vcs-todo sets the list's query setting to "reviewed:=no",
+// so that todo with no query shows the commits not yet reviewed.
+//
+// A commit message saying that it fixes a task, as in
+// "Fixes todo/home/123", marks that task done, adding a note
+// about the commit. The -close flag sets the regular expression
+// used to find such references; its first submatch must be
+// the task name relative to the todo root.
+//
+// The -watch flag keeps vcs-todo running, updating the lists
+// every five minutes (or -poll duration). The -hook flag instead
+// installs a post-commit hook in each git repository that runs vcs-todo,
+// with the same list, -close, -author, -no-merges, -diff, and
+// -max-diff-bytes flags, to record each new commit as it is made.
+//
+// The task's text is the commit's log entry, with a summary of the
+// files changed and the diff. The -diff flag sets how much to record:
+// none (just the log entry), stat (with the summary), or full (the default).
+// A diff larger than -max-diff-bytes is stored as an attachment
+// in the list's _attach directory, and the task refers to its file.
+//
+// The -prune flag cleans up after rebases and force-pushes.
+// After recording new commits, it looks for open tasks whose commits
+// are no longer on any local or remote-tracking branch (in Mercurial,
+// are no longer visible; in Jujutsu, are no longer reachable from
+// a bookmark or the working copy).
+// If the rewritten commit kept the Change-Id, and so was noted in the
+// task's cherry-picks header, the task is updated to refer to it.
+// Otherwise the task is marked done as superseded.
+// Pruning assumes that the list holds the commits of only one repository.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: vcs-todo [-root list | -list list] [-rev range] [-since date]\n")
+	fmt.Fprintf(os.Stderr, "                [-author pattern] [-no-merges] [-close regexp]\n")
+	fmt.Fprintf(os.Stderr, "                [-diff none|stat|full] [-max-diff-bytes n]\n")
+	fmt.Fprintf(os.Stderr, "                [-watch [-poll duration] | -hook] [-prune] [-j n] [repo...]\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+var (
+	rootFlag = flag.String("root", "", "record each repo's commits in a sublist of `list` named for the repo (default git, hg, or jj)")
+	listFlag = flag.String("list", "", "record the commits in `list`")
+
+	revFlag      = flag.String("rev", "", "record the commits in the revision `range`, such as A..B, instead of the current history")
+	sinceFlag    = flag.String("since", "", "record only commits more recent than `date`")
+	authorFlag   = flag.String("author", "", "record only commits by authors matching `pattern`")
+	noMergesFlag = flag.Bool("no-merges", false, "record only commits that are not merges")
+	watchFlag    = flag.Bool("watch", false, "keep running, updating the lists periodically")
+	pollFlag     = flag.Duration("poll", 5*time.Minute, "with -watch, update the lists every `duration`")
+	hookFlag     = flag.Bool("hook", false, "install a post-commit hook that records each new commit")
+	headFlag     = flag.Bool("head", false, "record only the current commit (used by the -hook post-commit hook)")
+	diffFlag     = flag.String("diff", "full", "record `mode` of each commit's changes: none, stat, or full")
+	maxDiffFlag  = flag.Int("max-diff-bytes", 32*1024, "attach diffs larger than `n` bytes instead of recording them in the task")
+	jobsFlag     = flag.Int("j", 4, "update up to `n` repos at once")
+	pruneFlag    = flag.Bool("prune", false, "mark done or re-link tasks for commits no longer on any branch")
+	closeFlag    = flag.String("close", `(?i)\b(?:fixes|closes) todo/([a-z0-9_\-./]*[a-z0-9_\-])`, "mark tasks done when commit messages match `regexp`")
+)
+
+var closeRE *regexp.Regexp
+
+// filterKey returns a description of the filter flags,
+// for use in the state key recording the last commit seen.
+func filterKey() string {
+	var args []string
+	if *sinceFlag != "" {
+		args = append(args, "--since="+*sinceFlag)
+	}
+	if *authorFlag != "" {
+		args = append(args, "--author="+*authorFlag)
+	}
+	if *noMergesFlag {
+		args = append(args, "--no-merges")
+	}
+	return strings.Join(args, " ")
+}
+
+var (
+	exitMu sync.Mutex
+	exit   = 0
+)
+
+// fail records that the program should exit with a failure status.
+func fail() {
+	exitMu.Lock()
+	exit = 1
+	exitMu.Unlock()
+}
+
+func main() {
+	log.SetPrefix("vcs-todo: ")
+	log.SetFlags(0)
+	flag.Usage = usage
+	flag.Parse()
+	var err error
+	closeRE, err = regexp.Compile(*closeFlag)
+	if err != nil {
+		log.Fatalf("invalid -close: %v", err)
+	}
+	if closeRE.NumSubexp() < 1 {
+		log.Fatalf("invalid -close: %s has no submatch for the task name", *closeFlag)
+	}
+
+	if *jobsFlag < 1 {
+		log.Fatalf("invalid -j %d: must be at least 1", *jobsFlag)
+	}
+	switch *diffFlag {
+	case "none", "stat", "full":
+	default:
+		log.Fatalf("invalid -diff %s: must be none, stat, or full", *diffFlag)
+	}
+
+	for _, name := range []string{*rootFlag, *listFlag} {
+		if filepath.IsAbs(name) || strings.HasPrefix(filepath.Clean(name), "..") {
+			log.Fatalf("invalid list %s: must be relative to %s", name, task.Root())
+		}
+	}
+	args := flag.Args()
+	if len(args) == 0 {
+		wd, err := os.Getwd()
+		if err != nil {
+			log.Fatal(err)
+		}
+		dir, err := findRepo(wd)
+		if err != nil {
+			log.Fatal(err)
+		}
+		args = []string{dir}
+	}
+
+	// Make the repo paths absolute: update changes directory.
+	for i, arg := range args {
+		abs, err := filepath.Abs(arg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		args[i] = abs
+	}
+
+	if *hookFlag {
+		for _, arg := range args {
+			installHook(arg)
+		}
+		os.Exit(exit)
+	}
+
+	for {
+		updateAll(args)
+		if !*watchFlag {
+			break
+		}
+		time.Sleep(*pollFlag)
+	}
+	os.Exit(exit)
+}
+
+// updateAll updates the lists for the repos dirs,
+// running up to -j updates at once.
+// Repos sharing a list, as with -list, are updated one at a time,
+// since each update rewrites the list's _config and _state files.
+func updateAll(dirs []string) {
+	var groups [][]string
+	index := make(map[string]int)
+	for _, dir := range dirs {
+		key := dir
+		if v := vcsFor(dir); v != nil {
+			key = listName(dir, v)
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], dir)
+	}
+
+	work := make(chan []string)
+	var wg sync.WaitGroup
+	for i := 0; i < *jobsFlag; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range work {
+				for _, dir := range group {
+					update(dir)
+					if *pruneFlag {
+						prune(dir)
+					}
+				}
+			}
+		}()
+	}
+	for _, group := range groups {
+		work <- group
+	}
+	close(work)
+	wg.Wait()
+}
+
+// hookMarker identifies the post-commit hooks installed by vcs-todo.
+// Hooks installed by the old git-todo command are replaced too.
+const hookMarker = "# Installed by vcs-todo -hook."
+
+// installHook installs a post-commit hook in the git repo dir
+// that runs vcs-todo -head with the current list, close, filter,
+// and diff flags.
+func installHook(dir string) {
+	if v := vcsFor(dir); v == nil || v.name() != "git" {
+		log.Printf("%s: -hook only supports git repos", dir)
+		fail()
+		return
+	}
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--git-path", "hooks/post-commit").CombinedOutput()
+	if err != nil {
+		log.Printf("%s: git rev-parse --git-path: %v\n%s", dir, err, out)
+		fail()
+		return
+	}
+	file := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	if data, err := ioutil.ReadFile(file); err == nil && !bytes.Contains(data, []byte(hookMarker)) && !bytes.Contains(data, []byte("# Installed by git-todo -hook.")) {
+		log.Printf("%s: %s already exists", dir, file)
+		fail()
+		return
+	}
+
+	prog, err := os.Executable()
+	if err != nil {
+		prog = "vcs-todo"
+	}
+	cmd := []string{shellQuote(prog), "-head"}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "root", "list", "close", "author", "no-merges", "diff", "max-diff-bytes":
+			cmd = append(cmd, shellQuote("-"+f.Name+"="+f.Value.String()))
+		}
+	})
+	script := "#!/bin/sh\n" + hookMarker + "\n" + strings.Join(cmd, " ") + "\n"
+	if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+		log.Print(err)
+		fail()
+		return
+	}
+	if err := ioutil.WriteFile(file, []byte(script), 0777); err != nil {
+		log.Print(err)
+		fail()
+		return
+	}
+	if err := os.Chmod(file, 0777); err != nil {
+		log.Print(err)
+		fail()
+	}
+}
+
+// shellQuote returns s quoted for use as a single shell word.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// reviewQuery is the saved search added to each list,
+// selecting the commits not yet reviewed.
+const reviewQuery = "reviewed:=no"
+
+// listName returns the name of the list for the commits in the repo dir.
+func listName(dir string, v vcs) string {
+	if *listFlag != "" {
+		return *listFlag
+	}
+	root := *rootFlag
+	if root == "" {
+		root = v.name()
+	}
+	return filepath.Join(root, filepath.Base(dir))
+}
+
+func update(dir string) {
+	v := vcsFor(dir)
+	if v == nil {
+		log.Printf("%s: not a git, hg, or jj root", dir)
+		fail()
+		return
+	}
+
+	name := listName(dir, v)
+	if err := task.MakeList(name); err != nil {
+		log.Printf("%s: %v", dir, err)
+		fail()
+		return
+	}
+	l := task.OpenList(name)
+	l.AllowWrites()
+	l.Importing()
+	if l.Config().Get("query") == "" {
+		if err := l.AddConfig("query", reviewQuery); err != nil {
+			log.Printf("%s: %v", dir, err)
+			fail()
+		}
+	}
+
+	opt := &logOptions{
+		rev:      *revFlag,
+		head:     *headFlag,
+		since:    *sinceFlag,
+		author:   *authorFlag,
+		noMerges: *noMergesFlag,
+	}
+
+	// Only look at commits since the last run, if we know what it saw.
+	// The state key is the repo directory and filters, in case lists
+	// are shared or the filters change. An explicit -rev range
+	// is always processed in full.
+	var stateKey string
+	if opt.rev == "" && !opt.head {
+		head, err := v.head()
+		if err != nil {
+			log.Printf("%s: %v", dir, err)
+			fail()
+			return
+		}
+		opt.to = head
+		stateKey = "last commit " + dir
+		if f := filterKey(); f != "" {
+			stateKey += " " + f
+		}
+		last := l.State(stateKey)
+		if last == head {
+			return // nothing new
+		}
+		if last != "" && v.exists(last) {
+			opt.from = last
+		}
+	}
+
+	commits, err := v.log(opt)
+	if err != nil {
+		log.Printf("%s: %v", dir, err)
+		fail()
+		return
+	}
+	if b, ok := v.(batcher); ok {
+		if err := b.annotate(commits); err != nil {
+			// files and release ask about each commit instead.
+			log.Printf("%s: %v", dir, err)
+		}
+	}
+	branch := v.branch()
+	eids, err := l.ExternalIDs()
+	if err != nil {
+		log.Printf("%s: %v", dir, err)
+		fail()
+		return
+	}
+	ok := true
+	defer func() {
+		// Record the head we processed, unless some commit failed,
+		// in which case the next run must look at it again.
+		if ok && stateKey != "" {
+			if err := l.SetState(stateKey, opt.to); err != nil {
+				log.Printf("%s: %v", dir, err)
+				fail()
+			}
+		}
+	}()
+
+Log:
+	for _, c := range commits {
+		hash := c.hash
+		message := c.message
+		subject := c.subject
+
+		// Shorten hash to 7 digits, like old-school Git.
+		// We don't need perfect uniqueness: if we collide
+		// with an older entry, we can let it keep the 7-digit
+		// prefix and use the 8-digit prefix for the newer commit.
+		// We should expect about 6 such collisions for 40k commits
+		// (the current Go repo size, in June 2019),
+		// and only about 37 collisions for 100k commits,
+		// which we won't reach for quite a long time.
+		//
+		//	$ hoc
+		//	func expect(digits, n) { return n * (1 - (1 - 1/16^digits)^(n-1)) }
+		//	expect(7, 4e4)
+		//	5.959871432077435
+		//	expect(7, 1e5)
+		//	37.24559263136307
+		//
+		var id string
+		for n := 7; ; n++ {
+			id = hash[:n]
+			t, err := l.Read(id)
+			if err != nil {
+				break
+			}
+			if t.Header("commit") == hash {
+				// Already have this commit.
+				continue Log
+			}
+		}
+
+		url := ""
+		for _, line := range strings.Split(message, "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "Reviewed-on: ") {
+				url = strings.TrimSpace(strings.TrimPrefix(line, "Reviewed-on:"))
+			}
+		}
+
+		// A commit with the same Change-Id as an earlier one
+		// is a cherry-pick of it: note it in the existing task.
+		tr := trailers(message)
+		changeID := ""
+		if ids := tr["change-id"]; len(ids) > 0 {
+			changeID = ids[0]
+		}
+		if t := eids[changeID]; changeID != "" && t != nil {
+			picks := t.Header("cherry-picks")
+			if t.Header("commit") != hash && !strings.Contains(picks, hash) {
+				hdr := map[string]string{"cherry-picks": strings.TrimSpace(picks + " " + hash)}
+				if t.Done() {
+					// Keep the task done.
+					hdr["todo"] = t.Header("todo")
+				}
+				comment := fmt.Sprintf("Also committed as %s.\n\n%s\n", hash, subject)
+				if err := l.Write(t, time.Now(), hdr, []byte(comment)); err != nil {
+					log.Printf("%s: write task: %v", dir, err)
+					fail()
+					ok = false
+					return
+				}
+			}
+			continue Log
+		}
+
+		hdr := map[string]string{
+			"title":     subject,
+			"commit":    hash,
+			"author":    c.author,
+			"committer": c.committer,
+			"reviewed":  "no",
+		}
+		if url != "" {
+			hdr["url"] = url
+		}
+		if branch != "" {
+			hdr["branch"] = branch
+		}
+		if n, err := v.files(c); err == nil {
+			hdr["files"] = strconv.Itoa(n)
+		}
+		if tag := v.release(c); tag != "" {
+			hdr["release"] = tag
+		}
+		for _, k := range trailerHeaders {
+			if v := tr[k]; len(v) > 0 {
+				hdr[k] = strings.Join(v, ", ")
+			}
+		}
+		if changeID != "" {
+			hdr["#id"] = changeID
+		}
+		body, err := commitText(l, v, c, id)
+		if err != nil {
+			log.Printf("%s: %v", dir, err)
+			fail()
+			ok = false
+			continue
+		}
+
+		t, err := l.Create(id, c.time, hdr, body)
+		if err != nil {
+			log.Printf("%s: write task: %v", dir, err)
+			fail()
+			ok = false
+			return
+		}
+		if changeID != "" {
+			eids[changeID] = t
+		}
+		closeTasks(dir, message, hash, subject, url)
+	}
+}
+
+// prune updates the open tasks in the list for the repo dir
+// whose commits are no longer reachable, re-linking each to its
+// rewritten commit if known and otherwise marking it done.
+func prune(dir string) {
+	v := vcsFor(dir)
+	if v == nil {
+		return // reported by update
+	}
+	name := listName(dir, v)
+	if !task.IsList(name) {
+		return
+	}
+	l := task.OpenList(name)
+	l.AllowWrites()
+	l.Importing()
+	reach, err := v.reachable()
+	if err != nil {
+		log.Printf("%s: %v", dir, err)
+		fail()
+		return
+	}
+	tasks, err := l.All()
+	if err != nil {
+		log.Printf("%s: %v", dir, err)
+		fail()
+		return
+	}
+	for _, t := range tasks {
+		hash := t.Header("commit")
+		if hash == "" || reach[hash] {
+			continue
+		}
+
+		// A rewritten commit with the same Change-Id
+		// was recorded as a cherry-pick of this one.
+		var hdr map[string]string
+		var comment string
+		picks := strings.Fields(t.Header("cherry-picks"))
+		for i, p := range picks {
+			if reach[p] {
+				rest := append(picks[:i:i], picks[i+1:]...)
+				hdr = map[string]string{"commit": p, "cherry-picks": strings.Join(rest, " ")}
+				comment = fmt.Sprintf("Commit %s was rewritten as %s.\n", hash, p)
+				break
+			}
+		}
+		if hdr == nil {
+			hdr = map[string]string{"todo": "done"}
+			comment = fmt.Sprintf("Commit %s was superseded: it is no longer on any branch.\n", hash)
+		}
+		if err := l.Write(t, time.Now(), hdr, []byte(comment)); err != nil {
+			log.Printf("%s: %s: %v", dir, t.ID(), err)
+			fail()
+		}
+	}
+}
+
+// commitText returns the text to record for c in the task id,
+// attaching the diff to the task if it is too large.
+func commitText(l *task.List, v vcs, c *commit, id string) ([]byte, error) {
+	body, err := v.describe(c, *diffFlag != "none")
+	if err != nil || *diffFlag != "full" {
+		return body, err
+	}
+	diff, err := v.diff(c)
+	if err != nil {
+		return nil, err
+	}
+	body = append(body, '\n')
+	if len(diff) <= *maxDiffFlag {
+		return append(body, diff...), nil
+	}
+	file, err := l.Attach(id, "diff", diff)
+	if err != nil {
+		return nil, err
+	}
+	return append(body, fmt.Sprintf("Diff (%d bytes) attached as %s\n", len(diff), file)...), nil
+}
+
+// closeTasks marks done the tasks that the commit message says it fixes,
+// noting the commit in each.
+func closeTasks(dir, message, hash, subject, url string) {
+	for _, m := range closeRE.FindAllStringSubmatch(message, -1) {
+		name := strings.Trim(m[1], "/")
+		list, id := path.Split(name)
+		if list == "" {
+			list = "."
+		}
+		if !task.IsList(list) {
+			log.Printf("%s: %s: no list for todo/%s", dir, hash[:7], name)
+			continue
+		}
+		l := task.OpenList(list)
+		t, err := l.Read(id)
+		if err != nil {
+			log.Printf("%s: %s: todo/%s: %v", dir, hash[:7], name, err)
+			continue
+		}
+		if t.Done() {
+			continue
+		}
+		comment := fmt.Sprintf("Fixed by commit %s.\n\n%s\n", hash, subject)
+		if url != "" {
+			comment += url + "\n"
+		}
+		if err := l.Write(t, time.Now(), map[string]string{"todo": "done"}, []byte(comment)); err != nil {
+			log.Printf("%s: %s: todo/%s: %v", dir, hash[:7], name, err)
+			fail()
+		}
+	}
+}
+
+// trailerHeaders are the commit message trailers recorded as task headers,
+// lower-cased, as in "fixes: #123".
+var trailerHeaders = []string{
+	"fixes",
+	"updates",
+	"change-id",
+	"co-authored-by",
+	"reviewed-by",
+}
+
+// trailers returns the trailers in the final paragraph of a commit message,
+// such as "Fixes: #123" or "Change-Id: I1234", keyed by lower-case name.
+func trailers(message string) map[string][]string {
+	m := make(map[string][]string)
+	paras := strings.Split(strings.TrimSpace(message), "\n\n")
+	if len(paras) < 2 {
+		// Only a subject.
+		return m
+	}
+	for _, line := range strings.Split(paras[len(paras)-1], "\n") {
+		i := strings.Index(line, ":")
+		if i <= 0 || strings.ContainsAny(line[:i], " \t") {
+			continue
+		}
+		k := strings.ToLower(line[:i])
+		if v := strings.TrimSpace(line[i+1:]); v != "" {
+			m[k] = append(m[k], v)
+		}
+	}
+	return m
+}
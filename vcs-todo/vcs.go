@@ -0,0 +1,188 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A vcs is a repository in a version control system
+// whose commits can be recorded as tasks.
+type vcs interface {
+	// name returns the system's name, such as "git",
+	// which is also the default parent list for its repos.
+	name() string
+
+	// head returns the ID of the current commit.
+	head() (string, error)
+
+	// exists reports whether the commit id exists.
+	exists(id string) bool
+
+	// log returns the commits selected by opt, newest first.
+	log(opt *logOptions) ([]*commit, error)
+
+	// reachable returns the set of commits still on a branch,
+	// or the nearest equivalent, keyed by full ID.
+	reachable() (map[string]bool, error)
+
+	// branch returns the name of the current branch, or "" if unknown.
+	branch() string
+
+	// files returns the number of files changed by c.
+	files(c *commit) (int, error)
+
+	// release returns the earliest tag containing c, or "" if none does.
+	release(c *commit) string
+
+	// describe returns the commit's log entry, with a summary
+	// of the files changed if stat is set.
+	describe(c *commit, stat bool) ([]byte, error)
+
+	// diff returns the commit's diff.
+	diff(c *commit) ([]byte, error)
+}
+
+// A batcher is a vcs that can look up the files and release
+// of many commits at once, which is much faster than asking
+// about each commit in turn. After annotate(cs), files and release
+// answer from the results for the commits in cs.
+type batcher interface {
+	annotate(cs []*commit) error
+}
+
+// logOptions selects the commits for vcs.log.
+type logOptions struct {
+	rev      string // if set, the commits in this revision range, in the system's syntax
+	from, to string // otherwise, the commits up to to, after from if it is set
+	head     bool   // only the current commit
+	since    string // only commits after this date
+	author   string // only commits by authors matching this pattern
+	noMerges bool   // only commits that are not merges
+}
+
+// A commit is a single commit read from a repository log.
+type commit struct {
+	hash      string
+	message   string
+	subject   string
+	time      time.Time
+	author    string
+	committer string
+
+	// Set by batcher.annotate.
+	annotated bool   // files and release are known
+	files     int    // number of files changed
+	release   string // earliest tag containing the commit
+}
+
+// vcsFor returns the version control system for the repo
+// whose root directory is dir, or nil if dir is not a repo root.
+// A Jujutsu repo colocated with git is treated as Jujutsu.
+func vcsFor(dir string) vcs {
+	for _, v := range []struct {
+		meta string
+		vcs  vcs
+	}{
+		{".jj", jjVCS{dir}},
+		{".hg", hgVCS{dir}},
+		{".git", gitVCS{dir}},
+	} {
+		if _, err := os.Stat(filepath.Join(dir, v.meta)); err == nil {
+			return v.vcs
+		}
+	}
+	return nil
+}
+
+// findRepo returns the root directory of the repo containing dir.
+func findRepo(dir string) (string, error) {
+	for d := dir; ; {
+		if vcsFor(d) != nil {
+			return d, nil
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return "", fmt.Errorf("%s: not in a git, hg, or jj repo", dir)
+		}
+		d = parent
+	}
+}
+
+// lineSet returns the set of non-empty lines in out.
+func lineSet(out []byte) map[string]bool {
+	m := make(map[string]bool)
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			m[line] = true
+		}
+	}
+	return m
+}
+
+// command returns a command to run the named program in dir.
+func command(dir, name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	return cmd
+}
+
+// countLines returns the number of non-empty lines in out.
+func countLines(out []byte) int {
+	return len(lineSet(out))
+}
+
+// run runs the named program in dir and returns its standard output.
+func run(dir, name string, args ...string) ([]byte, error) {
+	cmd := command(dir, name, args...)
+	out, err := cmd.Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("%s %s: %v\n%s", name, strings.Join(args, " "), err, ee.Stderr)
+		}
+		return nil, fmt.Errorf("%s %s: %v", name, strings.Join(args, " "), err)
+	}
+	return out, nil
+}
+
+// logFields is the number of NUL-terminated fields per commit
+// in the log output parsed by parseLog: hash, message, subject,
+// time (Unix seconds, optionally followed by more text),
+// author, and committer.
+const logFields = 6
+
+// parseLog parses log output in the form described by logFields.
+func parseLog(out []byte) ([]*commit, error) {
+	fields := strings.Split(string(out), "\x00")
+	for i, field := range fields {
+		fields[i] = strings.TrimLeft(field, "\r\n")
+	}
+	var commits []*commit
+	for i := 0; i+logFields <= len(fields); i += logFields {
+		f := strings.Fields(fields[i+3])
+		if len(f) == 0 {
+			return nil, fmt.Errorf("invalid unix time %q", fields[i+3])
+		}
+		unixtime, err := strconv.ParseInt(f[0], 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unix time %q", fields[i+3])
+		}
+		commits = append(commits, &commit{
+			hash:      fields[i],
+			message:   fields[i+1],
+			subject:   fields[i+2],
+			time:      time.Unix(unixtime, 0),
+			author:    fields[i+4],
+			committer: fields[i+5],
+		})
+	}
+	return commits, nil
+}
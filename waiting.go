@@ -0,0 +1,100 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+func cmdWaiting(args []string) {
+	fs := flag.NewFlagSet("waiting", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo waiting [who]\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+	}
+	var buf bytes.Buffer
+	if err := writeWaiting(&buf, taskList(*dirFlag), fs.Arg(0), time.Now()); err != nil {
+		log.Fatal(err)
+	}
+	page(buf.Bytes())
+}
+
+// writeWaiting writes to w the tasks in l and its sublists
+// waiting on someone (or, if who is not empty, on who),
+// grouped by person. Tasks whose waiting date has passed,
+// and so have resurfaced in queries, are marked as such.
+// Task names are relative to l.
+func writeWaiting(w io.Writer, l *task.List, who string, now time.Time) error {
+	type entry struct {
+		ref  string
+		t    *task.Task
+		date string
+	}
+	byWho := make(map[string][]entry)
+	for _, sub := range allLists(l) {
+		all, err := sub.All()
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(sub.Name(), l.Name()), "/")
+		for _, t := range all {
+			name, date, ok := task.ParseWaiting(t.Header("todo"))
+			if !ok || who != "" && name != who {
+				continue
+			}
+			byWho[name] = append(byWho[name], entry{path.Join(rel, t.ID()), t, date})
+		}
+	}
+	if len(byWho) == 0 {
+		fmt.Fprintf(w, "no tasks waiting\n")
+		return nil
+	}
+
+	var names []string
+	for name := range byWho {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		if i > 0 {
+			fmt.Fprintf(w, "\n")
+		}
+		entries := byWho[name]
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].date != entries[j].date {
+				return entries[i].date < entries[j].date
+			}
+			return entries[i].ref < entries[j].ref
+		})
+		fmt.Fprintf(w, "%s (%d)\n", name, len(entries))
+		for _, e := range entries {
+			note := ""
+			if e.date != "" {
+				note = "\tuntil " + e.date
+				if !e.t.Deferred(now) {
+					note += " (passed)"
+				}
+			}
+			fmt.Fprintf(w, "\t%s\t%s%s\n", e.ref, e.t.Title(), note)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+// watch implements todo -w: it prints the output of show,
+// headed by title and the time, and prints it again whenever
+// a task in l or its sublists changes, checking every second,
+// and, if every > 0, at least that often. On a terminal, each
+// printing clears the screen and is cut to fit, making a text dashboard.
+// Watch returns only if show fails.
+func watch(l *task.List, title string, every time.Duration, show func(io.Writer) error) error {
+	tty := isTerminal(os.Stdout)
+	last := time.Now()
+	for {
+		var buf bytes.Buffer
+		if err := show(&buf); err != nil {
+			return err
+		}
+		out := buf.Bytes()
+		if tty {
+			// Leave room for the heading and blank line,
+			// and keep the cursor on the screen.
+			if _, height := termSize(); height > 3 {
+				lines := bytes.SplitAfter(out, []byte("\n"))
+				if len(lines) > height-3 {
+					out = bytes.Join(lines[:height-3], nil)
+				}
+			}
+			fmt.Print("\x1b[H\x1b[2J")
+		}
+		fmt.Printf("%s\t%s\n\n", title, time.Now().Format("15:04:05"))
+		os.Stdout.Write(out)
+
+		next := time.Now().Add(every)
+		for {
+			time.Sleep(time.Second)
+			now := time.Now()
+			changed := false
+			for _, sub := range allLists(l) {
+				if ids, err := sub.Modified(last); err == nil && len(ids) > 0 {
+					changed = true
+					break
+				}
+			}
+			last = now
+			if changed || every > 0 && !now.Before(next) {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"rsc.io/todo/task"
+)
+
+// A webServer serves a web interface to the lists under root.
+// It mirrors the acme interface: list and search windows,
+// task windows showing the headers and history,
+// and the Get, Put, Done, Mute, and Snooze commands.
+//
+// A URL path naming a list, such as /work/, shows the list's open tasks.
+// A path naming a task, such as /work/123, shows that task.
+// The feed.atom file in a list, such as /work/feed.atom,
+// is an Atom feed of recent updates to the list's tasks.
+// Commands are sent as POST requests to the same paths.
+// To keep other web sites from sending commands through a visitor's
+// browser, a POST must carry the token included in the server's
+// own forms and, if the browser names the page it came from,
+// come from the same host.
+//
+// Each request reads the lists afresh, so that pages and feeds
+// show changes made by other programs.
+type webServer struct {
+	root  string
+	token string // form token for POST requests
+}
+
+func newWebServer(root string) *webServer {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return &webServer{root: root, token: hex.EncodeToString(b[:])}
+}
+
+func (s *webServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" && !s.checkPost(r) {
+		http.Error(w, "invalid form token or origin", http.StatusForbidden)
+		return
+	}
+	p := strings.Trim(path.Clean("/"+r.URL.Path), "/")
+	name := path.Join(s.root, p)
+	if path.Base(p) == "feed.atom" {
+		dir := path.Dir(name)
+		if !task.IsList(dir) {
+			http.NotFound(w, r)
+			return
+		}
+		base := path.Dir("/"+p) + "/"
+		if base == "//" {
+			base = "/"
+		}
+		s.serveFeed(w, r, task.OpenList(dir), base)
+		return
+	}
+	if p == "" || task.IsList(name) {
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			http.Redirect(w, r, r.URL.Path+"/", http.StatusFound)
+			return
+		}
+		s.serveList(w, r, task.OpenList(name), p)
+		return
+	}
+	dir, id := path.Split(name)
+	l := task.OpenList(dir)
+	if (dir != "" && !task.IsList(dir)) || !l.Exists(id) {
+		http.NotFound(w, r)
+		return
+	}
+	s.serveTask(w, r, l, id, p)
+}
+
+// checkPost reports whether the POST request r carries the server's
+// form token and, if it has an Origin or Referer header, comes from
+// a page on the same host.
+func (s *webServer) checkPost(r *http.Request) bool {
+	if subtle.ConstantTimeCompare([]byte(r.PostFormValue("token")), []byte(s.token)) != 1 {
+		return false
+	}
+	from := r.Header.Get("Origin")
+	if from == "" {
+		from = r.Header.Get("Referer")
+	}
+	if from == "" {
+		return true
+	}
+	u, err := url.Parse(from)
+	return err == nil && u.Host == r.Host
+}
+
+type webListPage struct {
+	Title    string
+	Query    string
+	Sublists []string
+	Tasks    []*task.Task
+	New      string
+	Token    string
+}
+
+func (s *webServer) serveList(w http.ResponseWriter, r *http.Request, l *task.List, p string) {
+	if r.Method == "POST" {
+		// New: create a task from the submitted template.
+		t, err := writeTask(l, nil, formText(r, "text"))
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
+			return
+		}
+		http.Redirect(w, r, t.ID(), http.StatusSeeOther)
+		return
+	}
+
+	q := strings.TrimSpace(r.FormValue("q"))
+	if q == "" {
+		q = "all"
+	}
+	tasks, err := searchContext(r.Context(), l, q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sort.Sort(tasksByTitle(tasks))
+	page := &webListPage{
+		Title:    "/" + p,
+		Query:    q,
+		Sublists: l.Sublists(),
+		Tasks:    tasks,
+		New:      createTemplate,
+		Token:    s.token,
+	}
+	webRender(w, webListTemplate, page)
+}
+
+type webTaskPage struct {
+	Title   string
+	ID      string
+	Header  string
+	History string
+	Token   string
+}
+
+func (s *webServer) serveTask(w http.ResponseWriter, r *http.Request, l *task.List, id, p string) {
+	t, err := l.ReadContext(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	if r.Method == "POST" {
+		var hdr map[string]string
+		switch action := r.FormValue("action"); action {
+		case "Put":
+			// Put: apply the edited headers and add the comment, if any,
+			// like Put in an acme task window.
+			text := bytes.TrimSpace(formText(r, "header"))
+			text = append(text, "\n\n"...)
+			text = append(text, bytes.TrimSpace(formText(r, "comment"))...)
+			text = append(text, "\n— "...)
+			if _, err := writeTask(l, t, text); err != nil {
+				http.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
+				return
+			}
+		case "Done":
+			hdr = map[string]string{"todo": "done"}
+		case "Mute":
+			hdr = map[string]string{"todo": "mute"}
+		case "Snooze":
+			v, err := snoozeValue(r.FormValue("until"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			hdr = map[string]string{"todo": v}
+		default:
+			http.Error(w, fmt.Sprintf("unknown command %q", action), http.StatusBadRequest)
+			return
+		}
+		if hdr != nil {
+			if err := l.Write(t, time.Now(), hdr, nil); err != nil {
+				http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+				return
+			}
+		}
+		// Get: redisplay the updated task.
+		http.Redirect(w, r, id, http.StatusSeeOther)
+		return
+	}
+
+	var buf bytes.Buffer
+	t.PrintTo(&buf)
+	text := buf.String()
+	i := strings.Index(text, "\n\n")
+	page := &webTaskPage{
+		Title:   "/" + p,
+		ID:      id,
+		Header:  text[:i+1],
+		History: text[i+2:],
+		Token:   s.token,
+	}
+	webRender(w, webTaskTemplate, page)
+}
+
+// errorStatus returns the HTTP status for err, a failure to read
+// or write a task, or def if err is not one of the task package's
+// error kinds.
+func errorStatus(err error, def int) int {
+	switch {
+	case errors.Is(err, task.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, task.ErrReadOnly):
+		return http.StatusForbidden
+	case errors.Is(err, task.ErrExists), errors.Is(err, task.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, task.ErrMalformed):
+		return http.StatusUnprocessableEntity
+	}
+	return def
+}
+
+// formText returns the named form value,
+// with the CRLF line endings sent by browsers converted to LF.
+func formText(r *http.Request, key string) []byte {
+	return []byte(strings.Replace(r.FormValue(key), "\r\n", "\n", -1))
+}
+
+func webRender(w http.ResponseWriter, t *template.Template, data interface{}) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+const webStyle = `
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<style>
+body { font-family: sans-serif; margin: 1em; max-width: 50em; }
+pre, textarea { font-family: monospace; font-size: 90%; }
+textarea { width: 100%; box-sizing: border-box; }
+table { border-collapse: collapse; }
+td { padding: 0.2em 0.5em 0.2em 0; vertical-align: top; }
+form { margin: 0.5em 0; }
+</style>
+`
+
+var webListTemplate = template.Must(template.New("list").Parse(`<!DOCTYPE html>
+<title>todo {{.Title}}</title>
+` + webStyle + `
+<h1>todo {{.Title}}</h1>
+<p><a href="../">up</a> · <a href="./">Get</a> · <a href="feed.atom">feed</a></p>
+<form method="GET" action="./">
+<input name="q" value="{{.Query}}" size="30"> <input type="submit" value="Search">
+</form>
+{{if .Sublists}}
+<p>Lists: {{range .Sublists}}<a href="{{.}}/">{{.}}/</a> {{end}}</p>
+{{end}}
+<table>
+{{range .Tasks}}
+<tr><td><a href="{{.ID}}">{{.ID}}</a></td><td>{{.Title}}</td></tr>
+{{else}}
+<tr><td>No tasks.</td></tr>
+{{end}}
+</table>
+<h2>New</h2>
+<form method="POST" action="./">
+<input type="hidden" name="token" value="{{.Token}}">
+<textarea name="text" rows="6">{{.New}}</textarea>
+<input type="submit" value="Put">
+</form>
+`))
+
+var webTaskTemplate = template.Must(template.New("task").Parse(`<!DOCTYPE html>
+<title>todo {{.Title}}</title>
+` + webStyle + `
+<h1>todo {{.Title}}</h1>
+<p><a href="./">up</a> · <a href="{{.ID}}">Get</a></p>
+<form method="POST" action="{{.ID}}">
+<input type="hidden" name="token" value="{{.Token}}">
+<input type="submit" name="action" value="Done">
+<input type="submit" name="action" value="Mute">
+<input type="submit" name="action" value="Snooze"> until <input name="until" value="tomorrow" size="10">
+</form>
+<form method="POST" action="{{.ID}}">
+<input type="hidden" name="token" value="{{.Token}}">
+<textarea name="header" rows="8">{{.Header}}</textarea>
+<textarea name="comment" rows="4" placeholder="optional comment"></textarea>
+<input type="submit" name="action" value="Put">
+</form>
+<pre>{{.History}}</pre>
+`))